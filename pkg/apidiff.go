@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// apiChange is a change of the api of a composite between two definitions
+type apiChange struct {
+	// path of the changed field, prefixed with the version of the xrd
+	path     string
+	kind     apiChangeKind
+	message  string
+	breaking bool
+}
+
+type apiChangeKind string
+
+const (
+	apiAdded   apiChangeKind = "added"
+	apiRemoved apiChangeKind = "removed"
+	// apiChanged is a change of the type or the validations of a field
+	apiChanged apiChangeKind = "changed"
+)
+
+// Run the diff-api subcommand: compare the existing definitions with the
+// generated ones and fail if there are breaking changes not acknowledged in
+// acknowledgedBreakingChanges of the generate.yaml
+func runDiffAPI(argv []string, out io.Writer) error {
+	var args arguments
+	fs := flag.NewFlagSet("diff-api", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := addFlags(fs, &args); err != nil {
+		return err
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: x-generation diff-api [flags]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(argv); err != nil {
+		return err
+	}
+	setDefaultArgs(&args)
+
+	list, err := findGenerators(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot find generator files")
+	}
+	generatorConfig, err := loadGeneratorConfig(args.configFile, !args.allowUnknownFields)
+	if err != nil {
+		return errors.Wrap(err, "cannot load generator config file")
+	}
+	if err := checkConfig(generatorConfig); err != nil {
+		return errors.Wrap(err, "generator config not valid")
+	}
+	loader, err := newCRDLoader(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot load lock file")
+	}
+
+	breaking := false
+	failures := []failure{}
+	results := generateAll(list, args.concurrency, args.keepGoing, func(path string, out io.Writer) (bool, error) {
+		return diffAPI(path, &args, generatorConfig, loader, out)
+	})
+	for i, r := range results {
+		<-r.done
+		if r.skipped {
+			continue
+		}
+		out.Write(r.output.Bytes())
+		if r.err != nil {
+			failures = append(failures, failure{path: list[i], err: r.err})
+			continue
+		}
+		if !r.upToDate {
+			breaking = true
+		}
+	}
+	if len(failures) > 0 {
+		printFailures(out, failures, len(list))
+		return errors.New("cannot compare all definitions")
+	}
+	if breaking {
+		return errors.New("breaking api changes found, acknowledge them in acknowledgedBreakingChanges of the generate.yaml")
+	}
+	return nil
+}
+
+// Compare the existing definition of the generate file at path with the
+// generated one and print the changes, false is returned if there are breaking
+// changes which are not acknowledged
+func diffAPI(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, out io.Writer) (bool, error) {
+	g, err := loadGenerator(path, args, generatorConfig, loader, out)
+	if err != nil || g == nil {
+		return err == nil, err
+	}
+	files, err := g.Render(generatorConfig, args.scriptPath, args.scriptFile, args.outputPath)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		if filepath.Base(f.path) != "definition.yaml" {
+			continue
+		}
+		existing, err := os.ReadFile(f.path)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(out, "%s: no existing definition\n", f.path)
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		var oldXRD, newXRD crossplanev1.CompositeResourceDefinition
+		if err := yaml.Unmarshal(stripHeader(existing), &oldXRD); err != nil {
+			return false, errors.Wrapf(err, "cannot unmarshal %s", f.path)
+		}
+		if err := yaml.Unmarshal(f.content, &newXRD); err != nil {
+			return false, errors.Wrap(err, "cannot unmarshal generated definition")
+		}
+		changes, err := diffDefinitions(&oldXRD, &newXRD)
+		if err != nil {
+			return false, errors.Wrapf(err, "cannot compare %s", f.path)
+		}
+		return printAPIChanges(out, f.path, changes, g.AcknowledgedBreakingChanges), nil
+	}
+	return true, nil
+}
+
+// Print the changes of the definition at path, false is returned if there are
+// breaking changes which are not acknowledged
+func printAPIChanges(out io.Writer, path string, changes []apiChange, acknowledged []string) bool {
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "%s: no api changes\n", path)
+		return true
+	}
+	ok := true
+	fmt.Fprintf(out, "%s:\n", path)
+	for _, c := range changes {
+		kind := "non-breaking"
+		if c.breaking {
+			if isAcknowledged(c.path, acknowledged) {
+				kind = "breaking (acknowledged)"
+			} else {
+				kind = "breaking"
+				ok = false
+			}
+		}
+		fmt.Fprintf(out, "  %s %s: %s\n", kind, c.path, c.message)
+	}
+	return ok
+}
+
+// Check if the change at path is acknowledged, acknowledging a path includes
+// the changes of the fields below it
+func isAcknowledged(path string, acknowledged []string) bool {
+	for _, a := range acknowledged {
+		if path == a || strings.HasPrefix(path, a+".") || strings.HasPrefix(path, a+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare the names and the versions of two definitions
+func diffDefinitions(oldXRD, newXRD *crossplanev1.CompositeResourceDefinition) ([]apiChange, error) {
+	changes := []apiChange{}
+	if oldXRD.Spec.Group != newXRD.Spec.Group {
+		changes = append(changes, apiChange{path: "group", kind: apiChanged, message: fmt.Sprintf("changed from %s to %s", oldXRD.Spec.Group, newXRD.Spec.Group), breaking: true})
+	}
+	if oldXRD.Spec.Names.Kind != newXRD.Spec.Names.Kind {
+		changes = append(changes, apiChange{path: "names.kind", kind: apiChanged, message: fmt.Sprintf("changed from %s to %s", oldXRD.Spec.Names.Kind, newXRD.Spec.Names.Kind), breaking: true})
+	}
+	if oldXRD.Spec.ClaimNames != nil && (newXRD.Spec.ClaimNames == nil || oldXRD.Spec.ClaimNames.Kind != newXRD.Spec.ClaimNames.Kind) {
+		changes = append(changes, apiChange{path: "claimNames.kind", kind: apiRemoved, message: fmt.Sprintf("claim %s removed", oldXRD.Spec.ClaimNames.Kind), breaking: true})
+	}
+
+	newVersions := map[string]crossplanev1.CompositeResourceDefinitionVersion{}
+	for _, v := range newXRD.Spec.Versions {
+		newVersions[v.Name] = v
+	}
+	oldVersions := map[string]bool{}
+	for _, oldVersion := range oldXRD.Spec.Versions {
+		oldVersions[oldVersion.Name] = true
+		if !oldVersion.Served {
+			continue
+		}
+		newVersion, ok := newVersions[oldVersion.Name]
+		if !ok {
+			changes = append(changes, apiChange{path: oldVersion.Name, kind: apiRemoved, message: "version removed", breaking: true})
+			continue
+		}
+		if !newVersion.Served {
+			changes = append(changes, apiChange{path: oldVersion.Name, kind: apiRemoved, message: "version no longer served", breaking: true})
+			continue
+		}
+		oldSchema, err := versionSchema(oldVersion)
+		if err != nil {
+			return nil, err
+		}
+		newSchema, err := versionSchema(newVersion)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, diffSchema(oldVersion.Name, oldSchema, newSchema)...)
+	}
+	for _, v := range newXRD.Spec.Versions {
+		if !oldVersions[v.Name] {
+			changes = append(changes, apiChange{path: v.Name, kind: apiAdded, message: "version added"})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes, nil
+}
+
+// Return the schema of a version of a definition
+func versionSchema(v crossplanev1.CompositeResourceDefinitionVersion) (*extv1.JSONSchemaProps, error) {
+	schema := &extv1.JSONSchemaProps{}
+	if v.Schema == nil || len(v.Schema.OpenAPIV3Schema.Raw) == 0 {
+		return schema, nil
+	}
+	if err := json.Unmarshal(v.Schema.OpenAPIV3Schema.Raw, schema); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal schema of version %s", v.Name)
+	}
+	return schema, nil
+}
+
+// Compare two schemas. Removing properties, changing types, adding required
+// properties and restricting enums are breaking, existing claims may no longer
+// be valid.
+func diffSchema(path string, oldSchema, newSchema *extv1.JSONSchemaProps) []apiChange {
+	changes := []apiChange{}
+	if oldSchema.Type != newSchema.Type {
+		return append(changes, apiChange{path: path, kind: apiChanged, message: fmt.Sprintf("type changed from %s to %s", oldSchema.Type, newSchema.Type), breaking: true})
+	}
+	changes = append(changes, diffEnum(path, oldSchema.Enum, newSchema.Enum)...)
+
+	for _, name := range sortedProperties(oldSchema.Properties) {
+		property := path + "." + name
+		oldProperty := oldSchema.Properties[name]
+		newProperty, ok := newSchema.Properties[name]
+		if !ok {
+			changes = append(changes, apiChange{path: property, kind: apiRemoved, message: "property removed", breaking: true})
+			continue
+		}
+		oldRequired, newRequired := listHas(&oldSchema.Required, name), listHas(&newSchema.Required, name)
+		if !oldRequired && newRequired {
+			changes = append(changes, apiChange{path: property, kind: apiChanged, message: "property is now required", breaking: true})
+		} else if oldRequired && !newRequired {
+			changes = append(changes, apiChange{path: property, kind: apiChanged, message: "property is no longer required"})
+		}
+		changes = append(changes, diffSchema(property, &oldProperty, &newProperty)...)
+	}
+	for _, name := range sortedProperties(newSchema.Properties) {
+		if _, ok := oldSchema.Properties[name]; ok {
+			continue
+		}
+		if listHas(&newSchema.Required, name) {
+			changes = append(changes, apiChange{path: path + "." + name, kind: apiAdded, message: "required property added", breaking: true})
+		} else {
+			changes = append(changes, apiChange{path: path + "." + name, kind: apiAdded, message: "property added"})
+		}
+	}
+
+	if oldSchema.Items != nil && oldSchema.Items.Schema != nil && newSchema.Items != nil && newSchema.Items.Schema != nil {
+		changes = append(changes, diffSchema(path+"[*]", oldSchema.Items.Schema, newSchema.Items.Schema)...)
+	}
+	if oldSchema.AdditionalProperties != nil && oldSchema.AdditionalProperties.Schema != nil &&
+		newSchema.AdditionalProperties != nil && newSchema.AdditionalProperties.Schema != nil {
+		changes = append(changes, diffSchema(path+"[*]", oldSchema.AdditionalProperties.Schema, newSchema.AdditionalProperties.Schema)...)
+	}
+	return changes
+}
+
+// Compare the enums of a property, adding an enum or removing values is breaking
+func diffEnum(path string, oldEnum, newEnum []extv1.JSON) []apiChange {
+	if len(newEnum) == 0 {
+		if len(oldEnum) > 0 {
+			return []apiChange{{path: path, kind: apiChanged, message: "enum removed"}}
+		}
+		return nil
+	}
+	if len(oldEnum) == 0 {
+		return []apiChange{{path: path, kind: apiChanged, message: "enum added: " + strings.Join(enumValues(newEnum), ", "), breaking: true}}
+	}
+	oldValues, newValues := enumValues(oldEnum), enumValues(newEnum)
+	removed, added := []string{}, []string{}
+	for _, v := range oldValues {
+		if !listHas(&newValues, v) {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range newValues {
+		if !listHas(&oldValues, v) {
+			added = append(added, v)
+		}
+	}
+	changes := []apiChange{}
+	if len(removed) > 0 {
+		changes = append(changes, apiChange{path: path, kind: apiChanged, message: "enum values removed: " + strings.Join(removed, ", "), breaking: true})
+	}
+	if len(added) > 0 {
+		changes = append(changes, apiChange{path: path, kind: apiChanged, message: "enum values added: " + strings.Join(added, ", ")})
+	}
+	return changes
+}
+
+func enumValues(enum []extv1.JSON) []string {
+	values := []string{}
+	for _, v := range enum {
+		values = append(values, string(v.Raw))
+	}
+	return values
+}
+
+func sortedProperties(properties map[string]extv1.JSONSchemaProps) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
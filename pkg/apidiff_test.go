@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func xrdWithSpec(t *testing.T, version string, spec extv1.JSONSchemaProps) *crossplanev1.CompositeResourceDefinition {
+	t.Helper()
+	raw, err := json.Marshal(extv1.JSONSchemaProps{
+		Properties: map[string]extv1.JSONSchemaProps{"spec": spec},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &crossplanev1.CompositeResourceDefinition{
+		Spec: crossplanev1.CompositeResourceDefinitionSpec{
+			Group: "example.cloud",
+			Names: extv1.CustomResourceDefinitionNames{Kind: "CompositeBucket"},
+			Versions: []crossplanev1.CompositeResourceDefinitionVersion{{
+				Name:   version,
+				Served: true,
+				Schema: &crossplanev1.CompositeResourceValidation{
+					OpenAPIV3Schema: runtime.RawExtension{Raw: raw},
+				},
+			}},
+		},
+	}
+}
+
+func objectWith(required []string, properties map[string]extv1.JSONSchemaProps) extv1.JSONSchemaProps {
+	return extv1.JSONSchemaProps{Type: "object", Required: required, Properties: properties}
+}
+
+func enumOf(values ...string) []extv1.JSON {
+	enum := []extv1.JSON{}
+	for _, v := range values {
+		enum = append(enum, extv1.JSON{Raw: []byte(`"` + v + `"`)})
+	}
+	return enum
+}
+
+func Test_diffDefinitions(t *testing.T) {
+	base := objectWith(nil, map[string]extv1.JSONSchemaProps{
+		"region": {Type: "string"},
+		"acl":    {Type: "string", Enum: enumOf("private", "public-read")},
+		"size":   {Type: "integer"},
+	})
+	tests := []struct {
+		name    string
+		version string
+		spec    extv1.JSONSchemaProps
+		want    []apiChange
+	}{
+		{
+			name:    "Should find no changes",
+			version: "v1alpha1",
+			spec:    base,
+			want:    []apiChange{},
+		},
+		{
+			name:    "Should classify changes of properties",
+			version: "v1alpha1",
+			spec: objectWith([]string{"name"}, map[string]extv1.JSONSchemaProps{
+				"acl":         {Type: "string", Enum: enumOf("private", "authenticated-read")},
+				"size":        {Type: "string"},
+				"name":        {Type: "string"},
+				"description": {Type: "string"},
+			}),
+			want: []apiChange{
+				{path: "v1alpha1.spec.acl", kind: apiChanged, message: `enum values removed: "public-read"`, breaking: true},
+				{path: "v1alpha1.spec.acl", kind: apiChanged, message: `enum values added: "authenticated-read"`},
+				{path: "v1alpha1.spec.description", kind: apiAdded, message: "property added"},
+				{path: "v1alpha1.spec.name", kind: apiAdded, message: "required property added", breaking: true},
+				{path: "v1alpha1.spec.region", kind: apiRemoved, message: "property removed", breaking: true},
+				{path: "v1alpha1.spec.size", kind: apiChanged, message: "type changed from integer to string", breaking: true},
+			},
+		},
+		{
+			name:    "Should find removed versions",
+			version: "v1beta1",
+			spec:    base,
+			want: []apiChange{
+				{path: "v1alpha1", kind: apiRemoved, message: "version removed", breaking: true},
+				{path: "v1beta1", kind: apiAdded, message: "version added"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffDefinitions(xrdWithSpec(t, "v1alpha1", base), xrdWithSpec(t, tt.version, tt.spec))
+			if err != nil {
+				t.Fatalf("diffDefinitions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffDefinitions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_printAPIChanges(t *testing.T) {
+	changes := []apiChange{
+		{path: "v1alpha1.spec.forProvider.region", message: "property removed", breaking: true},
+		{path: "v1alpha1.spec.forProvider.acl", kind: apiAdded, message: "property added"},
+	}
+	tests := []struct {
+		name         string
+		acknowledged []string
+		want         bool
+		wantOutput   string
+	}{
+		{
+			name:       "Should fail on breaking changes",
+			want:       false,
+			wantOutput: "  breaking v1alpha1.spec.forProvider.region: property removed",
+		},
+		{
+			name:         "Should accept acknowledged breaking changes",
+			acknowledged: []string{"v1alpha1.spec.forProvider"},
+			want:         true,
+			wantOutput:   "  breaking (acknowledged) v1alpha1.spec.forProvider.region: property removed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if got := printAPIChanges(&out, "definition.yaml", changes, tt.acknowledged); got != tt.want {
+				t.Errorf("printAPIChanges() = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) || !strings.Contains(out.String(), "  non-breaking v1alpha1.spec.forProvider.acl: property added") {
+				t.Errorf("printAPIChanges() output = %s, want %s", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
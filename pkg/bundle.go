@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// bundleCRD is a crd contained in a bundle of crds, e.g. a provider package,
+// a multi document yaml file or a directory of crd files
+type bundleCRD struct {
+	Group  string
+	Kind   string
+	Plural string
+	Raw    []byte
+}
+
+// bundleCache keeps the crds of already read bundles, so each bundle is
+// only read once for all generators using it. Concurrent reads of the same
+// bundle wait for the first one to finish, other bundles are read in parallel.
+type bundleCache struct {
+	mu      sync.Mutex
+	bundles map[string]*bundleRead
+}
+
+type bundleRead struct {
+	done chan struct{}
+	crds []bundleCRD
+	err  error
+}
+
+func newBundleCache() *bundleCache {
+	return &bundleCache{bundles: map[string]*bundleRead{}}
+}
+
+// Load returns the crds of the bundle stored under key, read is only called
+// if the bundle was not read before. A nil cache always reads the bundle.
+func (c *bundleCache) Load(key string, read func() ([]bundleCRD, error)) ([]bundleCRD, error) {
+	if c == nil {
+		return read()
+	}
+	c.mu.Lock()
+	if r, ok := c.bundles[key]; ok {
+		c.mu.Unlock()
+		<-r.done
+		return r.crds, r.err
+	}
+	r := &bundleRead{done: make(chan struct{})}
+	c.bundles[key] = r
+	c.mu.Unlock()
+
+	r.crds, r.err = read()
+	if r.err != nil {
+		// errors are not cached, the next call reads the bundle again
+		c.mu.Lock()
+		delete(c.bundles, key)
+		c.mu.Unlock()
+	}
+	close(r.done)
+	return r.crds, r.err
+}
+
+// Select the crd from the bundle. If a kind is given, the crd is selected by
+// kind and (if given) group, otherwise the group and the plural are derived
+// from the crd file name.
+func selectCRD(crds []bundleCRD, crd t.CrdConfig, location string) ([]byte, error) {
+	if crd.Kind == "" {
+		group, plural := groupAndPluralFromFile(crd.File)
+		files := []string{}
+		for _, c := range crds {
+			if c.Group == group && c.Plural == plural {
+				return c.Raw, nil
+			}
+			files = append(files, c.Group+"_"+c.Plural+".yaml")
+		}
+		return nil, errors.Errorf("Could not find CRD %s in %s%s\n", crd.File, location, didYouMean(suggest(crd.File, files)))
+	}
+
+	matches := []bundleCRD{}
+	kinds := []string{}
+	groups := []string{}
+	for _, c := range crds {
+		if c.Kind == crd.Kind {
+			if crd.Group == "" || c.Group == crd.Group {
+				matches = append(matches, c)
+			} else {
+				groups = append(groups, c.Group)
+			}
+		}
+		if crd.Group == "" || c.Group == crd.Group {
+			kinds = append(kinds, c.Kind)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0].Raw, nil
+	case len(matches) > 1:
+		candidates := []string{}
+		for _, m := range matches {
+			candidates = append(candidates, m.Group)
+		}
+		sort.Strings(candidates)
+		return nil, errors.Errorf("Kind %s is ambiguous in %s, set provider.crd.group to one of %s\n", crd.Kind, location, strings.Join(candidates, ", "))
+	case len(groups) > 0:
+		sort.Strings(groups)
+		return nil, errors.Errorf("Could not find kind %s of group %s in %s, the kind exists in group %s\n", crd.Kind, crd.Group, location, strings.Join(groups, ", "))
+	}
+	if crd.Group != "" && len(kinds) == 0 {
+		allGroups := []string{}
+		for _, c := range crds {
+			allGroups = append(allGroups, c.Group)
+		}
+		return nil, errors.Errorf("Could not find group %s in %s%s\n", crd.Group, location, didYouMean(suggest(crd.Group, allGroups)))
+	}
+	return nil, errors.Errorf("Could not find kind %s in %s%s\n", crd.Kind, location, didYouMean(suggest(crd.Kind, kinds)))
+}
+
+// Derive the group and the plural of a crd from a file name like
+// s3.aws.crossplane.io_buckets.yaml
+func groupAndPluralFromFile(file string) (string, string) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	group, plural, _ := strings.Cut(name, "_")
+	return group, plural
+}
+
+// Possible plurals of a kind, used to find the crd file of a kind
+func pluralCandidates(kind string) []string {
+	lower := strings.ToLower(kind)
+	candidates := []string{lower + "s", lower + "es"}
+	if strings.HasSuffix(lower, "y") {
+		candidates = append(candidates, strings.TrimSuffix(lower, "y")+"ies")
+	}
+	return candidates
+}
+
+// Split a multi document yaml stream and return all crds contained
+func parseCRDDocuments(stream []byte) ([]bundleCRD, error) {
+	crds := []bundleCRD{}
+	for _, doc := range splitYAMLDocuments(stream) {
+		var object struct {
+			Kind string `json:"kind"`
+			Spec struct {
+				Group string `json:"group"`
+				Names struct {
+					Kind   string `json:"kind"`
+					Plural string `json:"plural"`
+				} `json:"names"`
+			} `json:"spec"`
+		}
+		if err := yaml.Unmarshal(doc, &object); err != nil {
+			return nil, err
+		}
+		if object.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		crds = append(crds, bundleCRD{
+			Group:  object.Spec.Group,
+			Kind:   object.Spec.Names.Kind,
+			Plural: object.Spec.Names.Plural,
+			Raw:    doc,
+		})
+	}
+	return crds, nil
+}
+
+// Read all crds of the yaml files in dir and its subdirectories
+func readCRDDirectory(dir string) ([]bundleCRD, error) {
+	crds := []bundleCRD{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fileCRDs, err := parseCRDDocuments(content)
+		if err != nil {
+			return errors.Wrapf(err, "cannot parse %s", path)
+		}
+		crds = append(crds, fileCRDs...)
+		return nil
+	})
+	return crds, err
+}
+
+// Parse the index of the crd files of a provider. The index is either a json
+// array of file names, a json array of objects with a name property (like
+// the response of the GitHub contents api) or a list of file names, one per line.
+func parseCRDIndex(index []byte) []string {
+	files := []string{}
+	var entries []interface{}
+	if err := json.Unmarshal(index, &entries); err == nil {
+		for _, e := range entries {
+			switch entry := e.(type) {
+			case string:
+				files = append(files, entry)
+			case map[string]interface{}:
+				if name, ok := entry["name"].(string); ok {
+					files = append(files, name)
+				}
+			}
+		}
+	} else {
+		for _, line := range strings.Split(string(index), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, line)
+			}
+		}
+	}
+	result := []string{}
+	for _, f := range files {
+		if ext := filepath.Ext(f); ext == ".yaml" || ext == ".yml" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// Find the file of the crd with the given kind and group in the index
+func findInIndex(files []string, crd t.CrdConfig, location string) (string, error) {
+	plurals := pluralCandidates(crd.Kind)
+	matches := []string{}
+	candidates := []string{}
+	for _, f := range files {
+		group, plural := groupAndPluralFromFile(f)
+		if crd.Group != "" && group != crd.Group {
+			continue
+		}
+		candidates = append(candidates, plural)
+		if listHas(&plurals, plural) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return "", errors.Errorf("Could not find a crd file for kind %s in %s%s\n", crd.Kind, location, didYouMean(suggest(plurals[0], candidates)))
+	}
+	sort.Strings(matches)
+	return "", errors.Errorf("Kind %s is ambiguous in %s, set provider.crd.group or provider.crd.file to one of %s\n", crd.Kind, location, strings.Join(matches, ", "))
+}
+
+// Check that the fetched crd has the requested kind
+func checkCRDKind(content []byte, crd t.CrdConfig, location string) error {
+	crds, err := parseCRDDocuments(content)
+	if err != nil {
+		return err
+	}
+	if _, err := selectCRD(crds, crd, location); err != nil {
+		return errors.Errorf("CRD %s does not contain kind %s\n", location, crd.Kind)
+	}
+	return nil
+}
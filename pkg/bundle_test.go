@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+)
+
+func Test_selectCRD(t *testing.T) {
+	crds := []bundleCRD{
+		{Group: "s3.aws.upbound.io", Kind: "Bucket", Plural: "buckets", Raw: []byte("upbound-bucket")},
+		{Group: "s3.aws.upbound.io", Kind: "BucketPolicy", Plural: "bucketpolicies", Raw: []byte("upbound-bucketpolicy")},
+		{Group: "s3.aws.crossplane.io", Kind: "Bucket", Plural: "buckets", Raw: []byte("contrib-bucket")},
+		{Group: "iam.aws.crossplane.io", Kind: "Role", Plural: "roles", Raw: []byte("contrib-role")},
+	}
+	tests := []struct {
+		name    string
+		crd     xtype.CrdConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name: "Should select by kind and group",
+			crd:  xtype.CrdConfig{Group: "s3.aws.crossplane.io", Kind: "Bucket"},
+			want: "contrib-bucket",
+		},
+		{
+			name: "Should select by unique kind without group",
+			crd:  xtype.CrdConfig{Kind: "Role"},
+			want: "contrib-role",
+		},
+		{
+			name: "Should select by file name",
+			crd:  xtype.CrdConfig{File: "s3.aws.upbound.io_bucketpolicies.yaml"},
+			want: "upbound-bucketpolicy",
+		},
+		{
+			name:    "Should fail for ambiguous kinds",
+			crd:     xtype.CrdConfig{Kind: "Bucket"},
+			wantErr: "set provider.crd.group to one of s3.aws.crossplane.io, s3.aws.upbound.io",
+		},
+		{
+			name:    "Should suggest similar kinds",
+			crd:     xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "BucketPolicys"},
+			wantErr: "did you mean BucketPolicy?",
+		},
+		{
+			name:    "Should name the groups of a kind",
+			crd:     xtype.CrdConfig{Group: "iam.aws.crossplane.io", Kind: "BucketPolicy"},
+			wantErr: "the kind exists in group s3.aws.upbound.io",
+		},
+		{
+			name:    "Should suggest similar groups",
+			crd:     xtype.CrdConfig{Group: "s3.aws.upbound.com", Kind: "Object"},
+			wantErr: "did you mean s3.aws.upbound.io?",
+		},
+		{
+			name:    "Should suggest similar file names",
+			crd:     xtype.CrdConfig{File: "s3.aws.upbound.io_bucket.yaml"},
+			wantErr: "did you mean s3.aws.upbound.io_buckets.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectCRD(crds, tt.crd, "test")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("selectCRD() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("selectCRD() error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("selectCRD() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findInIndex(t *testing.T) {
+	index := []byte(`[
+		{"name": "iam.aws.crossplane.io_policies.yaml", "type": "file"},
+		{"name": "iam.aws.crossplane.io_roles.yaml", "type": "file"},
+		{"name": "s3.aws.crossplane.io_buckets.yaml", "type": "file"},
+		{"name": "storage.gcp.crossplane.io_buckets.yaml", "type": "file"},
+		{"name": "README.md", "type": "file"}
+	]`)
+	files := parseCRDIndex(index)
+	if len(files) != 4 {
+		t.Fatalf("parseCRDIndex() = %v, want 4 crd files", files)
+	}
+	tests := []struct {
+		name    string
+		crd     xtype.CrdConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name: "Should find kinds with plural ies",
+			crd:  xtype.CrdConfig{Kind: "Policy"},
+			want: "iam.aws.crossplane.io_policies.yaml",
+		},
+		{
+			name: "Should find kind in group",
+			crd:  xtype.CrdConfig{Group: "s3.aws.crossplane.io", Kind: "Bucket"},
+			want: "s3.aws.crossplane.io_buckets.yaml",
+		},
+		{
+			name:    "Should fail for ambiguous kinds",
+			crd:     xtype.CrdConfig{Kind: "Bucket"},
+			wantErr: "s3.aws.crossplane.io_buckets.yaml, storage.gcp.crossplane.io_buckets.yaml",
+		},
+		{
+			name:    "Should suggest similar kinds",
+			crd:     xtype.CrdConfig{Group: "iam.aws.crossplane.io", Kind: "Rle"},
+			wantErr: "did you mean roles?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findInIndex(files, tt.crd, "test")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("findInIndex() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("findInIndex() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_localSource_FetchKind(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string][]byte{
+		"upbound/bundle.yaml": []byte(testPackageStream),
+		"notes.txt":           []byte("not a crd"),
+	})
+	s := &localSource{path: tempDir, bundles: newBundleCache()}
+	got, err := s.Fetch(xtype.CrdConfig{Kind: "BucketPolicy"})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if !strings.Contains(string(got), "name: bucketpolicies.s3.aws.upbound.io") {
+		t.Errorf("Fetch() = %s, want crd bucketpolicies.s3.aws.upbound.io", got)
+	}
+
+	bundleFile := filepath.Join(tempDir, "upbound", "bundle.yaml")
+	if err := os.Remove(bundleFile); err != nil {
+		t.Fatal(err)
+	}
+	// the directory is only read once
+	if _, err := s.Fetch(xtype.CrdConfig{Kind: "Bucket"}); err != nil {
+		t.Errorf("Fetch() error = %v", err)
+	}
+}
+
+func Test_bundleCache_Load(t *testing.T) {
+	c := newBundleCache()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	reads := map[string]int{}
+	read := func(key string) func() ([]bundleCRD, error) {
+		return func() ([]bundleCRD, error) {
+			mu.Lock()
+			reads[key]++
+			mu.Unlock()
+			if key == "slow" {
+				<-release
+			}
+			return []bundleCRD{{Kind: key}}, nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crds, err := c.Load("slow", read("slow"))
+			if err != nil || len(crds) != 1 || crds[0].Kind != "slow" {
+				t.Errorf("Load() = %v, %v, want crd slow", crds, err)
+			}
+		}()
+	}
+	// another bundle is read while the slow bundle is still being read
+	if _, err := c.Load("fast", read("fast")); err != nil {
+		t.Errorf("Load() error = %v", err)
+	}
+	close(release)
+	wg.Wait()
+	if reads["slow"] != 1 || reads["fast"] != 1 {
+		t.Errorf("Load() reads = %v, want each bundle read once", reads)
+	}
+
+	// errors are not cached
+	failed := errors.New("cannot read")
+	if _, err := c.Load("failing", func() ([]bundleCRD, error) { return nil, failed }); err != failed {
+		t.Errorf("Load() error = %v, want %v", err, failed)
+	}
+	if _, err := c.Load("failing", read("failing")); err != nil {
+		t.Errorf("Load() error = %v, want the bundle to be read again", err)
+	}
+}
+
+func Test_suggest(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		candidates []string
+		want       []string
+	}{
+		{
+			name:       "Should suggest similar values",
+			value:      "patchAndTransformFunction",
+			candidates: []string{"patchAndTransfromFunction", "autoReadyFunction", "usePipeline"},
+			want:       []string{"patchAndTransfromFunction"},
+		},
+		{
+			name:       "Should order equal distances alphabetically",
+			value:      "bevore",
+			candidates: []string{"bevor", "before", "input"},
+			want:       []string{"before", "bevor"},
+		},
+		{
+			name:       "Should ignore case",
+			value:      "Uidfieldpath",
+			candidates: []string{"uidFieldPath"},
+			want:       []string{"uidFieldPath"},
+		},
+		{
+			name:       "Should not suggest different values",
+			value:      "tags",
+			candidates: []string{"labels", "provider"},
+			want:       []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggest(tt.value, tt.candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
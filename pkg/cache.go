@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// crdCache is a content addressed on-disk cache for downloaded crd files.
+// The content of each crd is stored once under its sha256 checksum, a
+// reference per provider, version and file points to the checksum:
+//
+//	<dir>/sha256/<checksum>
+//	<dir>/refs/<provider>/<version>/<file>
+type crdCache struct {
+	dir string
+}
+
+// Default location of the crd cache inside the cache directory of the user
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "x-generation", "crds")
+	}
+	return filepath.Join(dir, "x-generation", "crds")
+}
+
+// Calculate the hex encoded sha256 checksum of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *crdCache) blobPath(sum string) string {
+	return filepath.Join(c.dir, "sha256", sum)
+}
+
+func (c *crdCache) refPath(provider, version, file string) string {
+	return filepath.Join(c.dir, "refs", provider, version, file)
+}
+
+// Get the content stored under the given checksum, the content is only
+// returned if it still matches the checksum
+func (c *crdCache) Get(sum string) ([]byte, bool) {
+	content, err := os.ReadFile(c.blobPath(sum))
+	if err != nil || checksum(content) != sum {
+		return nil, false
+	}
+	return content, true
+}
+
+// Lookup the checksum of the crd file of the given provider version
+func (c *crdCache) Lookup(provider, version, file string) (string, bool) {
+	ref, err := os.ReadFile(c.refPath(provider, version, file))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(ref)), true
+}
+
+// Put stores the content of the crd file of the given provider version and
+// returns its checksum
+func (c *crdCache) Put(provider, version, file string, content []byte) (string, error) {
+	sum := checksum(content)
+	if err := writeFileAtomic(c.blobPath(sum), content); err != nil {
+		return "", errors.Wrap(err, "cannot write crd to cache")
+	}
+	if err := writeFileAtomic(c.refPath(provider, version, file), []byte(sum+"\n")); err != nil {
+		return "", errors.Wrap(err, "cannot write crd reference to cache")
+	}
+	return sum, nil
+}
+
+// Write the file using a temporary file in the same directory, so concurrent
+// readers never see partially written content
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Return the backend the resources of the compositions are rendered with, the
+// generate.yaml overrides the global configuration
+func (g *Generator) compositionBackend(generatorConfig *t.GeneratorConfig) t.CompositionBackend {
+	if g.CompositionBackend != nil {
+		return *g.CompositionBackend
+	}
+	if generatorConfig.CompositionBackend != nil {
+		return *generatorConfig.CompositionBackend
+	}
+	return t.CompositionBackendPatchAndTransform
+}
+
+// Check the backend is known and, unless it is patch-and-transform, the
+// pipeline mode is used
+func (g *Generator) checkCompositionBackend(generatorConfig *t.GeneratorConfig) error {
+	switch backend := g.compositionBackend(generatorConfig); backend {
+	case t.CompositionBackendPatchAndTransform:
+		return nil
+	case t.CompositionBackendGoTemplating, t.CompositionBackendKCL:
+		if !g.usePipeline(generatorConfig) {
+			return errors.Errorf("compositionBackend %s requires usePipeline", backend)
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown compositionBackend %s", backend)
+	}
+}
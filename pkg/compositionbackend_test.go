@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	xcomposed "github.com/crossplane-contrib/x-generation/pkg/patchandtransform/composed"
+	xcomposite "github.com/crossplane-contrib/x-generation/pkg/patchandtransform/composite"
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	sprig "github.com/go-task/slim-sprig"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerator_RenderCompositionBackend(t *testing.T) {
+	goTemplating, patchAndTransform, kcl := xtype.CompositionBackendGoTemplating, xtype.CompositionBackendPatchAndTransform, xtype.CompositionBackendKCL
+	tests := []struct {
+		name      string
+		global    *xtype.CompositionBackend
+		generator *xtype.CompositionBackend
+		want      string
+	}{
+		{
+			name: "Should use patch-and-transform by default",
+			want: "function-patch-and-transform",
+		},
+		{
+			name:   "Should use go-templating from the global configuration",
+			global: &goTemplating,
+			want:   "function-go-templating",
+		},
+		{
+			name:      "Should prefer the generator over the global configuration",
+			global:    &goTemplating,
+			generator: &patchAndTransform,
+			want:      "function-patch-and-transform",
+		},
+		{
+			name:      "Should use kcl from the generator",
+			generator: &kcl,
+			want:      "function-kcl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, t.TempDir(), true)
+			g.CompositionBackend = tt.generator
+			gConfig := xtype.GeneratorConfig{
+				CompositionIdentifier: "example.cloud",
+				CompositionBackend:    tt.global,
+			}
+			if err := g.CheckConfig(&gConfig); err != nil {
+				t.Fatalf("CheckConfig() error = %v", err)
+			}
+			cwd, _ := os.Getwd()
+			files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			functions, err := pipelineFunctions(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(functions) != 1 || functions[0] != tt.want {
+				t.Errorf("Render() pipeline functions = %v, want %s", functions, tt.want)
+			}
+			for _, f := range files {
+				if !strings.HasPrefix(filepath.Base(f.path), "composition") {
+					continue
+				}
+				var composition crossplanev1.Composition
+				if err := yaml.Unmarshal(f.content, &composition); err != nil {
+					t.Fatal(err)
+				}
+				// the docs of go-templating and kcl compositions have no patches
+				if err := parameterPatches(&composition, map[string][]string{}); err != nil {
+					t.Errorf("parameterPatches() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerator_CheckCompositionBackend(t *testing.T) {
+	goTemplating, unknown := xtype.CompositionBackendGoTemplating, xtype.CompositionBackend("cue")
+	g := newTestGenerator(t, t.TempDir(), false)
+	g.CompositionBackend = &goTemplating
+	if err := g.CheckConfig(&xtype.GeneratorConfig{}); err == nil || err.Error() != "compositionBackend go-templating requires usePipeline" {
+		t.Errorf("CheckConfig() error = %v, want usePipeline required", err)
+	}
+	g.CompositionBackend = &unknown
+	if err := g.CheckConfig(&xtype.GeneratorConfig{}); err == nil || err.Error() != "unknown compositionBackend cue" {
+		t.Errorf("CheckConfig() error = %v, want unknown compositionBackend", err)
+	}
+}
+
+// TestGenerator_RenderCompositionBackends renders the composition of the same
+// generator with patch-and-transform and each other backend like their
+// functions and compares the managed resources and the composite
+func TestGenerator_RenderCompositionBackends(t *testing.T) {
+	claim := `
+apiVersion: example.cloud/testv1
+kind: Bucket
+metadata:
+  name: example
+  namespace: team
+  labels:
+    example.cloud/team: platform
+spec:
+  location: eu-central-1
+  forProvider:
+    acl: public
+    versioning: true
+`
+	observed := `
+apiVersion: s3.aws.crossplane.io/testv1
+kind: Bucket
+metadata:
+  name: example-x7k2p
+  annotations:
+    crossplane.io/composition-resource-name: Bucket
+    crossplane.io/external-name: example-x7k2p
+status:
+  atProvider:
+    arn: arn:aws:s3:::example-x7k2p
+`
+	tests := []struct {
+		name      string
+		claim     string
+		observed  string
+		resources int
+	}{
+		{
+			name:      "Should rename fields, map enums and propagate labels to tags",
+			claim:     claim,
+			resources: 1,
+		},
+		{
+			name:      "Should patch the status of the composite from the observed resource",
+			claim:     claim,
+			observed:  observed,
+			resources: 1,
+		},
+		{
+			name:  "Should not render resources without the label of a required tag",
+			claim: strings.Replace(claim, "example.cloud/team: platform", "example.cloud/owner: platform", 1),
+		},
+		{
+			name:      "Should pass values of the enum that are not mapped",
+			claim:     strings.Replace(claim, "acl: public", "acl: private", 1),
+			resources: 1,
+		},
+		{
+			name:      "Should patch fields over the values of overrideFields",
+			claim:     strings.Replace(claim, "versioning: true", "storageClass: GLACIER", 1),
+			resources: 1,
+		},
+		{
+			name:      "Should keep the defaults of the base for missing fields",
+			claim:     strings.Replace(claim, "  location: eu-central-1\n", "", 1),
+			resources: 1,
+		},
+	}
+	backends := []struct {
+		backend xtype.CompositionBackend
+		render  func(t *testing.T, composition *crossplanev1.Composition, composite *unstructured.Unstructured, observed []*unstructured.Unstructured) []map[string]interface{}
+		// annotations of the function removed from the managed resources,
+		// the first one is the name of the resource
+		annotations []string
+	}{
+		{
+			backend:     xtype.CompositionBackendGoTemplating,
+			render:      renderGoTemplating,
+			annotations: []string{"gotemplating.fn.crossplane.io/composition-resource-name", "gotemplating.fn.crossplane.io/ready"},
+		},
+		{
+			backend:     xtype.CompositionBackendKCL,
+			render:      renderKCL,
+			annotations: []string{"krm.kcl.dev/composition-resource-name", "krm.kcl.dev/ready"},
+		},
+	}
+	patchAndTransform := renderBucketComposition(t, xtype.CompositionBackendPatchAndTransform)
+	for _, b := range backends {
+		composition := renderBucketComposition(t, b.backend)
+		for _, tt := range tests {
+			t.Run(string(b.backend)+"/"+tt.name, func(t *testing.T) {
+				observed := []*unstructured.Unstructured{}
+				if tt.observed != "" {
+					observed = append(observed, unstructuredFromYAML(t, tt.observed))
+				}
+				want, err := renderComposition(patchAndTransform, unstructuredFromYAML(t, tt.claim), observed, map[string]interface{}{})
+				if err != nil {
+					t.Fatalf("renderComposition() error = %v", err)
+				}
+				composite := compositeOf(unstructuredFromYAML(t, tt.claim), composition.Spec.CompositeTypeRef)
+				got := desiredState(composite, observed, b.render(t, composition, composite, observed), b.annotations)
+				if len(got.resources) != tt.resources || len(want.resources) != tt.resources {
+					t.Fatalf("%s rendered %d resources, patch-and-transform %d, want %d", b.backend, len(got.resources), len(want.resources), tt.resources)
+				}
+				for i := range want.resources {
+					if !reflect.DeepEqual(got.resources[i].Object, want.resources[i].Object) {
+						g, _ := json.Marshal(got.resources[i].Object)
+						w, _ := json.Marshal(want.resources[i].Object)
+						t.Errorf("%s rendered %s, patch-and-transform %s", b.backend, g, w)
+					}
+				}
+				if !reflect.DeepEqual(got.composite.Object["status"], want.composite.Object["status"]) {
+					t.Errorf("%s rendered composite status %v, patch-and-transform %v", b.backend, got.composite.Object["status"], want.composite.Object["status"])
+				}
+			})
+		}
+	}
+}
+
+// renderBucketComposition returns the composition of a bucket whose location
+// is renamed, whose acl has an enum map, whose versioning is ignored, whose
+// storage class has a default and whose tags are patched from labels
+func renderBucketComposition(t *testing.T, backend xtype.CompositionBackend) *crossplanev1.Composition {
+	t.Helper()
+	g := newTestGenerator(t, t.TempDir(), true)
+	g.crd.Spec.Group = "s3.aws.crossplane.io"
+	g.crd.Spec.Names.Kind = "Bucket"
+	spec := g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	forProvider := spec.Properties["forProvider"]
+	forProvider.Properties["acl"] = extv1.JSONSchemaProps{Type: "string", Enum: enumOf("private", "public-read")}
+	forProvider.Properties["tags"] = arrayOf("key", "value")
+	forProvider.Properties["versioning"] = extv1.JSONSchemaProps{Type: "boolean"}
+	forProvider.Properties["storageClass"] = extv1.JSONSchemaProps{Type: "string"}
+	spec.Properties["forProvider"] = forProvider
+	g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+	status := g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["status"]
+	status.Properties["atProvider"] = extv1.JSONSchemaProps{Type: "object", Properties: map[string]extv1.JSONSchemaProps{"arn": {Type: "string"}}}
+	g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["status"] = status
+	crdSource, err := json.Marshal(g.crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.crdSource = string(crdSource)
+	region := "spec.forProvider.region"
+	g.OverrideFields = []xtype.OverrideField{
+		{Path: "spec.forProvider.versioning", Ignore: true},
+		{Path: "spec.forProvider.storageClass", Value: "STANDARD"},
+	}
+	g.OverrideFieldsInClaim = []xtype.OverrideFieldInClaim{
+		{ClaimPath: "spec.location", ManagedPath: &region},
+		{ClaimPath: "spec.forProvider.acl", OverrideSettings: &xtype.OverrideSettings{Enum: []*xtype.EnumValue{
+			{Value: extv1.JSON{Raw: []byte(`"public"`)}, Type: xtype.EnumValueTypeAdd, MapTo: &extv1.JSON{Raw: []byte(`"public-read"`)}},
+			{Value: extv1.JSON{Raw: []byte(`"public-read"`)}, Type: xtype.EnumValueTypeRemove},
+		}}},
+	}
+	g.Labels = xtype.LocalLabelConfig{LabelConfig: xtype.LabelConfig{FromCRD: []string{"example.cloud/team"}}}
+	g.Tags = xtype.LocalTagConfig{TagConfig: xtype.TagConfig{FromLabels: []string{"example.cloud/team"}}}
+	tags := detectTags(g.crd, "testv1", defaultTagDetectors)
+	g.TagType, g.TagProperty, g.TagKeyField, g.TagValueField = &tags.tagType, &tags.property, &tags.keyField, &tags.valueField
+	g.CompositionBackend = &backend
+
+	gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+	if err := g.CheckConfig(&gConfig); err != nil {
+		t.Fatalf("CheckConfig() error = %v", err)
+	}
+	cwd, _ := os.Getwd()
+	files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+	if err != nil {
+		t.Fatalf("Render() %s error = %v", backend, err)
+	}
+	for _, f := range files {
+		if strings.HasPrefix(filepath.Base(f.path), "composition") {
+			var composition crossplanev1.Composition
+			if err := yaml.Unmarshal(f.content, &composition); err != nil {
+				t.Fatal(err)
+			}
+			return &composition
+		}
+	}
+	t.Fatalf("Render() %s rendered no composition", backend)
+	return nil
+}
+
+// renderGoTemplating executes the template of the go-templating step like
+// function-go-templating, slim-sprig has the functions of sprig used by the
+// template
+func renderGoTemplating(t *testing.T, composition *crossplanev1.Composition, composite *unstructured.Unstructured, observed []*unstructured.Unstructured) []map[string]interface{} {
+	t.Helper()
+	var input struct {
+		Inline struct {
+			Template string `json:"template"`
+		} `json:"inline"`
+	}
+	unmarshalStepInput(t, composition, "function-go-templating", &input)
+	resources := map[string]interface{}{}
+	for _, o := range observed {
+		resources[o.GetAnnotations()[annotationResourceName]] = map[string]interface{}{"resource": o.Object}
+	}
+	data := map[string]interface{}{
+		"observed": map[string]interface{}{
+			"composite": map[string]interface{}{"resource": composite.Object},
+			"resources": resources,
+		},
+	}
+	tmpl, err := template.New("inline").Funcs(sprig.TxtFuncMap()).Parse(input.Inline.Template)
+	if err != nil {
+		t.Fatalf("cannot parse template %s: %v", input.Inline.Template, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatalf("cannot execute template %s: %v", input.Inline.Template, err)
+	}
+	documents := []map[string]interface{}{}
+	for _, document := range strings.Split(out.String(), "\n---\n") {
+		d := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(document), &d); err != nil {
+			t.Fatalf("cannot parse document %s: %v", document, err)
+		}
+		if len(d) > 0 {
+			documents = append(documents, d)
+		}
+	}
+	return documents
+}
+
+// renderKCL runs the program of the kcl step with the kcl cli and the params
+// function-kcl passes. The test is skipped if kcl is not installed, except in
+// CI, which installs kcl.
+func renderKCL(t *testing.T, composition *crossplanev1.Composition, composite *unstructured.Unstructured, observed []*unstructured.Unstructured) []map[string]interface{} {
+	t.Helper()
+	kcl, err := exec.LookPath("kcl")
+	if err != nil {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("kcl must be installed in CI: %v", err)
+		}
+		t.Skip("kcl is not installed")
+	}
+	var input struct {
+		Spec struct {
+			Source string `json:"source"`
+		} `json:"spec"`
+	}
+	unmarshalStepInput(t, composition, "function-kcl", &input)
+	ocds := map[string]interface{}{}
+	for _, o := range observed {
+		ocds[o.GetAnnotations()[annotationResourceName]] = map[string]interface{}{"Resource": o.Object}
+	}
+	params, err := json.Marshal(map[string]interface{}{"oxr": composite.Object, "ocds": ocds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := filepath.Join(t.TempDir(), "main.k")
+	if err := os.WriteFile(program, []byte(input.Spec.Source), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(kcl, "run", program, "-D", "params="+string(params))
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("cannot run %s: %v: %s", input.Spec.Source, err, stderr.String())
+	}
+	var output struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := yaml.Unmarshal(stdout.Bytes(), &output); err != nil {
+		t.Fatalf("cannot parse output %s: %v", stdout.String(), err)
+	}
+	return output.Items
+}
+
+func unmarshalStepInput(t *testing.T, composition *crossplanev1.Composition, function string, input interface{}) {
+	t.Helper()
+	for _, step := range composition.Spec.Pipeline {
+		if step.FunctionRef.Name == function {
+			if err := json.Unmarshal(step.Input.Raw, input); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("composition has no step of %s", function)
+}
+
+// desiredState returns the managed resources and the composite of the
+// documents rendered by a function, the annotations of the function are
+// removed and the metadata crossplane sets on composed resources is added
+func desiredState(composite *unstructured.Unstructured, observed []*unstructured.Unstructured, documents []map[string]interface{}, annotations []string) *renderResult {
+	r := &renderer{composite: &xcomposite.Unstructured{Unstructured: *composite}, observed: map[string]*xcomposed.Unstructured{}}
+	for _, o := range observed {
+		r.observed[o.GetAnnotations()[annotationResourceName]] = &xcomposed.Unstructured{Unstructured: *o}
+	}
+	result := &renderResult{composite: composite.DeepCopy(), resources: []*unstructured.Unstructured{}}
+	for _, document := range documents {
+		o := &unstructured.Unstructured{Object: document}
+		switch o.GetKind() {
+		case "CompositeConnectionDetails":
+		case composite.GetKind():
+			if status, ok := o.Object["status"]; ok {
+				result.composite.Object["status"] = status
+			}
+		default:
+			resourceAnnotations := o.GetAnnotations()
+			name := resourceAnnotations[annotations[0]]
+			for _, a := range annotations {
+				delete(resourceAnnotations, a)
+			}
+			o.SetAnnotations(resourceAnnotations)
+			r.setMetadata(o, name)
+			result.resources = append(result.resources, o)
+		}
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/crossplane-contrib/x-generation/pkg/schema"
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	generateSchemaFile = "generate.schema.json"
+	configSchemaFile   = "generator-config.schema.json"
+	// location the schemas are published at
+	schemaBaseURL = "https://raw.githubusercontent.com/crossplane-contrib/x-generation/main/schemas/"
+)
+
+// Return the schema of the config file type v, either *Generator or *t.GeneratorConfig
+func configSchema(v interface{}) *schema.Schema {
+	r := &schema.Reflector{
+		Enums: map[reflect.Type][]string{
+			reflect.TypeOf(t.GlobalHandlingType("")):   {string(appendGlobal), string(replaceGlobal)},
+			reflect.TypeOf(t.EnumValueType("")):        {string(t.EnumValueTypeAdd), string(t.EnumValueTypeMapTo), string(t.EnumValueTypeRemove)},
+			reflect.TypeOf(t.InitProviderHandling("")): {string(t.InitProviderExpose), string(t.InitProviderMerge), string(t.InitProviderHide)},
+			reflect.TypeOf(t.CrossplaneVersion("")):    {string(t.CrossplaneV1), string(t.CrossplaneV2)},
+			reflect.TypeOf(t.CompositionBackend("")):   {string(t.CompositionBackendPatchAndTransform), string(t.CompositionBackendGoTemplating), string(t.CompositionBackendKCL)},
+		},
+	}
+	switch v.(type) {
+	case *Generator:
+		s := r.Reflect(reflect.TypeOf(v), "generate.yaml")
+		s.ID = schemaBaseURL + generateSchemaFile
+		return s
+	case *t.GeneratorConfig:
+		s := r.Reflect(reflect.TypeOf(v), "generator-config.yaml")
+		s.ID = schemaBaseURL + configSchemaFile
+		return s
+	}
+	return r.Reflect(reflect.TypeOf(v), "")
+}
+
+// The schemas of all config files by the name of the schema file
+func configSchemas() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		generateSchemaFile: configSchema(&Generator{}),
+		configSchemaFile:   configSchema(&t.GeneratorConfig{}),
+	}
+}
+
+func marshalSchema(s *schema.Schema) ([]byte, error) {
+	j, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(j, '\n'), nil
+}
+
+// Run the schema subcommand: print the schema of generate.yaml or
+// generator-config.yaml, or write the schemas of both to a directory
+func runSchema(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(out)
+	outputPath := fs.String("outputPath", "", "directory the schema files "+generateSchemaFile+" and "+configSchemaFile+" are written to")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: x-generation schema [--outputPath dir] [generate|config]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	schemas := configSchemas()
+	names := []string{}
+	switch fs.Arg(0) {
+	case "generate":
+		names = append(names, generateSchemaFile)
+	case "config":
+		names = append(names, configSchemaFile)
+	case "":
+		if *outputPath == "" {
+			fs.Usage()
+			return errors.New("either the schema to print or --outputPath must be given")
+		}
+		for name := range schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	default:
+		fs.Usage()
+		return errors.Errorf("unknown schema %s, must be generate or config", fs.Arg(0))
+	}
+
+	for _, name := range names {
+		content, err := marshalSchema(schemas[name])
+		if err != nil {
+			return err
+		}
+		if *outputPath == "" {
+			out.Write(content)
+			continue
+		}
+		if err := os.MkdirAll(*outputPath, 0755); err != nil {
+			return err
+		}
+		path := filepath.Join(*outputPath, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return errors.Wrapf(err, "cannot write %s", path)
+		}
+		fmt.Fprintf(out, "Wrote %s\n", path)
+	}
+	return nil
+}
+
+// Check if the first argument is a subcommand and run it, other positional
+// arguments like the directory of `go run ./pkg .` are left to the generator
+func runSubcommand(args []string, out io.Writer) (bool, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false, nil
+	}
+	switch args[0] {
+	case "schema":
+		return true, runSchema(args[1:], out)
+	case "diff-api":
+		return true, runDiffAPI(args[1:], out)
+	case "upgrade-report":
+		return true, runUpgradeReport(args[1:], out)
+	case "render":
+		return true, runRender(args[1:], out)
+	case "docs":
+		return true, runDocs(args[1:], out)
+	}
+	return false, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_configSchemasUpToDate(t *testing.T) {
+	for name, s := range configSchemas() {
+		t.Run(name, func(t *testing.T) {
+			want, err := marshalSchema(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join("..", "schemas", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("schemas/%s is out of date, run make generate-schemas (-want +got):\n%s", name, diff)
+			}
+		})
+	}
+}
+
+func Test_runSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Should print the schema of generate.yaml",
+			args: []string{"generate"},
+			want: generateSchemaFile,
+		},
+		{
+			name: "Should print the schema of generator-config.yaml",
+			args: []string{"config"},
+			want: configSchemaFile,
+		},
+		{
+			name:    "Should fail for unknown schemas",
+			args:    []string{"composition"},
+			wantErr: true,
+		},
+		{
+			name:    "Should fail without schema or outputPath",
+			args:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := runSchema(tt.args, out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want, err := marshalSchema(configSchemas()[tt.want])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("runSchema() printed %s, want %s", out.String(), want)
+			}
+		})
+	}
+}
+
+func Test_runSchemaOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := runSchema([]string{"--outputPath", dir}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("runSchema() error = %v", err)
+	}
+	for name := range configSchemas() {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("runSchema() did not write %s: %v", name, err)
+		}
+	}
+}
+
+func Test_runSubcommand(t *testing.T) {
+	for _, args := range [][]string{{}, {"--check"}, {"."}} {
+		if ok, err := runSubcommand(args, &bytes.Buffer{}); ok || err != nil {
+			t.Errorf("runSubcommand(%v) = %v, %v, want the generator to run", args, ok, err)
+		}
+	}
+	if ok, err := runSubcommand([]string{"schema", "generate"}, &bytes.Buffer{}); !ok || err != nil {
+		t.Errorf("runSubcommand(schema) = %v, %v, want the schema command to run", ok, err)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	defaultPackageRegistry = "xpkg.upbound.io/crossplane-contrib"
+	// version constraint of functions without a configured version
+	anyPackageVersion = ">=v0.0.0-0"
+)
+
+// packageDependencies collects the providers and functions used by the
+// generated compositions of all generators
+type packageDependencies struct {
+	mu sync.Mutex
+	// the versions of the providers by their name
+	providers map[string][]string
+	functions map[string]bool
+}
+
+func newPackageDependencies() *packageDependencies {
+	return &packageDependencies{providers: map[string][]string{}, functions: map[string]bool{}}
+}
+
+// Add the providers of the generator and its resources and the functions of
+// the pipeline steps of the rendered compositions
+func (d *packageDependencies) add(g *Generator, generatorConfig *t.GeneratorConfig, files []generatedFile) error {
+	providers := []t.ProviderConfig{g.Provider}
+	for _, r := range g.Resources {
+		providers = append(providers, g.resourceProvider(r))
+	}
+	functions, err := pipelineFunctions(files)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, provider := range providers {
+		// like the crd source, the version of the global provider is used
+		// if the generator uses the global provider
+		name, version := generatorConfig.Provider.Name, generatorConfig.Provider.Version
+		if provider.Name != "" {
+			name, version = provider.Name, provider.Version
+		}
+		versions := d.providers[name]
+		if !listHas(&versions, version) {
+			d.providers[name] = append(versions, version)
+		}
+	}
+	for _, name := range functions {
+		d.functions[name] = true
+	}
+	return nil
+}
+
+// Return the dependencies of the Configuration package, the providers sorted
+// by name followed by the functions. The package and the version of
+// registered functions are taken from the functions registry.
+func (d *packageDependencies) dependsOn(config *t.ConfigurationConfig, functions map[string]t.Function) []pkgmetav1.Dependency {
+	registry := defaultPackageRegistry
+	if config.Registry != nil {
+		registry = strings.TrimSuffix(*config.Registry, "/")
+	}
+	reference := func(name, defaultVersion string) (string, string) {
+		pkg, version := registry+"/"+name, defaultVersion
+		if f, ok := functions[name]; ok {
+			pkg, version = splitPackageTag(f.Package)
+		}
+		if r, ok := config.Packages[name]; ok {
+			if r.Package != nil {
+				pkg = *r.Package
+			}
+			if r.Version != nil {
+				version = *r.Version
+			}
+		}
+		return pkg, version
+	}
+
+	dependencies := []pkgmetav1.Dependency{}
+	packages := []string{}
+	for _, name := range sortedKeys(d.providers) {
+		pkg, version := reference(name, ">="+lowestVersion(d.providers[name]))
+		if !listHas(&packages, pkg) {
+			packages = append(packages, pkg)
+			dependencies = append(dependencies, pkgmetav1.Dependency{Provider: &pkg, Version: version})
+		}
+	}
+	for _, name := range sortedKeys(d.functions) {
+		pkg, version := reference(name, anyPackageVersion)
+		if !listHas(&packages, pkg) {
+			packages = append(packages, pkg)
+			dependencies = append(dependencies, pkgmetav1.Dependency{Function: &pkg, Version: version})
+		}
+	}
+	return dependencies
+}
+
+// Split a package into the package without tag and a version constraint
+// allowing the tag or newer versions, any version is allowed for digests
+func splitPackageTag(pkg string) (string, string) {
+	if repository, _, ok := strings.Cut(pkg, "@"); ok {
+		return repository, anyPackageVersion
+	}
+	if i := strings.LastIndex(pkg, ":"); i > strings.LastIndex(pkg, "/") {
+		return pkg[:i], ">=" + pkg[i+1:]
+	}
+	return pkg, anyPackageVersion
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Return the lowest of the semantic versions
+func lowestVersion(versions []string) string {
+	lowest := versions[0]
+	for _, v := range versions[1:] {
+		if compareVersions(v, lowest) < 0 {
+			lowest = v
+		}
+	}
+	return lowest
+}
+
+// Compare the major, minor and patch version of two semantic versions,
+// prerelease and build metadata are ignored
+func compareVersions(a, b string) int {
+	parse := func(v string) []int {
+		v = strings.TrimPrefix(v, "v")
+		v, _, _ = strings.Cut(v, "-")
+		v, _, _ = strings.Cut(v, "+")
+		parts := []int{}
+		for _, p := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(p)
+			parts = append(parts, n)
+		}
+		return parts
+	}
+	pa, pb := parse(a), parse(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na = pa[i]
+		}
+		if i < len(pb) {
+			nb = pb[i]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Update the crossplane.yaml of the configuration package and the Function
+// manifests of functionsPath with the dependencies of the generators. The
+// dependencies are only complete if all generators of the global config were
+// processed, so the files are only updated if inputPath contains the
+// directory of the global config file. The result is false if a file is out
+// of date in check mode.
+func updatePackageFiles(args *arguments, generatorConfig *t.GeneratorConfig, dependencies *packageDependencies, out io.Writer) (bool, error) {
+	paths := []string{}
+	if generatorConfig.Configuration != nil {
+		paths = append(paths, generatorConfig.Configuration.Path)
+	}
+	if generatorConfig.FunctionsPath != nil {
+		paths = append(paths, *generatorConfig.FunctionsPath)
+	}
+	if !containsPath(args.inputPath, filepath.Dir(args.configFile)) {
+		fmt.Fprintf(out, "Not updating %s, --inputPath %s does not contain all generators of %s\n", strings.Join(paths, " and "), args.inputPath, args.configFile)
+		return true, nil
+	}
+	upToDate := true
+	if generatorConfig.Configuration != nil {
+		ok, err := updateConfiguration(generatorConfig, dependencies, args.check, out)
+		if err != nil {
+			return false, errors.Wrap(err, "cannot update the configuration package")
+		}
+		upToDate = upToDate && ok
+	}
+	if generatorConfig.FunctionsPath != nil {
+		ok, err := writeFunctions(generatorConfig, dependencies, args.check, out)
+		if err != nil {
+			return false, errors.Wrap(err, "cannot write the functions")
+		}
+		upToDate = upToDate && ok
+	}
+	return upToDate, nil
+}
+
+// Check if path is dir or below dir
+func containsPath(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Set spec.dependsOn of the crossplane.yaml of the Configuration package, a
+// missing file is created. Only spec.dependsOn is replaced, the comments and
+// the order of the other fields of an existing file are kept. In check mode a
+// diff is written to out instead, the result is false if the file is out of
+// date.
+func updateConfiguration(generatorConfig *t.GeneratorConfig, dependencies *packageDependencies, check bool, out io.Writer) (bool, error) {
+	config := generatorConfig.Configuration
+	existing, err := os.ReadFile(config.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(existing, &document); err != nil {
+		return false, errors.Wrapf(err, "cannot unmarshal %s", config.Path)
+	}
+	if document.Kind == 0 {
+		content, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "meta.pkg.crossplane.io/v1",
+			"kind":       "Configuration",
+			"metadata": map[string]interface{}{
+				"name": filepath.Base(filepath.Dir(config.Path)),
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		if err := yamlv3.Unmarshal(content, &document); err != nil {
+			return false, err
+		}
+	}
+	if document.Content[0].Kind != yamlv3.MappingNode {
+		return false, errors.Errorf("%s is not a yaml object", config.Path)
+	}
+
+	dependsOn, err := yaml.Marshal(dependencies.dependsOn(config, generatorConfig.Functions))
+	if err != nil {
+		return false, err
+	}
+	var dependsOnDocument yamlv3.Node
+	if err := yamlv3.Unmarshal(dependsOn, &dependsOnDocument); err != nil {
+		return false, err
+	}
+	spec := mappingValue(document.Content[0], "spec")
+	setMappingValue(spec, "dependsOn", dependsOnDocument.Content[0])
+
+	var content bytes.Buffer
+	encoder := yamlv3.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return false, err
+	}
+	if err := encoder.Close(); err != nil {
+		return false, err
+	}
+	return updateFile(config.Path, content.Bytes(), check, out)
+}
+
+// Return the mapping stored under key in the yaml mapping, a missing or empty
+// value is replaced by an empty mapping
+func mappingValue(mapping *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			if value.Kind != yamlv3.MappingNode {
+				*value = yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+			}
+			return value
+		}
+	}
+	value := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+	setMappingValue(mapping, key, value)
+	return value
+}
+
+// Set the value of key in the yaml mapping, an existing key keeps its position
+// and comments
+func setMappingValue(mapping *yamlv3.Node, key string, value *yamlv3.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// Write content to path unless the existing file has the same documents. In
+// check mode a diff is written to out instead, the result is false if the
+// file is out of date.
+func updateFile(path string, content []byte, check bool, out io.Writer) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && sameDocuments(existing, content) {
+		return true, nil
+	}
+	if check {
+		from := path
+		if err != nil {
+			from = "/dev/null"
+		}
+		fmt.Fprint(out, unifiedDiff(from, path, existing, content))
+		return false, nil
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return false, errors.Wrapf(err, "cannot write %s", path)
+	}
+	fmt.Fprintf(out, "Updated %s\n", path)
+	return true, nil
+}
+
+// Check if the documents of an existing multi document file have the same
+// content as the generated ones
+func sameDocuments(existing, generated []byte) bool {
+	existingDocs, generatedDocs := splitYAMLDocuments(existing), splitYAMLDocuments(generated)
+	if len(existingDocs) != len(generatedDocs) {
+		return false
+	}
+	for i := range existingDocs {
+		if !sameContent(existingDocs[i], generatedDocs[i]) {
+			return false
+		}
+	}
+	return true
+}
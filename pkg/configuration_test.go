@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+)
+
+var pipelineComposition = []byte(`
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  mode: Pipeline
+  pipeline:
+  - step: patch-and-transform
+    functionRef:
+      name: function-patch-and-transform
+  - step: automatically-detect-readiness
+    functionRef:
+      name: function-auto-ready
+`)
+
+func Test_packageDependencies(t *testing.T) {
+	generatorConfig := &xtype.GeneratorConfig{Provider: xtype.GlobalProviderConfig{Name: "provider-aws", Version: "v0.47.0"}}
+	dependencies := newPackageDependencies()
+	g := &Generator{Resources: []xtype.Resource{{Name: "policy", Provider: providerNamed("provider-upjet-aws", "v1.4.0")}}}
+	if err := dependencies.add(g, generatorConfig, []generatedFile{{path: "bucket/composition-example.yaml", content: pipelineComposition}}); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	g = &Generator{Provider: providerNamed("provider-aws", "v0.32.0")}
+	if err := dependencies.add(g, generatorConfig, []generatedFile{{path: "key/definition.yaml", content: []byte("kind: CompositeResourceDefinition")}}); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+
+	registry := "xpkg.upbound.io/example/"
+	familyPackage := "xpkg.upbound.io/upbound/provider-family-aws"
+	autoReadyVersion := ">=v0.2.0"
+	config := &xtype.ConfigurationConfig{
+		Registry: &registry,
+		Packages: map[string]xtype.PackageReference{
+			"provider-upjet-aws":  {Package: &familyPackage},
+			"function-auto-ready": {Version: &autoReadyVersion},
+		},
+	}
+	pkg := func(p string) *string {
+		return &p
+	}
+	want := []pkgmetav1.Dependency{
+		{Provider: pkg("xpkg.upbound.io/example/provider-aws"), Version: ">=v0.32.0"},
+		{Provider: pkg("xpkg.upbound.io/upbound/provider-family-aws"), Version: ">=v1.4.0"},
+		{Function: pkg("xpkg.upbound.io/example/function-auto-ready"), Version: ">=v0.2.0"},
+		{Function: pkg("xpkg.upbound.io/example/function-patch-and-transform"), Version: anyPackageVersion},
+	}
+	if got := dependencies.dependsOn(config, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependsOn() = %v, want %v", got, want)
+	}
+
+	functions := map[string]xtype.Function{
+		"function-patch-and-transform": {Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0"},
+	}
+	want[3] = pkgmetav1.Dependency{Function: pkg("xpkg.upbound.io/crossplane-contrib/function-patch-and-transform"), Version: ">=v0.3.0"}
+	if got := dependencies.dependsOn(config, functions); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependsOn() with registered functions = %v, want %v", got, want)
+	}
+}
+
+func Test_compareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "v0.32.0", b: "v0.47.0", want: -1},
+		{a: "v1.10.0", b: "v1.9.0", want: 1},
+		{a: "v1.4.0-rc.1", b: "v1.4.0", want: 0},
+		{a: "v1.4", b: "v1.4.0", want: 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_updateConfiguration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crossplane.yaml")
+	if err := os.WriteFile(path, []byte(`---
+# the configuration package of the generated definitions
+apiVersion: meta.pkg.crossplane.io/v1
+kind: Configuration
+metadata:
+  name: x-generation
+  annotations:
+    meta.crossplane.io/maintainer: Crossplane Maintainers <info@crossplane.io>
+spec:
+  crossplane:
+    version: ">=v1.14.0-0" # the first version with functions
+  # written by the generator
+  dependsOn:
+    - provider: xpkg.upbound.io/crossplane-contrib/provider-zpa
+      version: ">=v0.4.0"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dependencies := newPackageDependencies()
+	dependencies.providers["provider-aws"] = []string{"v0.32.0"}
+	config := &xtype.GeneratorConfig{Configuration: &xtype.ConfigurationConfig{Path: path}}
+
+	var out bytes.Buffer
+	upToDate, err := updateConfiguration(config, dependencies, true, &out)
+	if err != nil || upToDate {
+		t.Fatalf("updateConfiguration() check = %v, %v, want out of date", upToDate, err)
+	}
+	if !strings.Contains(out.String(), "+    - provider: xpkg.upbound.io/crossplane-contrib/provider-aws") {
+		t.Errorf("updateConfiguration() check should print a diff, got %s", out.String())
+	}
+
+	if _, err := updateConfiguration(config, dependencies, false, &out); err != nil {
+		t.Fatalf("updateConfiguration() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# the configuration package of the generated definitions
+apiVersion: meta.pkg.crossplane.io/v1
+kind: Configuration
+metadata:
+  name: x-generation
+  annotations:
+    meta.crossplane.io/maintainer: Crossplane Maintainers <info@crossplane.io>
+spec:
+  crossplane:
+    version: ">=v1.14.0-0" # the first version with functions
+  # written by the generator
+  dependsOn:
+    - provider: xpkg.upbound.io/crossplane-contrib/provider-aws
+      version: '>=v0.32.0'
+`
+	if string(content) != want {
+		t.Errorf("updateConfiguration() should only replace spec.dependsOn, got %s, want %s", content, want)
+	}
+
+	if upToDate, err := updateConfiguration(config, dependencies, true, &out); err != nil || !upToDate {
+		t.Errorf("updateConfiguration() check after update = %v, %v, want up to date", upToDate, err)
+	}
+
+	config.Configuration.Path = filepath.Join(t.TempDir(), "package", "crossplane.yaml")
+	if err := os.Mkdir(filepath.Dir(config.Configuration.Path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := updateConfiguration(config, dependencies, false, &out); err != nil {
+		t.Fatalf("updateConfiguration() of a missing file error = %v", err)
+	}
+	content, err = os.ReadFile(config.Configuration.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `apiVersion: meta.pkg.crossplane.io/v1
+kind: Configuration
+metadata:
+  name: package
+spec:
+  dependsOn:
+    - provider: xpkg.upbound.io/crossplane-contrib/provider-aws
+      version: '>=v0.32.0'
+`
+	if string(content) != want {
+		t.Errorf("updateConfiguration() of a missing file = %s, want %s", content, want)
+	}
+}
+
+func Test_updatePackageFiles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "package", "crossplane.yaml")
+	existing := []byte(`apiVersion: meta.pkg.crossplane.io/v1
+kind: Configuration
+metadata:
+  name: package
+spec:
+  dependsOn:
+    - provider: xpkg.upbound.io/crossplane-contrib/provider-aws
+      version: ">=v0.32.0"
+    - provider: xpkg.upbound.io/crossplane-contrib/provider-gcp
+      version: ">=v0.22.0"
+`)
+	functionsPath := filepath.Join(root, "functions.yaml")
+	existingFunctions := []byte(`---
+apiVersion: pkg.crossplane.io/v1beta1
+kind: Function
+metadata:
+  name: function-go-templating
+spec:
+  package: xpkg.upbound.io/crossplane-contrib/function-go-templating:v0.5.0
+`)
+	writeFiles(t, root, map[string][]byte{"package/crossplane.yaml": existing, "functions.yaml": existingFunctions})
+	// the generators below apis/ec2 only use provider-aws and patch-and-transform
+	dependencies := newPackageDependencies()
+	dependencies.providers["provider-aws"] = []string{"v0.47.0"}
+	dependencies.functions["function-patch-and-transform"] = true
+	config := &xtype.GeneratorConfig{
+		Configuration: &xtype.ConfigurationConfig{Path: path},
+		FunctionsPath: &functionsPath,
+		Functions: map[string]xtype.Function{
+			"function-go-templating":       {Package: "xpkg.upbound.io/crossplane-contrib/function-go-templating:v0.5.0"},
+			"function-patch-and-transform": {Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0"},
+		},
+	}
+
+	var out bytes.Buffer
+	args := &arguments{inputPath: filepath.Join(root, "apis", "ec2"), configFile: filepath.Join(root, "generator-config.yaml")}
+	if upToDate, err := updatePackageFiles(args, config, dependencies, &out); err != nil || !upToDate {
+		t.Fatalf("updatePackageFiles() of a subset = %v, %v", upToDate, err)
+	}
+	if content, _ := os.ReadFile(path); !bytes.Equal(content, existing) {
+		t.Errorf("updatePackageFiles() of a subset should not change %s, got %s", path, content)
+	}
+	if content, _ := os.ReadFile(functionsPath); !bytes.Equal(content, existingFunctions) {
+		t.Errorf("updatePackageFiles() of a subset should not change %s, got %s", functionsPath, content)
+	}
+	if !strings.Contains(out.String(), "does not contain all generators") {
+		t.Errorf("updatePackageFiles() of a subset should explain why nothing was updated, got %s", out.String())
+	}
+
+	args.inputPath = root
+	if _, err := updatePackageFiles(args, config, dependencies, &out); err != nil {
+		t.Fatalf("updatePackageFiles() error = %v", err)
+	}
+	if content, _ := os.ReadFile(path); strings.Contains(string(content), "provider-gcp") || !strings.Contains(string(content), ">=v0.47.0") {
+		t.Errorf("updatePackageFiles() of all generators should replace the dependencies, got %s", content)
+	}
+	if content, _ := os.ReadFile(functionsPath); strings.Contains(string(content), "function-go-templating") || !strings.Contains(string(content), "function-patch-and-transform") {
+		t.Errorf("updatePackageFiles() of all generators should replace the functions, got %s", content)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	getter "github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+)
+
+// A crdSource retrieves the content of the crd files of a provider
+type crdSource interface {
+	// Fetch returns the content of the given crd
+	Fetch(crd t.CrdConfig) ([]byte, error)
+	// Location returns a human readable location of the given crd
+	Location(crd t.CrdConfig) string
+}
+
+// crdLoader holds the settings used to load the crds of all generators
+type crdLoader struct {
+	// crdDir overrides all configured crd locations if set
+	crdDir string
+	// cache stores downloaded crds, nil disables caching
+	cache *crdCache
+	// lock records the checksums of downloaded crds, nil disables locking
+	lock *lockFile
+	// verifyLock fails the download of crds not matching their recorded checksum
+	verifyLock bool
+	// bundles holds the crds of already read provider packages and directories
+	bundles *bundleCache
+	// fetches deduplicates fetching the same crd for several generators
+	fetches *fetchGroup
+}
+
+// Fetch the crd from source, a crd requested by several generators is only
+// fetched once
+func (l *crdLoader) fetch(source crdSource, crd t.CrdConfig) ([]byte, error) {
+	key := source.Location(crd) + "|" + crd.File + "|" + crd.Kind + "." + crd.Group
+	return l.fetches.Do(key, func() ([]byte, error) {
+		return source.Fetch(crd)
+	})
+}
+
+// fetchGroup remembers the result of each fetch, concurrent fetches of the
+// same key wait for the first one to finish
+type fetchGroup struct {
+	mu      sync.Mutex
+	fetches map[string]*fetchCall
+}
+
+type fetchCall struct {
+	done    chan struct{}
+	content []byte
+	err     error
+}
+
+func newFetchGroup() *fetchGroup {
+	return &fetchGroup{fetches: map[string]*fetchCall{}}
+}
+
+// Do returns the result of fetch for key, fetch is only called once per key.
+// A nil group always calls fetch.
+func (f *fetchGroup) Do(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if f == nil {
+		return fetch()
+	}
+	f.mu.Lock()
+	if c, ok := f.fetches[key]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.content, c.err
+	}
+	c := &fetchCall{done: make(chan struct{})}
+	f.fetches[key] = c
+	f.mu.Unlock()
+
+	c.content, c.err = fetch()
+	close(c.done)
+	return c.content, c.err
+}
+
+// Write to logger, or to the standard logger if logger is nil
+func logf(logger *log.Logger, format string, v ...interface{}) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf(format, v...)
+}
+
+// remoteSource downloads crd files from a url built from the base url,
+// the provider name, the provider version and the crd file name
+type remoteSource struct {
+	baseURL         string
+	indexURL        string
+	providerName    string
+	providerVersion string
+
+	cache      *crdCache
+	lock       *lockFile
+	verifyLock bool
+	logger     *log.Logger
+}
+
+func (s *remoteSource) Location(crd t.CrdConfig) string {
+	if crd.File == "" && s.indexURL != "" {
+		return s.index() + "#" + crd.Kind + "." + crd.Group
+	}
+	return fmt.Sprintf(s.baseURL, s.providerName, s.providerVersion, crd.File)
+}
+
+func (s *remoteSource) index() string {
+	return fmt.Sprintf(s.indexURL, s.providerName, s.providerVersion)
+}
+
+// Fetch returns the crd file from the cache if possible, otherwise it is
+// downloaded and checked against the checksum recorded in the lock file.
+// Without a crd file name, the file is looked up by kind in the index.
+func (s *remoteSource) Fetch(crd t.CrdConfig) ([]byte, error) {
+	if crd.File != "" {
+		return s.fetchFile(crd.File)
+	}
+	index, err := s.download(s.index(), "index")
+	if err != nil {
+		return nil, err
+	}
+	file, err := findInIndex(parseCRDIndex(index), crd, "index "+s.index())
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.fetchFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCRDKind(content, crd, file); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (s *remoteSource) fetchFile(file string) ([]byte, error) {
+	url := fmt.Sprintf(s.baseURL, s.providerName, s.providerVersion, file)
+	var entry *lockEntry
+	if s.lock != nil {
+		entry = s.lock.Find(s.providerName, s.providerVersion, file)
+		if entry != nil && entry.URL != url {
+			if s.verifyLock {
+				return nil, errors.Errorf("Url of CRD %s changed from %s to %s\n", file, entry.URL, url)
+			}
+			entry = nil
+		}
+	}
+
+	if s.cache != nil {
+		// a recorded checksum takes precedence over the latest cached version
+		sum, ok := s.cache.Lookup(s.providerName, s.providerVersion, file)
+		if entry != nil {
+			sum, ok = entry.SHA256, true
+		}
+		if ok {
+			if content, ok := s.cache.Get(sum); ok {
+				logf(s.logger, "Using cached CRD file %s\n", file)
+				s.record(file, url, sum)
+				return content, nil
+			}
+		}
+	}
+
+	content, err := s.download(url, file)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := checksum(content)
+	if entry != nil && entry.SHA256 != sum {
+		if s.verifyLock {
+			return nil, errors.Errorf("Checksum of CRD %s does not match the lock file, got sha256 %s, want %s\n", url, sum, entry.SHA256)
+		}
+		logf(s.logger, "Checksum of CRD %s changed, updating lock file\n", url)
+	}
+	if s.cache != nil {
+		if _, err := s.cache.Put(s.providerName, s.providerVersion, file, content); err != nil {
+			return nil, err
+		}
+	}
+	s.record(file, url, sum)
+	return content, nil
+}
+
+func (s *remoteSource) record(file, url, sum string) {
+	if s.lock == nil {
+		return
+	}
+	s.lock.Record(lockEntry{
+		Provider: s.providerName,
+		Version:  s.providerVersion,
+		File:     file,
+		URL:      url,
+		SHA256:   sum,
+	})
+}
+
+// Download url, file is the name of the downloaded file used for logging
+func (s *remoteSource) download(url, file string) ([]byte, error) {
+	crdTempDir, err := os.MkdirTemp("", "gencrd")
+	if err != nil {
+		return nil, errors.Errorf("Error creating CRD temp dir: %v\n", err)
+	}
+
+	defer os.RemoveAll(crdTempDir)
+
+	crdTempFile := filepath.Join(crdTempDir, filepath.Base(file))
+
+	client := &getter.Client{
+		Ctx: context.Background(),
+		Src: url,
+		Dst: crdTempFile,
+	}
+
+	logf(s.logger, "Retrieving CRD file from %s\n", file)
+	err = client.Get()
+	if err != nil {
+		return nil, errors.Errorf("Get CRD: %v\n", err)
+	}
+
+	crd, err := os.ReadFile(crdTempFile)
+	if err != nil {
+		return nil, errors.Errorf("Error reading from CRD tempfile: %v\n", err)
+	}
+	return crd, nil
+}
+
+// localSource reads crd files from a local directory. If path points to a
+// file instead of a directory, this file is used regardless of the crd file name.
+// If a kind is given, the crd is selected from all crds in the directory or file.
+type localSource struct {
+	path string
+
+	bundles *bundleCache
+	logger  *log.Logger
+}
+
+func (s *localSource) Location(crd t.CrdConfig) string {
+	info, err := os.Stat(s.path)
+	if (err == nil && !info.IsDir()) || crd.Kind != "" {
+		return s.path
+	}
+	return filepath.Join(s.path, crd.File)
+}
+
+func (s *localSource) Fetch(crd t.CrdConfig) ([]byte, error) {
+	location := s.Location(crd)
+	if crd.Kind != "" {
+		crds, err := s.bundles.Load(s.path, func() ([]bundleCRD, error) {
+			logf(s.logger, "Reading CRDs from %s\n", s.path)
+			return readCRDDirectory(s.path)
+		})
+		if err != nil {
+			return nil, errors.Errorf("Error reading local CRDs: %v\n", err)
+		}
+		return selectCRD(crds, crd, location)
+	}
+	logf(s.logger, "Reading CRD file from %s\n", location)
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Errorf("Error reading local CRD file: %v\n", err)
+	}
+	return content, nil
+}
+
+// Determine where the crd of provider is loaded from, settings missing in
+// provider are taken from the global configuration. The first match wins:
+//   - the crdDir given on the command line
+//   - provider.package
+//   - provider.crdPath
+//   - provider.baseURL
+//   - provider.package of the global configuration
+//   - provider.crdPath of the global configuration
+//   - provider.baseURL of the global configuration
+//   - the default base url
+func (g *Generator) getProviderCRDSource(generatorConfig *t.GeneratorConfig, provider t.ProviderConfig, loader *crdLoader) (crdSource, error) {
+	if provider.CRD.File == "" && provider.CRD.Kind == "" {
+		return nil, errors.New("Neither provider.crd.file nor provider.crd.kind given\n")
+	}
+	if loader.crdDir != "" {
+		return &localSource{path: loader.crdDir, bundles: loader.bundles, logger: g.log}, nil
+	}
+
+	providerName := generatorConfig.Provider.Name
+	if provider.Name != "" {
+		providerName = provider.Name
+	}
+	providerVersion := generatorConfig.Provider.Version
+	if provider.Name != "" {
+		providerVersion = provider.Version
+	}
+
+	switch {
+	case provider.Package != nil:
+		return &packageSource{
+			path:            resolvePath(g.configPath, *provider.Package),
+			providerName:    providerName,
+			providerVersion: providerVersion,
+			bundles:         loader.bundles,
+			logger:          g.log,
+		}, nil
+	case provider.CRDPath != nil:
+		return &localSource{path: resolvePath(g.configPath, *provider.CRDPath), bundles: loader.bundles, logger: g.log}, nil
+	case provider.BaseURL != nil:
+		// the base url of the generator takes precedence over all global settings
+	case generatorConfig.Provider.Package != nil:
+		return &packageSource{
+			path:            *generatorConfig.Provider.Package,
+			providerName:    providerName,
+			providerVersion: providerVersion,
+			bundles:         loader.bundles,
+			logger:          g.log,
+		}, nil
+	case generatorConfig.Provider.CRDPath != nil:
+		return &localSource{path: *generatorConfig.Provider.CRDPath, bundles: loader.bundles, logger: g.log}, nil
+	}
+
+	usedBaseURL := baseURL
+	if provider.BaseURL != nil {
+		usedBaseURL = *provider.BaseURL
+	} else if generatorConfig.Provider.BaseURL != nil {
+		usedBaseURL = *generatorConfig.Provider.BaseURL
+	}
+
+	if providerName == "" {
+		return nil, errors.Errorf("No provider name given for crd: %v\n", provider.CRD.File)
+	}
+
+	if providerVersion == "" {
+		return nil, errors.Errorf("No provider version given for crd: %v\n", provider.CRD.File)
+	}
+
+	indexURL := ""
+	if provider.IndexURL != nil {
+		indexURL = *provider.IndexURL
+	} else if generatorConfig.Provider.IndexURL != nil {
+		indexURL = *generatorConfig.Provider.IndexURL
+	}
+
+	if provider.CRD.File == "" && indexURL == "" {
+		return nil, errors.Errorf("No crd file given for kind %s, selecting a crd by kind requires provider.indexURL, provider.package or provider.crdPath\n", provider.CRD.Kind)
+	}
+
+	return &remoteSource{
+		baseURL:         usedBaseURL,
+		indexURL:        indexURL,
+		providerName:    providerName,
+		providerVersion: providerVersion,
+		cache:           loader.cache,
+		lock:            loader.lock,
+		verifyLock:      loader.verifyLock,
+		logger:          g.log,
+	}, nil
+}
+
+// Resolve path relative to base, absolute paths are returned unchanged
+func resolvePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
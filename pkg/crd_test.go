@@ -0,0 +1,275 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+)
+
+func Test_getProviderCRDSource(t *testing.T) {
+	localDir := "/crds/global"
+	generatorDir := "./crds"
+	remote := "https://example.cloud/%s/%s/%s"
+	type args struct {
+		provider        xtype.ProviderConfig
+		generatorConfig xtype.GeneratorConfig
+		crdDir          string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    crdSource
+		wantErr bool
+	}{
+		{
+			name: "Should use the default base url",
+			args: args{
+				generatorConfig: xtype.GeneratorConfig{
+					Provider: xtype.GlobalProviderConfig{
+						Name:    "provider-aws",
+						Version: "v0.32.0",
+					},
+				},
+			},
+			want: &remoteSource{
+				baseURL:         baseURL,
+				providerName:    "provider-aws",
+				providerVersion: "v0.32.0",
+			},
+		},
+		{
+			name: "Should prefer the crdDir flag",
+			args: args{
+				provider: xtype.ProviderConfig{
+					GlobalProviderConfig: xtype.GlobalProviderConfig{
+						CRDPath: &generatorDir,
+						BaseURL: &remote,
+					},
+				},
+				generatorConfig: xtype.GeneratorConfig{
+					Provider: xtype.GlobalProviderConfig{
+						CRDPath: &localDir,
+					},
+				},
+				crdDir: "/vendor/crds",
+			},
+			want: &localSource{path: "/vendor/crds"},
+		},
+		{
+			name: "Should resolve crdPath of the generator relative to generate.yaml",
+			args: args{
+				provider: xtype.ProviderConfig{
+					GlobalProviderConfig: xtype.GlobalProviderConfig{
+						CRDPath: &generatorDir,
+					},
+				},
+			},
+			want: &localSource{path: "/package/S3-Bucket/crds"},
+		},
+		{
+			name: "Should prefer baseURL of the generator over global crdPath",
+			args: args{
+				provider: xtype.ProviderConfig{
+					GlobalProviderConfig: xtype.GlobalProviderConfig{
+						Name:    "provider-aws",
+						Version: "v0.33.0",
+						BaseURL: &remote,
+					},
+				},
+				generatorConfig: xtype.GeneratorConfig{
+					Provider: xtype.GlobalProviderConfig{
+						CRDPath: &localDir,
+					},
+				},
+			},
+			want: &remoteSource{
+				baseURL:         remote,
+				providerName:    "provider-aws",
+				providerVersion: "v0.33.0",
+			},
+		},
+		{
+			name: "Should prefer global crdPath over global baseURL",
+			args: args{
+				generatorConfig: xtype.GeneratorConfig{
+					Provider: xtype.GlobalProviderConfig{
+						Name:    "provider-aws",
+						Version: "v0.32.0",
+						BaseURL: &remote,
+						CRDPath: &localDir,
+					},
+				},
+			},
+			want: &localSource{path: localDir},
+		},
+		{
+			name: "Should fail without provider name for remote crds",
+			args: args{
+				generatorConfig: xtype.GeneratorConfig{
+					Provider: xtype.GlobalProviderConfig{
+						Version: "v0.32.0",
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Generator{
+				Provider:   tt.args.provider,
+				configPath: "/package/S3-Bucket",
+			}
+			g.Provider.CRD.File = "s3.aws.crossplane.io_buckets.yaml"
+			got, err := g.getProviderCRDSource(&tt.args.generatorConfig, g.Provider, &crdLoader{crdDir: tt.args.crdDir})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getProviderCRDSource() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getProviderCRDSource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_localSource_Fetch(t *testing.T) {
+	tempDir := t.TempDir()
+	content := []byte("kind: CustomResourceDefinition\n")
+	crdFile := filepath.Join(tempDir, "s3.aws.crossplane.io_buckets.yaml")
+	if err := os.WriteFile(crdFile, content, 0644); err != nil {
+		t.Fatalf("could not write crd file: %v", err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		file    string
+		wantErr bool
+	}{
+		{
+			name: "Should resolve the crd file in a directory",
+			path: tempDir,
+			file: "s3.aws.crossplane.io_buckets.yaml",
+		},
+		{
+			name: "Should use a file path directly",
+			path: crdFile,
+			file: "iam.aws.crossplane.io_roles.yaml",
+		},
+		{
+			name:    "Should fail for missing crd files",
+			path:    tempDir,
+			file:    "iam.aws.crossplane.io_roles.yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &localSource{path: tt.path}
+			got, err := s.Fetch(xtype.CrdConfig{File: tt.file})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, content) {
+				t.Errorf("Fetch() = %s, want %s", got, content)
+			}
+		})
+	}
+}
+
+func Test_remoteSource_Fetch(t *testing.T) {
+	content := "kind: CustomResourceDefinition\n"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			requests++
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	lock, err := loadLockFile(filepath.Join(tempDir, lockFileName))
+	if err != nil {
+		t.Fatalf("could not load lock file: %v", err)
+	}
+	s := &remoteSource{
+		baseURL:         server.URL + "/%s/%s/%s",
+		providerName:    "provider-aws",
+		providerVersion: "v0.32.0",
+		cache:           &crdCache{dir: filepath.Join(tempDir, "cache")},
+		lock:            lock,
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := s.Fetch(xtype.CrdConfig{File: "s3.aws.crossplane.io_buckets.yaml"})
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if string(got) != content {
+			t.Errorf("Fetch() = %s, want %s", got, content)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("crd should be downloaded once, got %d downloads", requests)
+	}
+	entry := lock.Find("provider-aws", "v0.32.0", "s3.aws.crossplane.io_buckets.yaml")
+	if entry == nil || entry.SHA256 != checksum([]byte(content)) {
+		t.Fatalf("lock file should record the checksum, got %v", entry)
+	}
+	if err := lock.Write(); err != nil {
+		t.Fatalf("could not write lock file: %v", err)
+	}
+	reloaded, err := loadLockFile(lock.path)
+	if err != nil || !reflect.DeepEqual(reloaded.CRDs, lock.CRDs) {
+		t.Errorf("lock file should be reloaded, got %v, %v", reloaded, err)
+	}
+
+	// the upstream crd changed while the lock file still records the old checksum
+	content = "kind: CustomResourceDefinition\nmetadata: {}\n"
+	s.cache = nil
+	s.verifyLock = true
+	if _, err := s.Fetch(xtype.CrdConfig{File: "s3.aws.crossplane.io_buckets.yaml"}); err == nil {
+		t.Error("Fetch() should fail for a changed crd in verify mode")
+	}
+	s.verifyLock = false
+	if _, err := s.Fetch(xtype.CrdConfig{File: "s3.aws.crossplane.io_buckets.yaml"}); err != nil {
+		t.Errorf("Fetch() error = %v", err)
+	}
+	if entry := lock.Find("provider-aws", "v0.32.0", "s3.aws.crossplane.io_buckets.yaml"); entry.SHA256 != checksum([]byte(content)) {
+		t.Error("lock file should be updated with the new checksum")
+	}
+}
+
+func Test_fetchGroup(t *testing.T) {
+	f := newFetchGroup()
+	var fetches atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := f.Do("crd", func() ([]byte, error) {
+				fetches.Add(1)
+				time.Sleep(10 * time.Millisecond)
+				return []byte("content"), nil
+			})
+			if err != nil || string(got) != "content" {
+				t.Errorf("Do() = %s, %v, want content", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches.Load() != 1 {
+		t.Errorf("crd should be fetched once, got %d fetches", fetches.Load())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// number of unchanged lines shown around a change
+const diffContext = 3
+
+// Create a unified diff of two files, an empty string is returned if the
+// files are equal
+func unifiedDiff(fromName, toName string, from, to []byte) string {
+	var sb strings.Builder
+	// writing to a strings.Builder can't fail
+	_ = difflib.WriteUnifiedDiff(&sb, difflib.UnifiedDiff{
+		A:        splitLines(string(from)),
+		B:        splitLines(string(to)),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  diffContext,
+	})
+	return sb.String()
+}
+
+// Split s into lines ending with a newline, as expected by difflib
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
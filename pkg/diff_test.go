@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "Should return nothing for equal files",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "Should show changed lines with context",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:   "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "Should split distant changes into hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n",
+		},
+		{
+			name: "Should show new files",
+			from: "",
+			to:   "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", []byte(tt.from), []byte(tt.to)); got != tt.want {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// A large file with more changed lines than the 2000 edits the diff used to
+// give up at
+func Test_unifiedDiff_Large(t *testing.T) {
+	var from, to strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&from, "line %d\n", i)
+		if i%4 == 0 {
+			fmt.Fprintf(&to, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&to, "line %d\n", i)
+		}
+	}
+	got := unifiedDiff("old", "new", []byte(from.String()), []byte(to.String()))
+	removed, added := 0, 0
+	for _, line := range strings.Split(got, "\n") {
+		switch {
+		case strings.HasPrefix(line, "-line "):
+			removed++
+		case strings.HasPrefix(line, "+changed "):
+			added++
+		case strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++"):
+			t.Fatalf("unifiedDiff() should only add changed lines, got %q", line)
+		}
+	}
+	if removed != 1250 || added != 1250 {
+		t.Errorf("unifiedDiff() removed %d and added %d lines, want 1250 each", removed, added)
+	}
+}
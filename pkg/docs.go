@@ -0,0 +1,528 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kindDocs is the reference documentation of the kind users create, the
+// claim or the composite if the definition has no claim
+type kindDocs struct {
+	Kind          string
+	CompositeKind string
+	Group         string
+	// path of the generate.yaml
+	Path     string
+	Versions []versionDocs
+}
+
+type versionDocs struct {
+	Name          string
+	Referenceable bool
+	Properties    []propertyDocs
+}
+
+// propertyDocs is a property of the schema of a version, the properties of
+// array items are documented below the array with paths like array[*].name
+type propertyDocs struct {
+	Path        string
+	Depth       int
+	Type        string
+	Required    bool
+	Default     string
+	Enum        []string
+	Description string
+	// the CEL validation rules with their message
+	Rules []string
+	// the fields of the managed resources the property is patched to
+	PatchesTo []string
+}
+
+// Run the docs subcommand: write a reference page for the kind of every
+// definition, rendered from the generate.yaml or read from the existing
+// definition and compositions, and an index of the pages
+func runDocs(argv []string, out io.Writer) error {
+	var args arguments
+	fs := flag.NewFlagSet("docs", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := addFlags(fs, &args); err != nil {
+		return err
+	}
+	docsPath := fs.String("docsPath", "docs", "directory the reference pages are written to")
+	format := fs.String("format", "markdown", "format of the reference pages, markdown or html")
+	existing := fs.Bool("existing", false, "document the existing definitions and compositions instead of generating them")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: x-generation docs [flags]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(argv); err != nil {
+		return err
+	}
+	setDefaultArgs(&args)
+	if *format != "markdown" && *format != "html" {
+		fs.Usage()
+		return errors.Errorf("unknown format %s, must be markdown or html", *format)
+	}
+
+	list, err := findGenerators(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot find generator files")
+	}
+	generatorConfig, err := loadGeneratorConfig(args.configFile, !args.allowUnknownFields)
+	if err != nil {
+		return errors.Wrap(err, "cannot load generator config file")
+	}
+	if err := checkConfig(generatorConfig); err != nil {
+		return errors.Wrap(err, "generator config not valid")
+	}
+	loader, err := newCRDLoader(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot load lock file")
+	}
+
+	var mu sync.Mutex
+	docs := map[string]*kindDocs{}
+	failures := []failure{}
+	results := generateAll(list, args.concurrency, args.keepGoing, func(path string, out io.Writer) (bool, error) {
+		d, err := generatorDocs(path, &args, generatorConfig, loader, *existing, out)
+		if err != nil {
+			return false, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		docs[path] = d
+		return true, nil
+	})
+	kinds := []kindDocs{}
+	for i, r := range results {
+		<-r.done
+		if r.skipped {
+			continue
+		}
+		out.Write(r.output.Bytes())
+		if r.err != nil {
+			failures = append(failures, failure{path: list[i], err: r.err})
+			continue
+		}
+		if d := docs[list[i]]; d != nil {
+			kinds = append(kinds, *d)
+		}
+	}
+	if len(failures) > 0 {
+		printFailures(out, failures, len(list))
+		return errors.New("cannot document all definitions")
+	}
+	sort.SliceStable(kinds, func(i, j int) bool {
+		if kinds[i].Group != kinds[j].Group {
+			return kinds[i].Group < kinds[j].Group
+		}
+		return kinds[i].Kind < kinds[j].Kind
+	})
+
+	pages, err := docsPages(kinds, *format)
+	if err != nil {
+		return err
+	}
+	for name, content := range pages {
+		path := filepath.Join(*docsPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return errors.Wrapf(err, "cannot write %s", path)
+		}
+	}
+	fmt.Fprintf(out, "Wrote %d reference pages to %s\n", len(kinds), *docsPath)
+	return nil
+}
+
+// Return the documentation of the generate file at path, nil is returned if
+// the generator is ignored
+func generatorDocs(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, existing bool, out io.Writer) (*kindDocs, error) {
+	var files []generatedFile
+	var err error
+	if existing {
+		files, err = existingFiles(path, args, out)
+	} else {
+		files, err = renderedFiles(path, args, generatorConfig, loader, out)
+	}
+	if err != nil || files == nil {
+		return nil, err
+	}
+	var xrd *crossplanev1.CompositeResourceDefinition
+	compositions := []crossplanev1.Composition{}
+	for _, f := range files {
+		name := filepath.Base(f.path)
+		if name != "definition.yaml" && !strings.HasPrefix(name, "composition") {
+			continue
+		}
+		if name == "definition.yaml" {
+			xrd = &crossplanev1.CompositeResourceDefinition{}
+			if err := yaml.Unmarshal(f.content, xrd); err != nil {
+				return nil, errors.Wrapf(err, "cannot unmarshal %s", f.path)
+			}
+			continue
+		}
+		var composition crossplanev1.Composition
+		if err := yaml.Unmarshal(f.content, &composition); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal %s", f.path)
+		}
+		compositions = append(compositions, composition)
+	}
+	if xrd == nil {
+		return nil, errors.New("no definition generated")
+	}
+	patches := map[string][]string{}
+	for i := range compositions {
+		if err := parameterPatches(&compositions[i], patches); err != nil {
+			return nil, errors.Wrapf(err, "cannot read the patches of composition %s", compositions[i].Name)
+		}
+	}
+	d, err := definitionDocs(xrd, patches)
+	if err != nil {
+		return nil, err
+	}
+	d.Path = path
+	fmt.Fprintf(out, "Documented %s of %s\n", d.Kind, path)
+	return d, nil
+}
+
+// Render the files of the generate file at path, nil is returned if the
+// generator is ignored
+func renderedFiles(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, out io.Writer) ([]generatedFile, error) {
+	g, err := loadGenerator(path, args, generatorConfig, loader, out)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return g.Render(generatorConfig, args.scriptPath, args.scriptFile, args.outputPath)
+}
+
+// Read the existing definition and compositions of the generate file at path
+// from its output directory without loading the crd of the provider, nil is
+// returned if the generator is ignored
+func existingFiles(path string, args *arguments, out io.Writer) ([]generatedFile, error) {
+	g, err := (&Generator{log: log.New(out, "", log.LstdFlags)}).LoadConfig(path, !args.allowUnknownFields)
+	if err != nil {
+		return nil, err
+	}
+	if g.Ignore {
+		fmt.Fprintf(out, "Generator for %s asks to be ignored, skipping...\n", g.Name)
+		return nil, nil
+	}
+	dir := g.configPath
+	if args.outputPath != "" {
+		dir = args.outputPath
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "composition*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	files := []generatedFile{}
+	for _, p := range append([]string{filepath.Join(dir, "definition.yaml")}, paths...) {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read existing %s", p)
+		}
+		files = append(files, generatedFile{path: p, content: stripHeader(content)})
+	}
+	return files, nil
+}
+
+// Add the fields of the managed resources the properties of the composite
+// are patched to by the Parameters patch sets of the composition to patches,
+// compositions without patch-and-transform step add nothing
+func parameterPatches(composition *crossplanev1.Composition, patches map[string][]string) error {
+	inputs, err := patchAndTransformInputs(composition)
+	if errors.Is(err, errNoPatchAndTransform) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		patchSets := map[string]p.PatchSet{}
+		for _, s := range input.PatchSets {
+			patchSets[s.Name] = s
+		}
+		for _, template := range input.Resources {
+			kind := template.Name
+			if template.Base != nil {
+				var typeMeta metav1.TypeMeta
+				if err := json.Unmarshal(template.Base.Raw, &typeMeta); err != nil {
+					return errors.Wrapf(err, "cannot parse the base of %s", template.Name)
+				}
+				if typeMeta.Kind != "" {
+					kind = typeMeta.Kind
+				}
+			}
+			for _, patch := range template.Patches {
+				name := patch.GetPatchSetName()
+				if patch.GetType() != p.PatchTypePatchSet || (name != "Parameters" && !strings.HasPrefix(name, "Parameters-")) {
+					continue
+				}
+				for _, setPatch := range patchSets[name].Patches {
+					if setPatch.GetType() != p.PatchTypeFromCompositeFieldPath || setPatch.FromFieldPath == nil || setPatch.ToFieldPath == nil {
+						continue
+					}
+					target := kind + " " + *setPatch.ToFieldPath
+					targets := patches[*setPatch.FromFieldPath]
+					if !listHas(&targets, target) {
+						patches[*setPatch.FromFieldPath] = append(targets, target)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Return the documentation of the served versions of a definition, patches
+// are the managed resource fields of the properties
+func definitionDocs(xrd *crossplanev1.CompositeResourceDefinition, patches map[string][]string) (*kindDocs, error) {
+	d := &kindDocs{Kind: xrd.Spec.Names.Kind, CompositeKind: xrd.Spec.Names.Kind, Group: xrd.Spec.Group}
+	if xrd.Spec.ClaimNames != nil {
+		d.Kind = xrd.Spec.ClaimNames.Kind
+	}
+	for _, v := range xrd.Spec.Versions {
+		if !v.Served {
+			continue
+		}
+		schema, err := versionSchema(v)
+		if err != nil {
+			return nil, err
+		}
+		version := versionDocs{Name: v.Name, Referenceable: v.Referenceable}
+		for _, name := range []string{"spec", "status"} {
+			if property, ok := schema.Properties[name]; ok {
+				version.Properties = append(version.Properties, schemaDocs(name, 0, &property, listHas(&schema.Required, name), patches)...)
+			}
+		}
+		d.Versions = append(d.Versions, version)
+	}
+	return d, nil
+}
+
+// Return the documentation of a property and the properties below it
+func schemaDocs(path string, depth int, schema *extv1.JSONSchemaProps, required bool, patches map[string][]string) []propertyDocs {
+	property := propertyDocs{
+		Path:        path,
+		Depth:       depth,
+		Type:        schemaType(schema),
+		Required:    required,
+		Description: strings.TrimSpace(schema.Description),
+		PatchesTo:   patches[path],
+	}
+	if schema.Default != nil {
+		property.Default = string(schema.Default.Raw)
+	}
+	for _, e := range schema.Enum {
+		property.Enum = append(property.Enum, string(e.Raw))
+	}
+	for _, rule := range schema.XValidations {
+		if rule.Message != "" {
+			property.Rules = append(property.Rules, fmt.Sprintf("%s (%s)", rule.Rule, rule.Message))
+		} else {
+			property.Rules = append(property.Rules, rule.Rule)
+		}
+	}
+
+	properties := []propertyDocs{property}
+	for _, name := range sortedProperties(schema.Properties) {
+		child := schema.Properties[name]
+		properties = append(properties, schemaDocs(path+"."+name, depth+1, &child, listHas(&schema.Required, name), patches)...)
+	}
+	if schema.Type == "array" && schema.Items != nil && schema.Items.Schema != nil {
+		item := schema.Items.Schema
+		for _, name := range sortedProperties(item.Properties) {
+			child := item.Properties[name]
+			properties = append(properties, schemaDocs(path+"[*]."+name, depth+1, &child, listHas(&item.Required, name), patches)...)
+		}
+	}
+	return properties
+}
+
+// Return the type of a property, arrays and maps include the type of their
+// items
+func schemaType(schema *extv1.JSONSchemaProps) string {
+	switch {
+	case schema.Type == "array" && schema.Items != nil && schema.Items.Schema != nil:
+		return "[]" + schemaType(schema.Items.Schema)
+	case schema.Type == "object" && schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil:
+		return "map[string]" + schemaType(schema.AdditionalProperties.Schema)
+	case schema.Type == "" && schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields:
+		return "any"
+	}
+	return schema.Type
+}
+
+// Return the name of the page of a kind relative to the docs directory
+func (d *kindDocs) page(extension string) string {
+	return filepath.Join(d.Group, strings.ToLower(d.Kind)+extension)
+}
+
+// Return the reference pages and their index by their path relative to the
+// docs directory
+func docsPages(kinds []kindDocs, format string) (map[string][]byte, error) {
+	pages := map[string][]byte{}
+	if format == "markdown" {
+		for i := range kinds {
+			pages[kinds[i].page(".md")] = []byte(kinds[i].markdown())
+		}
+		pages["README.md"] = []byte(docsIndexMarkdown(kinds))
+		return pages, nil
+	}
+	for i := range kinds {
+		var sb strings.Builder
+		if err := kindTemplate.Execute(&sb, &kinds[i]); err != nil {
+			return nil, errors.Wrapf(err, "cannot render the page of %s", kinds[i].Kind)
+		}
+		pages[kinds[i].page(".html")] = []byte(sb.String())
+	}
+	var sb strings.Builder
+	if err := indexTemplate.Execute(&sb, kinds); err != nil {
+		return nil, errors.Wrap(err, "cannot render the index")
+	}
+	pages["index.html"] = []byte(sb.String())
+	return pages, nil
+}
+
+func docsIndexMarkdown(kinds []kindDocs) string {
+	var sb strings.Builder
+	sb.WriteString("# API reference\n\n")
+	sb.WriteString("| Kind | Group | Versions |\n")
+	sb.WriteString("| ---- | ----- | -------- |\n")
+	for i := range kinds {
+		versions := []string{}
+		for _, v := range kinds[i].Versions {
+			versions = append(versions, v.Name)
+		}
+		fmt.Fprintf(&sb, "| [%s](%s) | %s | %s |\n", kinds[i].Kind, filepath.ToSlash(kinds[i].page(".md")), kinds[i].Group, strings.Join(versions, ", "))
+	}
+	return sb.String()
+}
+
+// Render the reference page of a kind as markdown
+func (d *kindDocs) markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", d.Kind)
+	fmt.Fprintf(&sb, "Group: `%s`  \n", d.Group)
+	if d.CompositeKind != d.Kind {
+		fmt.Fprintf(&sb, "Composite: `%s`  \n", d.CompositeKind)
+	}
+	fmt.Fprintf(&sb, "Generated from: `%s`\n", d.Path)
+	for _, v := range d.Versions {
+		fmt.Fprintf(&sb, "\n## %s/%s", d.Group, v.Name)
+		if v.Referenceable {
+			sb.WriteString(" (referenceable)")
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString("| Property | Type | Required | Default | Enum | Validation | Patches to | Description |\n")
+		sb.WriteString("| -------- | ---- | -------- | ------- | ---- | ---------- | ---------- | ----------- |\n")
+		for _, property := range v.Properties {
+			required := ""
+			if property.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(&sb, "| %s`%s` | %s | %s | %s | %s | %s | %s | %s |\n",
+				strings.Repeat("&nbsp;&nbsp;", property.Depth),
+				markdownCell(property.Path),
+				markdownCell(property.Type),
+				required,
+				markdownCode(property.Default),
+				markdownCode(property.Enum...),
+				markdownCode(property.Rules...),
+				markdownCode(property.PatchesTo...),
+				markdownCell(property.Description))
+		}
+	}
+	return sb.String()
+}
+
+// Escape a value for a cell of a markdown table
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+// Return the values as code in a cell of a markdown table, one per line
+func markdownCode(values ...string) string {
+	cells := []string{}
+	for _, v := range values {
+		if v != "" {
+			cells = append(cells, "`"+markdownCell(v)+"`")
+		}
+	}
+	return strings.Join(cells, "<br>")
+}
+
+var kindTemplate = template.Must(template.New("kind").Funcs(template.FuncMap{
+	"indent": func(depth int) template.CSS {
+		return template.CSS(fmt.Sprintf("padding-left: %dem", depth*2))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Kind }}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px; text-align: left; vertical-align: top; }
+td.description { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<p><a href="{{ if .Group }}../{{ end }}index.html">API reference</a></p>
+<h1>{{ .Kind }}</h1>
+<p>Group: <code>{{ .Group }}</code>{{ if ne .CompositeKind .Kind }}<br>Composite: <code>{{ .CompositeKind }}</code>{{ end }}<br>Generated from: <code>{{ .Path }}</code></p>
+{{- $group := .Group }}
+{{- range .Versions }}
+<h2>{{ $group }}/{{ .Name }}{{ if .Referenceable }} (referenceable){{ end }}</h2>
+<table>
+<tr><th>Property</th><th>Type</th><th>Required</th><th>Default</th><th>Enum</th><th>Validation</th><th>Patches to</th><th>Description</th></tr>
+{{- range .Properties }}
+<tr><td style="{{ indent .Depth }}"><code>{{ .Path }}</code></td><td>{{ .Type }}</td><td>{{ if .Required }}yes{{ end }}</td><td>{{ if .Default }}<code>{{ .Default }}</code>{{ end }}</td><td>{{ range .Enum }}<code>{{ . }}</code><br>{{ end }}</td><td>{{ range .Rules }}<code>{{ . }}</code><br>{{ end }}</td><td>{{ range .PatchesTo }}<code>{{ . }}</code><br>{{ end }}</td><td class="description">{{ .Description }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"page": func(d kindDocs) string {
+		return filepath.ToSlash(d.page(".html"))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API reference</title>
+</head>
+<body>
+<h1>API reference</h1>
+<ul>
+{{- range . }}
+<li><a href="{{ page . }}">{{ .Kind }}</a> ({{ .Group }})</li>
+{{- end }}
+</ul>
+</body>
+</html>
+`))
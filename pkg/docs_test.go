@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestGeneratorDocs(t *testing.T) {
+	for _, usePipeline := range []bool{false, true} {
+		g := newTestGenerator(t, t.TempDir(), usePipeline)
+		g.crd.Spec.Names.Kind = "Bucket"
+		g.crd.Spec.Versions[0].Served = true
+		crdSource, err := json.Marshal(g.crd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.crdSource = string(crdSource)
+		gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+		if err := g.CheckConfig(&gConfig); err != nil {
+			t.Fatalf("CheckConfig() error = %v", err)
+		}
+		cwd, _ := os.Getwd()
+		files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		var xrd crossplanev1.CompositeResourceDefinition
+		patches := map[string][]string{}
+		for _, f := range files {
+			switch name := filepath.Base(f.path); {
+			case name == "definition.yaml":
+				if err := yaml.Unmarshal(f.content, &xrd); err != nil {
+					t.Fatal(err)
+				}
+			case strings.HasPrefix(name, "composition"):
+				var composition crossplanev1.Composition
+				if err := yaml.Unmarshal(f.content, &composition); err != nil {
+					t.Fatal(err)
+				}
+				if err := parameterPatches(&composition, patches); err != nil {
+					t.Fatalf("parameterPatches() usePipeline %v error = %v", usePipeline, err)
+				}
+			}
+		}
+
+		d, err := definitionDocs(&xrd, patches)
+		if err != nil {
+			t.Fatalf("definitionDocs() error = %v", err)
+		}
+		if d.Kind != "TestObject" || len(d.Versions) != 1 {
+			t.Fatalf("definitionDocs() usePipeline %v = %+v, want one version of TestObject", usePipeline, d)
+		}
+		var region *propertyDocs
+		for i, property := range d.Versions[0].Properties {
+			if property.Path == "spec.forProvider.region" {
+				region = &d.Versions[0].Properties[i]
+			}
+		}
+		if region == nil {
+			t.Fatalf("definitionDocs() usePipeline %v should document spec.forProvider.region, got %+v", usePipeline, d.Versions[0].Properties)
+		}
+		if want := []string{"Bucket spec.forProvider.region"}; region.Type != "string" || region.Depth != 2 || !reflect.DeepEqual(region.PatchesTo, want) {
+			t.Errorf("definitionDocs() usePipeline %v region = %+v, want a string patched to %v", usePipeline, region, want)
+		}
+	}
+}
+
+func Test_kindDocs_markdown(t *testing.T) {
+	spec := extv1.JSONSchemaProps{
+		Type:     "object",
+		Required: []string{"size"},
+		Properties: map[string]extv1.JSONSchemaProps{
+			"size": {
+				Type:         "string",
+				Description:  "Size of the cluster.",
+				Enum:         enumOf("small", "large"),
+				Default:      &extv1.JSON{Raw: []byte(`"small"`)},
+				XValidations: extv1.ValidationRules{{Rule: "self == oldSelf", Message: "size is immutable"}},
+			},
+			"labels": objectOfStrings,
+			"rules":  arrayOf("from", "to"),
+		},
+	}
+	patches := map[string][]string{"spec.size": {"Cluster spec.forProvider.nodeSize"}}
+	d := kindDocs{Kind: "Cluster", CompositeKind: "XCluster", Group: "example.cloud", Path: "cluster/generate.yaml", Versions: []versionDocs{{
+		Name:          "v1alpha1",
+		Referenceable: true,
+		Properties:    schemaDocs("spec", 0, &spec, true, patches),
+	}}}
+
+	got := d.markdown()
+	for _, want := range []string{
+		"# Cluster\n",
+		"Composite: `XCluster`",
+		"## example.cloud/v1alpha1 (referenceable)\n",
+		"| &nbsp;&nbsp;`spec.size` | string | yes | `\"small\"` | `\"small\"`<br>`\"large\"` | `self == oldSelf (size is immutable)` | `Cluster spec.forProvider.nodeSize` | Size of the cluster. |\n",
+		"| &nbsp;&nbsp;`spec.labels` | map[string]string |",
+		"| &nbsp;&nbsp;`spec.rules` | []object |",
+		"| &nbsp;&nbsp;&nbsp;&nbsp;`spec.rules[*].from` | string |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("markdown() should contain %q, got %s", want, got)
+		}
+	}
+
+	pages, err := docsPages([]kindDocs{d}, "html")
+	if err != nil {
+		t.Fatalf("docsPages() error = %v", err)
+	}
+	page := string(pages[filepath.Join("example.cloud", "cluster.html")])
+	if !strings.Contains(page, "<code>Cluster spec.forProvider.nodeSize</code>") || !strings.Contains(string(pages["index.html"]), `href="example.cloud/cluster.html"`) {
+		t.Errorf("docsPages() = %v", pages)
+	}
+}
+
+func TestGeneratorDocs_Existing(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join("..", "package", "Kafka-Configuration")
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		content, err := os.ReadFile(filepath.Join(source, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, dir, map[string][]byte{e.Name(): content})
+	}
+	// the crd can neither be read nor downloaded
+	loader := &crdLoader{crdDir: filepath.Join(dir, "missing")}
+	args := &arguments{}
+	var out strings.Builder
+	d, err := generatorDocs(filepath.Join(dir, "generate.yaml"), args, &xtype.GeneratorConfig{}, loader, true, &out)
+	if err != nil {
+		t.Fatalf("generatorDocs() error = %v", err)
+	}
+	if d.CompositeKind != "CompositeConfiguration" || len(d.Versions) != 1 || len(d.Versions[0].Properties) == 0 {
+		t.Errorf("generatorDocs() = %s %d versions, want the docs of the existing definition", d.CompositeKind, len(d.Versions))
+	}
+
+	if _, err := generatorDocs(filepath.Join(dir, "generate.yaml"), args, &xtype.GeneratorConfig{}, loader, false, &out); err == nil {
+		t.Errorf("generatorDocs() without existing should fail to load the missing crd")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// Return the examples configuration, the settings of the generate.yaml
+// override the global configuration
+func (g *Generator) examplesConfig(generatorConfig *t.GeneratorConfig) t.ExamplesConfig {
+	config := t.ExamplesConfig{}
+	for _, c := range []*t.ExamplesConfig{generatorConfig.Examples, g.Examples} {
+		if c == nil {
+			continue
+		}
+		if c.Generate != nil {
+			config.Generate = c.Generate
+		}
+		if c.Path != nil {
+			config.Path = c.Path
+		}
+	}
+	return config
+}
+
+// Add a minimal and a full example claim generated from the schema of the
+// rendered definition to the files. The minimal example only contains the
+// required fields.
+func (g *Generator) addExamples(generatorConfig *t.GeneratorConfig, files []generatedFile, outPath string) ([]generatedFile, error) {
+	config := g.examplesConfig(generatorConfig)
+	if config.Generate == nil || !*config.Generate {
+		return files, nil
+	}
+	examplesPath := outPath
+	if config.Path != nil {
+		examplesPath = filepath.Join(*config.Path, filepath.Base(outPath))
+	}
+	for _, f := range files {
+		if filepath.Base(f.path) != "definition.yaml" {
+			continue
+		}
+		var xrd crossplanev1.CompositeResourceDefinition
+		if err := yaml.Unmarshal(f.content, &xrd); err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal xrd")
+		}
+		for _, full := range []bool{false, true} {
+			content, err := exampleClaim(&xrd, g.crossplaneVersion(generatorConfig), full)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot generate example")
+			}
+			name := "example-minimal.yaml"
+			if full {
+				name = "example-full.yaml"
+			}
+			files = append(files, generatedFile{path: filepath.Join(examplesPath, name), content: content})
+		}
+	}
+	return files, nil
+}
+
+// Return an example claim of the referenceable version of the definition, or
+// an example composite for crossplane v2. Only required properties are set
+// unless full is true, descriptions are added as comments.
+func exampleClaim(xrd *crossplanev1.CompositeResourceDefinition, crossplaneVersion t.CrossplaneVersion, full bool) ([]byte, error) {
+	if len(xrd.Spec.Versions) == 0 {
+		return nil, errors.New("the definition has no versions")
+	}
+	version := xrd.Spec.Versions[0]
+	for _, v := range xrd.Spec.Versions {
+		if v.Referenceable {
+			version = v
+		}
+	}
+	schema, err := versionSchema(version)
+	if err != nil {
+		return nil, err
+	}
+	kind := xrd.Spec.Names.Kind
+	if crossplaneVersion != t.CrossplaneV2 && xrd.Spec.ClaimNames != nil {
+		kind = xrd.Spec.ClaimNames.Kind
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "apiVersion: %s/%s\n", xrd.Spec.Group, version.Name)
+	fmt.Fprintf(&sb, "kind: %s\n", kind)
+	sb.WriteString("metadata:\n")
+	fmt.Fprintf(&sb, "  name: example-%s\n", strings.ToLower(kind))
+	sb.WriteString("  namespace: default\n")
+	spec := schema.Properties["spec"]
+	lines, err := exampleProperty("spec", &spec, 0, full)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		sb.WriteString(l + "\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+// Return the lines of a property of an example, including its description as
+// comment
+func exampleProperty(name string, schema *extv1.JSONSchemaProps, indent int, full bool) ([]string, error) {
+	prefix := strings.Repeat(" ", indent)
+	lines := []string{}
+	if description := strings.TrimSpace(strings.SplitN(schema.Description, "\n", 2)[0]); description != "" {
+		lines = append(lines, prefix+"# "+description)
+	}
+	value, err := exampleValue(schema, indent, full)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 1 && !strings.HasPrefix(value[0], " ") && !strings.HasPrefix(value[0], "-") {
+		return append(lines, prefix+name+": "+value[0]), nil
+	}
+	lines = append(lines, prefix+name+":")
+	return append(lines, value...), nil
+}
+
+// Return the lines of the value of a property, a single unindented line is a
+// value on the line of the property
+func exampleValue(schema *extv1.JSONSchemaProps, indent int, full bool) ([]string, error) {
+	switch {
+	case schema.Default != nil:
+		return exampleJSON(schema.Default.Raw)
+	case len(schema.Enum) > 0:
+		return exampleJSON(schema.Enum[0].Raw)
+	}
+	switch schema.Type {
+	case "object":
+		lines := []string{}
+		for _, name := range sortedProperties(schema.Properties) {
+			if !full && !listHas(&schema.Required, name) {
+				continue
+			}
+			property := schema.Properties[name]
+			propertyLines, err := exampleProperty(name, &property, indent+2, full)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, propertyLines...)
+		}
+		if len(lines) == 0 && full && schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+			return exampleProperty("key", schema.AdditionalProperties.Schema, indent+2, full)
+		}
+		if len(lines) == 0 {
+			return []string{"{}"}, nil
+		}
+		return lines, nil
+	case "array":
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return []string{"[]"}, nil
+		}
+		// the item is rendered like a property and its first line is
+		// turned into the list item
+		item, err := exampleValue(schema.Items.Schema, indent, full)
+		if err != nil {
+			return nil, err
+		}
+		if len(item) == 1 && !strings.HasPrefix(item[0], " ") {
+			return []string{strings.Repeat(" ", indent) + "- " + item[0]}, nil
+		}
+		for i, l := range item {
+			if trimmed := strings.TrimLeft(l, " "); !strings.HasPrefix(trimmed, "#") {
+				item[i] = strings.Repeat(" ", indent) + "- " + trimmed
+				break
+			}
+		}
+		return item, nil
+	case "string":
+		return []string{"example"}, nil
+	case "integer", "number":
+		if schema.Minimum != nil {
+			return []string{strconv.FormatFloat(*schema.Minimum, 'f', -1, 64)}, nil
+		}
+		return []string{"1"}, nil
+	case "boolean":
+		return []string{"true"}, nil
+	}
+	return []string{"{}"}, nil
+}
+
+// Return a default or enum value on a single line, objects and arrays in
+// flow style
+func exampleJSON(raw []byte) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return nil, err
+		}
+		return []string{compact.String()}, nil
+	}
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{strings.TrimSpace(string(content))}, nil
+}
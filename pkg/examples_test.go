@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	yamlv3 "gopkg.in/yaml.v3"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerator_RenderExamples(t *testing.T) {
+	for _, usePipeline := range []bool{false, true} {
+		dir := t.TempDir()
+		g := newTestGenerator(t, dir, usePipeline)
+		spec := g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+		spec.Required = []string{"forProvider"}
+		forProvider := spec.Properties["forProvider"]
+		forProvider.Required = []string{"region"}
+		forProvider.Properties["region"] = extv1.JSONSchemaProps{Type: "string", Description: "Region of the bucket.\nMore details."}
+		forProvider.Properties["acl"] = extv1.JSONSchemaProps{Type: "string", Enum: enumOf("private", "public-read")}
+		forProvider.Properties["corsRules"] = arrayOf("method", "origin")
+		spec.Properties["forProvider"] = forProvider
+		g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+		crdSource, err := json.Marshal(g.crd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.crdSource = string(crdSource)
+		generate := true
+		examplesPath := filepath.Join(dir, "examples")
+		g.Examples = &xtype.ExamplesConfig{Generate: &generate, Path: &examplesPath}
+
+		gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+		if err := g.CheckConfig(&gConfig); err != nil {
+			t.Fatalf("CheckConfig() error = %v", err)
+		}
+		cwd, _ := os.Getwd()
+		files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		examples := map[string]string{}
+		for _, f := range files {
+			if filepath.Dir(f.path) == filepath.Join(examplesPath, filepath.Base(dir)) {
+				examples[filepath.Base(f.path)] = string(f.content)
+			}
+		}
+
+		tests := map[string]struct {
+			wantForProvider map[string]interface{}
+		}{
+			"example-minimal.yaml": {
+				wantForProvider: map[string]interface{}{"region": "example"},
+			},
+			"example-full.yaml": {
+				wantForProvider: map[string]interface{}{
+					"acl":       "private",
+					"corsRules": []interface{}{map[string]interface{}{"method": "example", "origin": "example"}},
+					"region":    "example",
+				},
+			},
+		}
+		for name, tt := range tests {
+			content, ok := examples[name]
+			if !ok {
+				t.Fatalf("Render() usePipeline %v should generate %s, got %v", usePipeline, name, examples)
+			}
+			claim := unstructuredFromYAML(t, content)
+			if claim.GetAPIVersion() != "example.cloud/testv1" || claim.GetKind() != "TestObject" {
+				t.Errorf("Render() usePipeline %v %s is a %s %s, want example.cloud/testv1 TestObject", usePipeline, name, claim.GetAPIVersion(), claim.GetKind())
+			}
+			forProvider, _, _ := unstructured.NestedMap(claim.Object, "spec", "forProvider")
+			if !reflect.DeepEqual(forProvider, tt.wantForProvider) {
+				t.Errorf("Render() usePipeline %v %s forProvider = %v, want %v", usePipeline, name, forProvider, tt.wantForProvider)
+			}
+			// only the first line of the description is used as comment
+			if got := keyComment(t, content, "spec", "forProvider", "region"); got != "# Region of the bucket." {
+				t.Errorf("Render() usePipeline %v %s region comment = %q, want %q", usePipeline, name, got, "# Region of the bucket.")
+			}
+		}
+	}
+}
+
+// Return the comment above the key at path of the yaml document
+func keyComment(t *testing.T, content string, path ...string) string {
+	t.Helper()
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(content), &doc); err != nil {
+		t.Fatal(err)
+	}
+	node := doc.Content[0]
+	for i, key := range path {
+		found := false
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value != key {
+				continue
+			}
+			if i == len(path)-1 {
+				return node.Content[j].HeadComment
+			}
+			node, found = node.Content[j+1], true
+			break
+		}
+		if !found {
+			break
+		}
+	}
+	t.Fatalf("no key %s in %s", strings.Join(path, "."), content)
+	return ""
+}
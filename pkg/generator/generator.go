@@ -33,6 +33,7 @@ type XGenerator struct {
 	OverrideFields               []t.OverrideField           `yaml:"overrideFields" json:"overrideFields"`
 	OverrideFieldsInClaim        []t.OverrideFieldInClaim    `yaml:"overrideFieldsInClaim" json:"overrideFieldsInClaim"`
 	Labels                       t.LocalLabelConfig          `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Tags                         t.LocalTagConfig            `yaml:"tags,omitempty" json:"tags,omitempty"`
 	ReadinessChecks              *bool                       `yaml:"readinessChecks,omitempty" json:"readinessChecks,omitempty"`
 	ResourceName                 *string                     `yaml:"resourceName,omitempty" json:"resourceName,omitempty"`
 	UIDFieldPath                 *string                     `yaml:"uidFieldPath,omitempty" json:"uidFieldPath,omitempty"`
@@ -40,12 +41,22 @@ type XGenerator struct {
 	AdditionalPipelineSteps      []t.PipelineStep            `yaml:"additionalPipelineSteps,omitempty" json:"additionalPipelineSteps,omitempty"`
 	TagType                      *string                     `yaml:"tagType,omitempty" json:"tagType,omitempty"`
 	TagProperty                  *string                     `yaml:"tagProperty,omitempty" json:"tagProperty,omitempty"`
+	TagKeyField                  *string                     `yaml:"tagKeyField,omitempty" json:"tagKeyField,omitempty"`
+	TagValueField                *string                     `yaml:"tagValueField,omitempty" json:"tagValueField,omitempty"`
 	AutoReadyFunction            *t.AutoReadyFunction        `yaml:"autoReadyFunction,omitempty" json:"autoReadyFunction,omitempty"`
 	PatchAndTransfromFunction    *string                     `yaml:"patchAndTransfromFunction,omitempty" json:"patchAndTransfromFunction,omitempty"`
+	CompositionBackend           t.CompositionBackend        `yaml:"compositionBackend,omitempty" json:"compositionBackend,omitempty"`
+	GoTemplatingFunction         *string                     `yaml:"goTemplatingFunction,omitempty" json:"goTemplatingFunction,omitempty"`
+	KCLFunction                  *string                     `yaml:"kclFunction,omitempty" json:"kclFunction,omitempty"`
 	DefaultCompositeDeletePolicy *string                     `yaml:"defaultCompositeDeletePolicy,omitempty" json:"defaultCompositeDeletePolicy,omitempty"`
+	CrossplaneVersion            *t.CrossplaneVersion        `yaml:"crossplaneVersion,omitempty" json:"crossplaneVersion,omitempty"`
 
 	GlobalLabels             []string
 	GeneratorConfig          t.GeneratorConfig
+	InitProviderFields       []string
+	Resources                []Resource
+	ServedVersions           []t.XRDVersion
+	References               []t.ResourceReference
 	xrdSchema                *v1.JSONSchemaProps
 	overrideFieldDefinitions []*OverrideFieldDefinition
 }
@@ -73,25 +84,23 @@ func (g *XGenerator) GenerateXRD() (*c.CompositeResourceDefinition, error) {
 	plural := g.nameToPlural()
 	defaultCompositionName, _ := g.getDefaultCompositionName()
 	version, _ := g.getVersion()
-	status, err := g.generateSchema("status")
+	status, err := g.generateStatusSchema()
 	if err != nil {
 		return nil, err
 	}
-	status.Properties["observed"] = v1.JSONSchemaProps{
-		Description:            "Freeform field containing information about the observed status.",
-		Type:                   "object",
-		XPreserveUnknownFields: pointer(true),
-	}
-	status.Properties["uid"] = v1.JSONSchemaProps{
-		Description: fmt.Sprintf("The unique ID of this %s resource reported by the provider", g.Name),
-		Type:        "string",
-	}
 	g.overrideFieldDefinitions = mapOverwrittenFields(g.OverrideFieldsInClaim)
 	specSchema, err := g.generateSchema("spec")
 	if err != nil {
 		return nil, err
 	}
 	g.xrdSchema = specSchema
+	xrdSpec := g.xrdSchema
+	if len(g.Resources) > 0 {
+		xrdSpec = g.xrdSchema.DeepCopy()
+		if err := g.addResourceSchemas(xrdSpec, status); err != nil {
+			return nil, err
+		}
+	}
 	xrd := c.CompositeResourceDefinition{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apiextensions.crossplane.io/v1",
@@ -110,7 +119,7 @@ func (g *XGenerator) GenerateXRD() (*c.CompositeResourceDefinition, error) {
 			},
 			Group: g.Group,
 			Names: v1.CustomResourceDefinitionNames{
-				Kind:       "Composite" + g.Name,
+				Kind:       g.compositeKind(),
 				Plural:     "composite" + plural,
 				Categories: g.generateCategories(),
 			},
@@ -124,7 +133,7 @@ func (g *XGenerator) GenerateXRD() (*c.CompositeResourceDefinition, error) {
 							Object: &unstructured.Unstructured{
 								Object: map[string]interface{}{
 									"properties": map[string]interface{}{
-										"spec":   g.xrdSchema,
+										"spec":   xrdSpec,
 										"status": status,
 									},
 								},
@@ -141,6 +150,22 @@ func (g *XGenerator) GenerateXRD() (*c.CompositeResourceDefinition, error) {
 	// g.generateSchema()
 	//
 
+	for _, served := range g.ServedVersions {
+		version, err := g.generateServedVersion(served)
+		if err != nil {
+			return nil, err
+		}
+		xrd.Spec.Versions = append(xrd.Spec.Versions, *version)
+	}
+
+	if g.namespaced() {
+		// the composite is used directly, there is no claim
+		xrd.APIVersion = "apiextensions.crossplane.io/v2"
+		xrd.Name = g.fqdn()
+		xrd.Spec.ClaimNames = nil
+		xrd.Spec.Names.Plural = plural
+	}
+
 	if g.ConnectionSecretKeys != nil {
 		xrd.Spec.ConnectionSecretKeys = *g.ConnectionSecretKeys
 	}
@@ -149,6 +174,66 @@ func (g *XGenerator) GenerateXRD() (*c.CompositeResourceDefinition, error) {
 	return &xrd, nil
 }
 
+// generateStatusSchema returns the status of the composite, which contains the
+// status of the resource, its uid and the observed conditions
+func (g *XGenerator) generateStatusSchema() (*v1.JSONSchemaProps, error) {
+	status, err := g.generateSchema("status")
+	if err != nil {
+		return nil, err
+	}
+	status.Properties["observed"] = v1.JSONSchemaProps{
+		Description:            "Freeform field containing information about the observed status.",
+		Type:                   "object",
+		XPreserveUnknownFields: pointer(true),
+	}
+	status.Properties["uid"] = v1.JSONSchemaProps{
+		Description: fmt.Sprintf("The unique ID of this %s resource reported by the provider", g.Name),
+		Type:        "string",
+	}
+	return status, nil
+}
+
+// generateServedVersion returns a served version of the xrd, which isn't
+// referenceable, generated from the given version of the crd
+func (g *XGenerator) generateServedVersion(served t.XRDVersion) (*c.CompositeResourceDefinitionVersion, error) {
+	vg := *g
+	vg.Provider.CRD.Version = served.CRDVersion
+	vg.overrideFieldDefinitions = mapOverwrittenFields(g.OverrideFieldsInClaim)
+	version, err := vg.getVersion()
+	if err != nil {
+		return nil, err
+	}
+	status, err := vg.generateStatusSchema()
+	if err != nil {
+		return nil, err
+	}
+	spec, err := vg.generateSchema("spec")
+	if err != nil {
+		return nil, err
+	}
+	if err := vg.addResourceSchemas(spec, status); err != nil {
+		return nil, err
+	}
+	return &c.CompositeResourceDefinitionVersion{
+		Name:          served.Name,
+		Referenceable: false,
+		Served:        true,
+		Schema: &c.CompositeResourceValidation{
+			OpenAPIV3Schema: runtime.RawExtension{
+				Object: &unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"properties": map[string]interface{}{
+							"spec":   spec,
+							"status": status,
+						},
+					},
+				},
+			},
+		},
+		AdditionalPrinterColumns: filterCustomResourceColumnDefinitions(version.AdditionalPrinterColumns),
+	}, nil
+}
+
 func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 	compositions := []NamedComposition{}
 
@@ -188,7 +273,7 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 			Spec: c.CompositionSpec{
 				CompositeTypeRef: c.TypeReference{
 					APIVersion: g.Group + "/" + g.Version,
-					Kind:       "Composite" + g.Name,
+					Kind:       g.compositeKind(),
 				},
 				Mode: pointer(c.CompositionModePipeline),
 			},
@@ -197,6 +282,10 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 		patchSets := []p.PatchSet{}
 
 		if g.PatchlName == nil || *g.PatchlName {
+			fromFieldPath := "metadata.labels[crossplane.io/claim-name]"
+			if g.namespaced() {
+				fromFieldPath = "metadata.name"
+			}
 			var toFieldPath string
 			if g.PatchExternalName != nil && !*g.PatchExternalName {
 				toFieldPath = "metadata.name"
@@ -209,7 +298,7 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 					{
 						Type: p.PatchTypeFromCompositeFieldPath,
 						Patch: p.Patch{
-							FromFieldPath: pointer("metadata.labels[crossplane.io/claim-name]"),
+							FromFieldPath: &fromFieldPath,
 							ToFieldPath:   &toFieldPath,
 						},
 					},
@@ -264,6 +353,12 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 			patchSets = append(patchSets, labelPatchset)
 		}
 
+		tagPatchset := g.generateTagPatchset()
+
+		if len(tagPatchset.Patches) > 0 {
+			patchSets = append(patchSets, tagPatchset)
+		}
+
 		// composition.Spec.PatchSets = patchSets
 
 		for _, ps := range patchSets {
@@ -273,6 +368,12 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 			})
 		}
 
+		resourcePatchSets, resourceTemplates, err := g.generateResources(comp, labelPatchset)
+		if err != nil {
+			return nil, err
+		}
+		patchSets = append(patchSets, resourcePatchSets...)
+
 		resource.Patches = append(resource.Patches, p.ComposedPatch{
 			Patch: p.Patch{
 				FromFieldPath: pointer(g.getUidFieldPath()),
@@ -294,6 +395,8 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 			Type: p.PatchTypeToCompositeFieldPath,
 		})
 
+		resource.Patches = append(resource.Patches, g.referencePatches(g.References)...)
+
 		if g.ConnectionSecretKeys != nil {
 			composition.Spec.WriteConnectionSecretsToNamespace = pointer("crossplane-system")
 			resource.Patches = append(resource.Patches, p.ComposedPatch{
@@ -329,40 +432,28 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 		// 	resource,
 		// }
 
-		name = "function-patch-and-transform"
-		if g.PatchAndTransfromFunction != nil {
-			name = *g.PatchAndTransfromFunction
-		}
-		patchAndTransform := c.PipelineStep{
-			Step: "patch-and-transform",
-			FunctionRef: c.FunctionReference{
-				Name: name,
-			},
-		}
-
 		patchAndTransformResource := p.Resources{
 			TypeMeta: metav1.TypeMeta{
 				APIVersion: "pt.fn.crossplane.io/v1beta1",
 				Kind:       "Resources",
 			},
 			PatchSets: patchSets,
-			Resources: []p.ComposedTemplate{resource},
+			Resources: append([]p.ComposedTemplate{resource}, resourceTemplates...),
 		}
 
-		patchAndTransformRaw := map[string]interface{}{
-			"apiVersion": patchAndTransformResource.APIVersion,
-			"kind":       patchAndTransformResource.Kind,
-			"patchSets":  patchAndTransformResource.PatchSets,
-			"resources":  patchAndTransformResource.Resources,
+		var step *c.PipelineStep
+		switch g.CompositionBackend {
+		case t.CompositionBackendGoTemplating:
+			step, err = g.goTemplatingStep(patchAndTransformResource)
+		case t.CompositionBackendKCL:
+			step, err = g.kclStep(patchAndTransformResource)
+		default:
+			step, err = g.patchAndTransformStep(patchAndTransformResource)
 		}
-		raw, err := json.Marshal(patchAndTransformRaw)
 		if err != nil {
 			return nil, err
 		}
-		patchAndTransform.Input = &runtime.RawExtension{
-			Raw: raw,
-		}
-		composition.Spec.Pipeline = append(composition.Spec.Pipeline, patchAndTransform)
+		composition.Spec.Pipeline = append(composition.Spec.Pipeline, *step)
 
 		if g.AdditionalPipelineSteps != nil {
 			startSteps := []c.PipelineStep{}
@@ -401,6 +492,34 @@ func (g *XGenerator) GenerateComposition() ([]NamedComposition, error) {
 	return compositions, nil
 }
 
+// patchAndTransformStep returns the pipeline step rendering the resources with
+// function-patch-and-transform
+func (g *XGenerator) patchAndTransformStep(input p.Resources) (*c.PipelineStep, error) {
+	name := "function-patch-and-transform"
+	if g.PatchAndTransfromFunction != nil {
+		name = *g.PatchAndTransfromFunction
+	}
+	patchAndTransformRaw := map[string]interface{}{
+		"apiVersion": input.APIVersion,
+		"kind":       input.Kind,
+		"patchSets":  input.PatchSets,
+		"resources":  input.Resources,
+	}
+	raw, err := json.Marshal(patchAndTransformRaw)
+	if err != nil {
+		return nil, err
+	}
+	return &c.PipelineStep{
+		Step: "patch-and-transform",
+		FunctionRef: c.FunctionReference{
+			Name: name,
+		},
+		Input: &runtime.RawExtension{
+			Raw: raw,
+		},
+	}, nil
+}
+
 func (g *XGenerator) updateKubernetesValidation(schema *v1.JSONSchemaProps) {
 
 	kubernetesValidations := schema.XValidations
@@ -447,7 +566,7 @@ func (g *XGenerator) generatePropertyPatchesFor(schema v1.JSONSchemaProps, path
 		if definition != nil {
 			toFieldPath = definition.ManagedPath
 		} else {
-			toFieldPath = path
+			toFieldPath = g.managedPath(path)
 		}
 		definitionPatches := getPatchesFromDefinition(definition, patchType)
 		if len(definitionPatches) > 0 {
@@ -543,6 +662,132 @@ func generateLabelPatchset(name string, fields []string) p.PatchSet {
 	}
 }
 
+// tagPath returns the path of the tags of the managed resource below spec,
+// tag properties without path are below spec.forProvider
+func (g *XGenerator) tagPath() string {
+	if g.TagProperty == nil {
+		return "forProvider.tags"
+	}
+	if path, ok := strings.CutPrefix(*g.TagProperty, "spec."); ok {
+		return path
+	}
+	if *g.TagProperty == "tagSet" {
+		return "forProvider.tagging.tagSet"
+	}
+	return "forProvider." + *g.TagProperty
+}
+
+// managedPath returns the path in the managed resource a property of the
+// spec of the composite is patched to, the InitProviderFields merged from
+// spec.initProvider into spec.forProvider are patched to spec.initProvider
+func (g *XGenerator) managedPath(path string) string {
+	for _, f := range g.InitProviderFields {
+		if path == f || strings.HasPrefix(path, f+".") || strings.HasPrefix(path, f+"[") {
+			return "spec.initProvider" + strings.TrimPrefix(path, "spec.forProvider")
+		}
+	}
+	return path
+}
+
+func (g *XGenerator) tagType() string {
+	if g.TagType == nil || g.TagProperty == nil || *g.TagProperty == "" {
+		return "noTag"
+	}
+	return *g.TagType
+}
+
+// tagFields returns the names of the key and the value of the items of array tags
+func (g *XGenerator) tagFields() (string, string) {
+	keyField, valueField := "key", "value"
+	if g.tagType() == "tagKeyTagValueArray" {
+		keyField, valueField = "tagKey", "tagValue"
+	}
+	if g.TagKeyField != nil {
+		keyField = *g.TagKeyField
+	}
+	if g.TagValueField != nil {
+		valueField = *g.TagValueField
+	}
+	return keyField, valueField
+}
+
+// generateTags returns the tags of the base of the managed resource: the keys
+// of tags.fromLabels, patched by the Tags patchset, and the tags of tags.common
+func (g *XGenerator) generateTags() interface{} {
+	common := make([]string, 0, len(g.Tags.Common))
+	for key := range g.Tags.Common {
+		common = append(common, key)
+	}
+	sort.Strings(common)
+
+	switch g.tagType() {
+	case "keyValueArray", "tagKeyTagValueArray":
+		keyField, valueField := g.tagFields()
+		tags := []interface{}{}
+		for _, tag := range g.Tags.FromLabels {
+			tags = append(tags, map[string]string{keyField: tag})
+		}
+		for _, key := range common {
+			tags = append(tags, map[string]string{keyField: key, valueField: g.Tags.Common[key]})
+		}
+		if len(tags) > 0 {
+			return tags
+		}
+	case "tagObject":
+		if len(common) > 0 {
+			tags := map[string]string{}
+			for _, key := range common {
+				tags[key] = g.Tags.Common[key]
+			}
+			return tags
+		}
+	}
+	return nil
+}
+
+// generateTagPatchset patches the value of each tag in tags.fromLabels from
+// the label with the same name
+func (g *XGenerator) generateTagPatchset() p.PatchSet {
+	path := "spec." + g.tagPath()
+	fromFields := fieldsTo("metadata.labels", g.Tags.FromLabels)
+	toFields := []string{}
+	policies := []p.FromFieldPathPolicy{}
+
+	for i, tag := range g.Tags.FromLabels {
+		switch g.tagType() {
+		case "keyValueArray", "tagKeyTagValueArray":
+			_, valueField := g.tagFields()
+			toFields = append(toFields, fmt.Sprintf("%s[%d].%s", path, i, valueField))
+			policies = append(policies, p.FromFieldPathPolicyRequired)
+		case "tagObject":
+			toFields = append(toFields, fmt.Sprintf("%s['%s']", path, tag))
+			policies = append(policies, p.FromFieldPathPolicyOptional)
+		}
+	}
+	if len(toFields) == 0 {
+		return p.PatchSet{Name: "Tags"}
+	}
+	patches, _ := generatePatches(fromFields, toFields, policies, p.PatchTypeFromCompositeFieldPath)
+
+	return p.PatchSet{
+		Name:    "Tags",
+		Patches: patches,
+	}
+}
+
+// setPath sets the value at the path in the object, missing objects are created
+func setPath(object map[string]interface{}, path []string, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		next, ok := object[segment].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			object[segment] = next
+		}
+		object = next
+	}
+	object[path[len(path)-1]] = value
+}
+
 func (g *XGenerator) nameToPlural() string {
 	if g.Plural != nil {
 		return strings.ToLower(*g.Plural)
@@ -557,6 +802,20 @@ func (g *XGenerator) nameToPlural() string {
 	return lname
 }
 
+// namespaced returns if a namespaced composite without a claim is generated
+func (g *XGenerator) namespaced() bool {
+	return g.CrossplaneVersion != nil && *g.CrossplaneVersion == t.CrossplaneV2
+}
+
+// compositeKind returns the kind of the composite, which is prefixed with
+// Composite unless there is no claim
+func (g *XGenerator) compositeKind() string {
+	if g.namespaced() {
+		return g.Name
+	}
+	return "Composite" + g.Name
+}
+
 func (g *XGenerator) fqdn() string {
 
 	plural := g.nameToPlural()
@@ -685,10 +944,16 @@ func (g *XGenerator) getIgnored() []string {
 		"spec.forProvider.tags",
 		"spec.forProvider.tagSpecifications",
 		"spec.forProvider.tagging",
+		"spec.initProvider.tags",
+		"spec.initProvider.tagSpecifications",
+		"spec.initProvider.tagging",
 		"spec.providerConfigRef.default",
 		"spec.providerRef",
 		"spec.publishConnectionDetailsTo.configRef.default",
 	}
+	if g.tagType() != "noTag" {
+		defaultIgnored = append(defaultIgnored, "spec."+g.tagPath())
+	}
 	for _, o := range g.OverrideFields {
 		if o.Ignore {
 			defaultIgnored = append(defaultIgnored, o.Path)
@@ -732,6 +997,10 @@ func (g *XGenerator) generateBase(comp t.Composition) []byte {
 		base["metadata"].(map[string]interface{})["labels"] = commonLabels
 	}
 
+	if tags := g.generateTags(); tags != nil {
+		setPath(baseSpec, strings.Split(g.tagPath(), "."), tags)
+	}
+
 	if g.ConnectionSecretKeys != nil {
 		base["spec"].(map[string]interface{})["writeConnectionSecretToRef"] = map[string]string{
 			"namespace": "crossplane-system",
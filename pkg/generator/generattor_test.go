@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	tp "github.com/crossplane-contrib/x-generation/pkg/types"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 )
 
 func Test_generateOverrideFields(t *testing.T) {
@@ -128,3 +129,93 @@ func Test_generateOverrideFields(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateTags(t *testing.T) {
+	tags := tp.LocalTagConfig{
+		TagConfig: tp.TagConfig{
+			FromLabels: []string{"tags.example.cloud/account", "tags.example.cloud/zone"},
+			Common:     map[string]string{"commonTagB": "b", "commonTagA": "a"},
+		},
+	}
+	tests := []struct {
+		name        string
+		tagType     string
+		tagProperty string
+		wantBase    string
+		wantPatches string
+	}{
+		{
+			name:        "Should generate key value tags",
+			tagType:     "keyValueArray",
+			tagProperty: "spec.forProvider.tags",
+			wantBase:    `{"forProvider":{"tags":[{"key":"tags.example.cloud/account"},{"key":"tags.example.cloud/zone"},{"key":"commonTagA","value":"a"},{"key":"commonTagB","value":"b"}]},"providerConfigRef":{"name":"default"}}`,
+			wantPatches: `[{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/account']","toFieldPath":"spec.forProvider.tags[0].value","policy":{"fromFieldPath":"Required"}},{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/zone']","toFieldPath":"spec.forProvider.tags[1].value","policy":{"fromFieldPath":"Required"}}]`,
+		},
+		{
+			name:        "Should generate tagKey tagValue tags in tagSet",
+			tagType:     "tagKeyTagValueArray",
+			tagProperty: "spec.forProvider.tagging.tagSet",
+			wantBase:    `{"forProvider":{"tagging":{"tagSet":[{"tagKey":"tags.example.cloud/account"},{"tagKey":"tags.example.cloud/zone"},{"tagKey":"commonTagA","tagValue":"a"},{"tagKey":"commonTagB","tagValue":"b"}]}},"providerConfigRef":{"name":"default"}}`,
+			wantPatches: `[{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/account']","toFieldPath":"spec.forProvider.tagging.tagSet[0].tagValue","policy":{"fromFieldPath":"Required"}},{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/zone']","toFieldPath":"spec.forProvider.tagging.tagSet[1].tagValue","policy":{"fromFieldPath":"Required"}}]`,
+		},
+		{
+			name:        "Should generate tag objects",
+			tagType:     "tagObject",
+			tagProperty: "spec.forProvider.tags",
+			wantBase:    `{"forProvider":{"tags":{"commonTagA":"a","commonTagB":"b"}},"providerConfigRef":{"name":"default"}}`,
+			wantPatches: `[{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/account']","toFieldPath":"spec.forProvider.tags['tags.example.cloud/account']","policy":{"fromFieldPath":"Optional"}},{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/zone']","toFieldPath":"spec.forProvider.tags['tags.example.cloud/zone']","policy":{"fromFieldPath":"Optional"}}]`,
+		},
+		{
+			name:        "Should generate tags in initProvider",
+			tagType:     "tagObject",
+			tagProperty: "spec.initProvider.tags",
+			wantBase:    `{"initProvider":{"tags":{"commonTagA":"a","commonTagB":"b"}},"providerConfigRef":{"name":"default"}}`,
+			wantPatches: `[{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/account']","toFieldPath":"spec.initProvider.tags['tags.example.cloud/account']","policy":{"fromFieldPath":"Optional"}},{"type":"FromCompositeFieldPath","fromFieldPath":"metadata.labels['tags.example.cloud/zone']","toFieldPath":"spec.initProvider.tags['tags.example.cloud/zone']","policy":{"fromFieldPath":"Optional"}}]`,
+		},
+		{
+			name:        "Should not generate tags without tags",
+			tagType:     "noTag",
+			tagProperty: "",
+			wantBase:    `{"providerConfigRef":{"name":"default"}}`,
+			wantPatches: `null`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &XGenerator{
+				Tags:        tags,
+				TagType:     &tt.tagType,
+				TagProperty: &tt.tagProperty,
+				Provider:    tp.ProviderConfig{CRD: tp.CrdConfig{Version: "v1"}},
+				Crd: v1.CustomResourceDefinition{
+					Spec: v1.CustomResourceDefinitionSpec{
+						Versions: []v1.CustomResourceDefinitionVersion{{
+							Name: "v1",
+							Schema: &v1.CustomResourceValidation{
+								OpenAPIV3Schema: &v1.JSONSchemaProps{
+									Properties: map[string]v1.JSONSchemaProps{
+										"spec": {Properties: map[string]v1.JSONSchemaProps{"providerConfigRef": {}}},
+									},
+								},
+							},
+						}},
+					},
+				},
+			}
+			var base map[string]json.RawMessage
+			if err := json.Unmarshal(g.generateBase(tp.Composition{}), &base); err != nil {
+				t.Fatal(err)
+			}
+			if string(base["spec"]) != tt.wantBase {
+				t.Errorf("generateBase() spec = %s, want %s", base["spec"], tt.wantBase)
+			}
+			patches, err := json.Marshal(g.generateTagPatchset().Patches)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(patches) != tt.wantPatches {
+				t.Errorf("generateTagPatchset() = %s, want %s", patches, tt.wantPatches)
+			}
+		})
+	}
+}
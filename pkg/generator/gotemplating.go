@@ -0,0 +1,330 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	c "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	goTemplatingAPIVersion      = "gotemplating.fn.crossplane.io/v1beta1"
+	connectionDetailsAPIVersion = "meta.gotemplating.fn.crossplane.io/v1alpha1"
+	resourceNameAnnotation      = "gotemplating.fn.crossplane.io/composition-resource-name"
+	readyAnnotation             = "gotemplating.fn.crossplane.io/ready"
+)
+
+// goTemplatingStep returns the pipeline step rendering the resources with an
+// inline template of function-go-templating. The template is translated from
+// the patch-and-transform input, so the override, ignore, rename and enum
+// semantics of both backends are the same.
+func (g *XGenerator) goTemplatingStep(input p.Resources) (*c.PipelineStep, error) {
+	template, err := g.goTemplate(input)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(map[string]interface{}{
+		"apiVersion": goTemplatingAPIVersion,
+		"kind":       "GoTemplate",
+		"source":     "Inline",
+		"inline": map[string]string{
+			"template": template,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	name := "function-go-templating"
+	if g.GoTemplatingFunction != nil {
+		name = *g.GoTemplatingFunction
+	}
+	return &c.PipelineStep{
+		Step: "go-templating",
+		FunctionRef: c.FunctionReference{
+			Name: name,
+		},
+		Input: &runtime.RawExtension{
+			Raw: raw,
+		},
+	}, nil
+}
+
+// goTemplate collects the sections of the template, the status patched to the
+// composite and its connection details are shared by all resources
+type goTemplate struct {
+	sections          strings.Builder
+	composite         map[string]interface{}
+	connectionDetails bool
+}
+
+// goTemplate translates the resources and their patches into a template. The
+// template renders a document for each resource, the composite with its
+// status and, if there are any, the connection details of the composite.
+func (g *XGenerator) goTemplate(input p.Resources) (string, error) {
+	patchSets := map[string][]p.PatchSetPatch{}
+	for _, ps := range input.PatchSets {
+		patchSets[ps.Name] = ps.Patches
+	}
+	gt := &goTemplate{
+		composite: map[string]interface{}{
+			"apiVersion": g.Group + "/" + g.Version,
+			"kind":       g.compositeKind(),
+		},
+	}
+	for _, r := range input.Resources {
+		if err := gt.resource(r, patchSets); err != nil {
+			return "", fmt.Errorf("resource %s: %w", r.Name, err)
+		}
+	}
+
+	composite, err := templateJSON(gt.composite)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("{{- $xr := .observed.composite.resource }}\n")
+	fmt.Fprintf(&b, "{{- $composite := fromJson %s }}\n", composite)
+	if gt.connectionDetails {
+		b.WriteString("{{- $details := dict }}\n")
+	}
+	b.WriteString(gt.sections.String())
+	b.WriteString("---\n{{ toJson $composite }}\n")
+	if gt.connectionDetails {
+		fmt.Fprintf(&b, "---\n{{ toJson (dict \"apiVersion\" %q \"kind\" \"CompositeConnectionDetails\" \"data\" $details) }}\n", connectionDetailsAPIVersion)
+	}
+	return b.String(), nil
+}
+
+// resource adds the section rendering the resource. The patches from the
+// composite are applied to the base, the patches to the composite read the
+// observed resource. The resource is not rendered if a required field of
+// the composite is missing.
+func (gt *goTemplate) resource(r p.ComposedTemplate, patchSets map[string][]p.PatchSetPatch) error {
+	base := map[string]interface{}{}
+	if r.Base != nil {
+		if err := json.Unmarshal(r.Base.Raw, &base); err != nil {
+			return fmt.Errorf("cannot unmarshal base: %w", err)
+		}
+	}
+	annotations := map[string]interface{}{resourceNameAnnotation: r.Name}
+	for _, check := range r.ReadinessChecks {
+		if check.Type == p.ReadinessCheckTypeNone {
+			annotations[readyAnnotation] = "True"
+		}
+	}
+	mergeAnnotations(base, annotations)
+
+	patches, err := resourcePatches(r, patchSets)
+	if err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	observed, required := false, false
+	for _, patch := range patches {
+		switch patch.Type {
+		case p.PatchTypeFromCompositeFieldPath, "":
+			isRequired := requiredPatch(patch.Patch)
+			required = required || isRequired
+			err = writePatch(&body, patch.Patch, "$xr", "$resource", base, isRequired)
+		case p.PatchTypeToCompositeFieldPath:
+			observed = true
+			err = writePatch(&body, patch.Patch, "$observed", "$composite", gt.composite, false)
+		default:
+			err = fmt.Errorf("patches of type %s are not supported by the go-templating backend", patch.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, detail := range r.ConnectionDetails {
+		if detail.Type != p.ConnectionDetailTypeFromConnectionSecretKey || detail.FromConnectionSecretKey == nil {
+			return fmt.Errorf("connection details of type %s are not supported by the go-templating backend", detail.Type)
+		}
+		gt.connectionDetails = true
+		fmt.Fprintf(&body, "{{- $value := dig \"observed\" \"resources\" %q \"connectionDetails\" %q nil $ }}\n", r.Name, *detail.FromConnectionSecretKey)
+		body.WriteString("{{- if not (kindIs \"invalid\" $value) }}\n")
+		fmt.Fprintf(&body, "{{- $_ := set $details %q $value }}\n", detail.Name)
+		body.WriteString("{{- end }}\n")
+	}
+
+	resource, err := templateJSON(base)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&gt.sections, "{{- /* %s */}}\n", r.Name)
+	fmt.Fprintf(&gt.sections, "{{- $resource := fromJson %s }}\n", resource)
+	if observed {
+		fmt.Fprintf(&gt.sections, "{{- $observed := dig \"observed\" \"resources\" %q \"resource\" (dict) $ }}\n", r.Name)
+	}
+	if required {
+		gt.sections.WriteString("{{- $render := true }}\n")
+	}
+	gt.sections.WriteString(body.String())
+	if required {
+		gt.sections.WriteString("{{- if $render }}\n---\n{{ toJson $resource }}\n{{- end }}\n")
+	} else {
+		gt.sections.WriteString("---\n{{ toJson $resource }}\n")
+	}
+	return nil
+}
+
+// resourcePatches returns the patches of the resource with the patches of
+// the referenced patch sets in their place
+func resourcePatches(r p.ComposedTemplate, patchSets map[string][]p.PatchSetPatch) ([]p.PatchSetPatch, error) {
+	patches := []p.PatchSetPatch{}
+	for _, patch := range r.Patches {
+		if patch.Type == p.PatchTypePatchSet {
+			ps, ok := patchSets[*patch.PatchSetName]
+			if !ok {
+				return nil, fmt.Errorf("cannot find patchset %s", *patch.PatchSetName)
+			}
+			patches = append(patches, ps...)
+			continue
+		}
+		patches = append(patches, p.PatchSetPatch{Type: patch.Type, Patch: patch.Patch})
+	}
+	return patches, nil
+}
+
+// requiredPatch returns if the resource is not rendered without the value of
+// the patch
+func requiredPatch(patch p.Patch) bool {
+	return patch.Policy.GetFromFieldPathPolicy() == p.FromFieldPathPolicyRequired
+}
+
+// writePatch writes the statements copying the value at the from field path of
+// source to the to field path of target, which is rendered from object. The
+// objects containing the field are added when the value is set, arrays must
+// exist in object. A missing value is not copied, if it is required rendering
+// the resource is skipped.
+func writePatch(w *strings.Builder, patch p.Patch, source, target string, object map[string]interface{}, required bool) error {
+	if patch.FromFieldPath == nil || patch.ToFieldPath == nil {
+		return errors.New("patches without fromFieldPath or toFieldPath are not supported by the go-templating backend")
+	}
+	from, err := fieldpath.Parse(*patch.FromFieldPath)
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %w", *patch.FromFieldPath, err)
+	}
+	keys := []string{}
+	for _, s := range from {
+		if s.Type != fieldpath.SegmentField || s.Field == "*" {
+			return fmt.Errorf("patch from %s: arrays are not supported by the go-templating backend", *patch.FromFieldPath)
+		}
+		keys = append(keys, strconv.Quote(s.Field))
+	}
+	to, err := fieldpath.Parse(*patch.ToFieldPath)
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %w", *patch.ToFieldPath, err)
+	}
+	if len(to) == 0 || to[len(to)-1].Type != fieldpath.SegmentField {
+		return fmt.Errorf("patch to %s: only fields of objects are supported by the go-templating backend", *patch.ToFieldPath)
+	}
+
+	// the statements adding the objects missing in object and the expression
+	// of the object containing the field
+	var parents strings.Builder
+	indexes := []string{target}
+	var value interface{} = object
+	for i, s := range to {
+		parent := indexes[0]
+		if len(indexes) > 1 {
+			parent = "(index " + strings.Join(indexes, " ") + ")"
+		}
+		if i == len(to)-1 {
+			fmt.Fprintf(&parents, "{{- $_ := set %s %q $value }}\n", parent, s.Field)
+			break
+		}
+		switch {
+		case s.Type == fieldpath.SegmentIndex:
+			array, ok := value.([]interface{})
+			if !ok || int(s.Index) >= len(array) {
+				return fmt.Errorf("patch to %s: arrays must exist in the base with the go-templating backend", *patch.ToFieldPath)
+			}
+			value = array[s.Index]
+			indexes = append(indexes, strconv.FormatUint(uint64(s.Index), 10))
+			continue
+		case s.Field == "*":
+			return fmt.Errorf("patch to %s: wildcards are not supported by the go-templating backend", *patch.ToFieldPath)
+		}
+		if o, ok := value.(map[string]interface{}); ok && o[s.Field] != nil {
+			value = o[s.Field]
+		} else {
+			value = nil
+			fmt.Fprintf(&parents, "{{- if not (hasKey %s %q) }}{{ $_ := set %s %q (dict) }}{{ end }}\n", parent, s.Field, parent, s.Field)
+		}
+		indexes = append(indexes, strconv.Quote(s.Field))
+	}
+
+	fmt.Fprintf(w, "{{- $value := dig %s nil %s }}\n", strings.Join(keys, " "), source)
+	if len(patch.Transforms) > 0 {
+		w.WriteString("{{- if not (kindIs \"invalid\" $value) }}\n")
+		for _, transform := range patch.Transforms {
+			if err := writeTransform(w, transform); err != nil {
+				return fmt.Errorf("patch to %s: %w", *patch.ToFieldPath, err)
+			}
+		}
+		w.WriteString("{{- end }}\n")
+	}
+	w.WriteString("{{- if not (kindIs \"invalid\" $value) }}\n")
+	w.WriteString(parents.String())
+	if required {
+		w.WriteString("{{- else }}\n{{- $render = false }}\n")
+	}
+	w.WriteString("{{- end }}\n")
+	return nil
+}
+
+// writeTransform writes the statement transforming $value, map transforms and
+// string formats are supported
+func writeTransform(w *strings.Builder, transform p.Transform) error {
+	switch {
+	case transform.Type == p.TransformTypeMap && transform.Map != nil:
+		pairs, err := templateJSON(transform.Map.Pairs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "{{- $value = index (fromJson %s) (toString $value) }}\n", pairs)
+	case transform.Type == p.TransformTypeString && transform.String != nil && transform.String.Type == p.StringTransformTypeFormat && transform.String.Format != nil:
+		fmt.Fprintf(w, "{{- $value = printf %s $value }}\n", strconv.Quote(*transform.String.Format))
+	default:
+		return fmt.Errorf("transforms of type %s are not supported by the go-templating backend", transform.Type)
+	}
+	return nil
+}
+
+// mergeAnnotations adds the annotations to the metadata of the base
+func mergeAnnotations(base map[string]interface{}, annotations map[string]interface{}) {
+	metadata, ok := base["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		base["metadata"] = metadata
+	}
+	existing, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		existing = map[string]interface{}{}
+		metadata["annotations"] = existing
+	}
+	for k, v := range annotations {
+		existing[k] = v
+	}
+}
+
+// templateJSON returns the value as JSON string literal of a template, a raw
+// string unless the JSON contains a backtick
+func templateJSON(value interface{}) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(string(raw), "`") {
+		return strconv.Quote(string(raw)), nil
+	}
+	return "`" + string(raw) + "`", nil
+}
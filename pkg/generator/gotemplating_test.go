@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	tp "github.com/crossplane-contrib/x-generation/pkg/types"
+	sprig "github.com/go-task/slim-sprig"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// newBucketGenerator returns a generator of a bucket, whose location is
+// renamed, whose acl has an enum map and whose tags are patched from labels
+func newBucketGenerator(backend tp.CompositionBackend) *XGenerator {
+	str := v1.JSONSchemaProps{Type: "string"}
+	acl := v1.JSONSchemaProps{Type: "string", Enum: []v1.JSON{{Raw: []byte(`"private"`)}, {Raw: []byte(`"public-read"`)}}}
+	return &XGenerator{
+		Group:        "example.cloud",
+		Name:         "Bucket",
+		Version:      "v1alpha1",
+		Compositions: []tp.Composition{{Name: "bucket.aws.example.cloud", Provider: "aws", Default: true}},
+		Provider:     tp.ProviderConfig{CRD: tp.CrdConfig{Version: "v1beta1"}},
+		Crd: v1.CustomResourceDefinition{
+			Spec: v1.CustomResourceDefinitionSpec{
+				Group: "s3.aws.crossplane.io",
+				Names: v1.CustomResourceDefinitionNames{Kind: "Bucket"},
+				Versions: []v1.CustomResourceDefinitionVersion{{
+					Name: "v1beta1",
+					Schema: &v1.CustomResourceValidation{
+						OpenAPIV3Schema: &v1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]v1.JSONSchemaProps{
+								"spec": {Type: "object", Properties: map[string]v1.JSONSchemaProps{
+									"forProvider": {Type: "object", Properties: map[string]v1.JSONSchemaProps{
+										"region":     str,
+										"acl":        acl,
+										"versioning": {Type: "boolean"},
+										"tags": {Type: "array", Items: &v1.JSONSchemaPropsOrArray{Schema: &v1.JSONSchemaProps{
+											Type:       "object",
+											Properties: map[string]v1.JSONSchemaProps{"key": str, "value": str},
+										}}},
+									}},
+									"providerConfigRef": {Type: "object", Properties: map[string]v1.JSONSchemaProps{"name": str}},
+								}},
+								"status": {Type: "object", Properties: map[string]v1.JSONSchemaProps{
+									"atProvider": {Type: "object", Properties: map[string]v1.JSONSchemaProps{"arn": str}},
+								}},
+							},
+						},
+					},
+				}},
+			},
+		},
+		OverrideFields: []tp.OverrideField{{Path: "spec.forProvider.versioning", Ignore: true}},
+		OverrideFieldsInClaim: []tp.OverrideFieldInClaim{
+			{ClaimPath: "spec.location", ManagedPath: pointer("spec.forProvider.region")},
+			{ClaimPath: "spec.forProvider.acl", OverrideSettings: &tp.OverrideSettings{Enum: []*tp.EnumValue{
+				{Value: v1.JSON{Raw: []byte(`"public"`)}, Type: tp.EnumValueTypeAdd, MapTo: &v1.JSON{Raw: []byte(`"public-read"`)}},
+				{Value: v1.JSON{Raw: []byte(`"public-read"`)}, Type: tp.EnumValueTypeRemove},
+			}}},
+		},
+		Labels:             tp.LocalLabelConfig{LabelConfig: tp.LabelConfig{FromCRD: []string{"example.cloud/team"}}},
+		Tags:               tp.LocalTagConfig{TagConfig: tp.TagConfig{FromLabels: []string{"example.cloud/team"}}},
+		TagType:            pointer("keyValueArray"),
+		TagProperty:        pointer("tags"),
+		GlobalLabels:       []string{"example.cloud/cost-center"},
+		GeneratorConfig:    tp.GeneratorConfig{CompositionIdentifier: "example.cloud"},
+		CompositionBackend: backend,
+	}
+}
+
+// renderGoTemplate executes the template of the go-templating step of the
+// composition like function-go-templating and returns the rendered documents
+func renderGoTemplate(t *testing.T, g *XGenerator, input map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	if _, err := g.GenerateXRD(); err != nil {
+		t.Fatalf("GenerateXRD() error = %v", err)
+	}
+	compositions, err := g.GenerateComposition()
+	if err != nil {
+		t.Fatalf("GenerateComposition() error = %v", err)
+	}
+	step := compositions[0].Composition.Spec.Pipeline[0]
+	if step.Step != "go-templating" || step.FunctionRef.Name != "function-go-templating" {
+		t.Fatalf("GenerateComposition() first step = %s of %s, want go-templating", step.Step, step.FunctionRef.Name)
+	}
+	var goTemplate struct {
+		Inline struct {
+			Template string `json:"template"`
+		} `json:"inline"`
+	}
+	if err := json.Unmarshal(step.Input.Raw, &goTemplate); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := template.New("inline").Funcs(sprig.TxtFuncMap()).Parse(goTemplate.Inline.Template)
+	if err != nil {
+		t.Fatalf("cannot parse template %s: %v", goTemplate.Inline.Template, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, input); err != nil {
+		t.Fatalf("cannot execute template %s: %v", goTemplate.Inline.Template, err)
+	}
+	documents := []map[string]interface{}{}
+	for _, document := range strings.Split(out.String(), "\n---\n") {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+		d := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(document), &d); err != nil {
+			t.Fatalf("cannot unmarshal document %s: %v", document, err)
+		}
+		documents = append(documents, d)
+	}
+	return documents
+}
+
+func jsonObject(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	o := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(s), &o); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestGoTemplatingComposition(t *testing.T) {
+	xr := jsonObject(t, `{
+		"apiVersion": "example.cloud/v1alpha1",
+		"kind": "CompositeBucket",
+		"metadata": {
+			"name": "my-bucket-x7k2p",
+			"uid": "2f7c5e0e",
+			"labels": {"crossplane.io/claim-name": "my-bucket", "example.cloud/team": "platform", "example.cloud/cost-center": "1234"}
+		},
+		"spec": {"location": "eu-central-1", "forProvider": {"acl": "public", "versioning": true}}
+	}`)
+	observed := jsonObject(t, `{"status": {"atProvider": {"arn": "arn:aws:s3:::my-bucket"}}}`)
+	input := map[string]interface{}{
+		"observed": map[string]interface{}{
+			"composite": map[string]interface{}{"resource": xr},
+			"resources": map[string]interface{}{"Bucket": map[string]interface{}{"resource": observed}},
+		},
+	}
+
+	documents := renderGoTemplate(t, newBucketGenerator(tp.CompositionBackendGoTemplating), input)
+	if len(documents) != 2 {
+		t.Fatalf("template rendered %d documents, want the bucket and the composite: %v", len(documents), documents)
+	}
+	want := jsonObject(t, `{
+		"apiVersion": "s3.aws.crossplane.io/v1beta1",
+		"kind": "Bucket",
+		"metadata": {
+			"annotations": {"crossplane.io/external-name": "my-bucket", "gotemplating.fn.crossplane.io/composition-resource-name": "Bucket"},
+			"labels": {"example.cloud/team": "platform", "example.cloud/cost-center": "1234"}
+		},
+		"spec": {
+			"forProvider": {"region": "eu-central-1", "acl": "public-read", "tags": [{"key": "example.cloud/team", "value": "platform"}]},
+			"providerConfigRef": {"name": "default"}
+		}
+	}`)
+	if !reflect.DeepEqual(documents[0], want) {
+		got, _ := json.Marshal(documents[0])
+		t.Errorf("template rendered bucket %s", got)
+	}
+	status := documents[1]["status"].(map[string]interface{})
+	if documents[1]["kind"] != "CompositeBucket" || !reflect.DeepEqual(status["atProvider"], map[string]interface{}{"arn": "arn:aws:s3:::my-bucket"}) {
+		t.Errorf("template rendered composite %v, want the arn in its status", documents[1])
+	}
+
+	// the tag patched from the label is required
+	delete(xr["metadata"].(map[string]interface{})["labels"].(map[string]interface{}), "example.cloud/team")
+	documents = renderGoTemplate(t, newBucketGenerator(tp.CompositionBackendGoTemplating), input)
+	if len(documents) != 1 || documents[0]["kind"] != "CompositeBucket" {
+		t.Errorf("template without required label rendered %v, want only the composite", documents)
+	}
+}
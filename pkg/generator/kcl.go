@@ -0,0 +1,485 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	c "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	kclAPIVersion                  = "krm.kcl.dev/v1alpha1"
+	kclConnectionDetailsAPIVersion = "meta.krm.kcl.dev/v1alpha1"
+	kclResourceNameAnnotation      = "krm.kcl.dev/composition-resource-name"
+	kclReadyAnnotation             = "krm.kcl.dev/ready"
+)
+
+var kclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// kclKeywords can't be used as identifiers, keys with these names are quoted
+var kclKeywords = map[string]bool{
+	"all": true, "and": true, "any": true, "as": true, "assert": true, "check": true,
+	"elif": true, "else": true, "False": true, "filter": true, "final": true, "for": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true, "map": true,
+	"mixin": true, "None": true, "not": true, "or": true, "protocol": true, "relaxed": true,
+	"rule": true, "schema": true, "True": true, "type": true, "Undefined": true,
+}
+
+// kclStep returns the pipeline step rendering the resources with a program of
+// function-kcl. Like the template of the go-templating backend, the program is
+// translated from the patch-and-transform input.
+func (g *XGenerator) kclStep(input p.Resources) (*c.PipelineStep, error) {
+	program, err := g.kclProgram(input)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(map[string]interface{}{
+		"apiVersion": kclAPIVersion,
+		"kind":       "KCLInput",
+		"spec": map[string]string{
+			"source": program,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	name := "function-kcl"
+	if g.KCLFunction != nil {
+		name = *g.KCLFunction
+	}
+	return &c.PipelineStep{
+		Step: "kcl",
+		FunctionRef: c.FunctionReference{
+			Name: name,
+		},
+		Input: &runtime.RawExtension{
+			Raw: raw,
+		},
+	}, nil
+}
+
+// kclNode is a field of an object rendered by the program. The fields of the
+// base are static, fields only added by patches are rendered if one of the
+// values patched to them or to their fields is set.
+type kclNode struct {
+	static bool
+	// value of static fields that are neither objects nor arrays
+	value  interface{}
+	fields map[string]*kclNode
+	items  []*kclNode
+	// the variables patched to the field, the last one set wins
+	values []string
+}
+
+func newKCLNode(value interface{}) *kclNode {
+	n := &kclNode{static: true}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		n.fields = map[string]*kclNode{}
+		for k, f := range v {
+			n.fields[k] = newKCLNode(f)
+		}
+	case []interface{}:
+		n.items = []*kclNode{}
+		for _, item := range v {
+			n.items = append(n.items, newKCLNode(item))
+		}
+	default:
+		n.value = v
+	}
+	return n
+}
+
+// patch adds the variable to the values of the field at the path, the missing
+// objects containing the field are added. Arrays must exist in the base.
+func (n *kclNode) patch(path fieldpath.Segments, variable string) error {
+	for _, s := range path {
+		switch {
+		case s.Type == fieldpath.SegmentIndex:
+			if n.items == nil || int(s.Index) >= len(n.items) {
+				return errors.New("arrays must exist in the base with the kcl backend")
+			}
+			n = n.items[s.Index]
+		case s.Field == "*":
+			return errors.New("wildcards are not supported by the kcl backend")
+		default:
+			if n.fields == nil {
+				return fmt.Errorf("cannot patch %s of a field that is not an object", s.Field)
+			}
+			child, ok := n.fields[s.Field]
+			if !ok {
+				child = &kclNode{fields: map[string]*kclNode{}}
+				n.fields[s.Field] = child
+			}
+			n = child
+		}
+	}
+	n.values = append(n.values, variable)
+	return nil
+}
+
+// variables returns the variables patched to the field and its fields
+func (n *kclNode) variables() []string {
+	variables := append([]string{}, n.values...)
+	for _, k := range sortedFields(n.fields) {
+		variables = append(variables, n.fields[k].variables()...)
+	}
+	for _, item := range n.items {
+		variables = append(variables, item.variables()...)
+	}
+	return variables
+}
+
+// writeEntry writes the field as entry of a config, fields added by patches
+// are only written if one of their variables is set
+func (n *kclNode) writeEntry(w *strings.Builder, key string, indent int) {
+	if !n.static {
+		fmt.Fprintf(w, "%sif %s:\n", kclIndent(indent), kclAnySet(n.variables()))
+		indent++
+	}
+	fmt.Fprintf(w, "%s%s = ", kclIndent(indent), kclKey(key))
+	n.writeExpression(w, indent)
+	w.WriteString("\n")
+}
+
+// writeExpression writes the last set variable patched to the field, falling
+// back to the value of the base
+func (n *kclNode) writeExpression(w *strings.Builder, indent int) {
+	for i := len(n.values) - 1; i >= 0; i-- {
+		if i == 0 && !n.static {
+			w.WriteString(n.values[0])
+			return
+		}
+		fmt.Fprintf(w, "%s if %s != None else ", n.values[i], n.values[i])
+	}
+	switch {
+	case n.fields != nil && len(n.fields) > 0:
+		w.WriteString("{\n")
+		for _, k := range sortedFields(n.fields) {
+			n.fields[k].writeEntry(w, k, indent+1)
+		}
+		fmt.Fprintf(w, "%s}", kclIndent(indent))
+	case n.fields != nil:
+		w.WriteString("{}")
+	case n.items != nil && len(n.items) > 0:
+		w.WriteString("[\n")
+		for _, item := range n.items {
+			w.WriteString(kclIndent(indent + 1))
+			item.writeExpression(w, indent+1)
+			w.WriteString("\n")
+		}
+		fmt.Fprintf(w, "%s]", kclIndent(indent))
+	case n.items != nil:
+		w.WriteString("[]")
+	default:
+		w.WriteString(kclLiteral(n.value))
+	}
+}
+
+// kclProgram translates the resources and their patches into a program. The
+// variables of the program hold the values read from the composite and the
+// observed resources, the items are the resources, the composite with its
+// status and, if there are any, the connection details of the composite.
+func (g *XGenerator) kclProgram(input p.Resources) (string, error) {
+	patchSets := map[string][]p.PatchSetPatch{}
+	for _, ps := range input.PatchSets {
+		patchSets[ps.Name] = ps.Patches
+	}
+	kp := &kclProgram{
+		composite: newKCLNode(map[string]interface{}{
+			"apiVersion": g.Group + "/" + g.Version,
+			"kind":       g.compositeKind(),
+		}),
+		details: newKCLNode(map[string]interface{}{
+			"apiVersion": kclConnectionDetailsAPIVersion,
+			"kind":       "CompositeConnectionDetails",
+			"data":       map[string]interface{}{},
+		}),
+	}
+	for i, r := range input.Resources {
+		if err := kp.resource(i, r, patchSets); err != nil {
+			return "", fmt.Errorf("resource %s: %w", r.Name, err)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("oxr = option(\"params\").oxr\n")
+	b.WriteString("ocds = option(\"params\").ocds\n")
+	b.WriteString(kp.sections.String())
+	b.WriteString("\n_composite = ")
+	kp.composite.writeExpression(&b, 0)
+	b.WriteString("\n")
+	items := append(kp.items, "[_composite]")
+	if len(kp.details.fields["data"].fields) > 0 {
+		b.WriteString("_details = ")
+		kp.details.writeExpression(&b, 0)
+		b.WriteString("\n")
+		items = append(items, "[_details]")
+	}
+	fmt.Fprintf(&b, "\nitems = %s\n", strings.Join(items, " + "))
+	return b.String(), nil
+}
+
+// kclProgram collects the sections of the program, the composite and its
+// connection details are shared by all resources
+type kclProgram struct {
+	sections  strings.Builder
+	variables int
+	composite *kclNode
+	details   *kclNode
+	items     []string
+}
+
+// resource adds the section of the resource: the variables of its patches and
+// the resource itself. The resource is not rendered if a required field of
+// the composite is missing.
+func (kp *kclProgram) resource(i int, r p.ComposedTemplate, patchSets map[string][]p.PatchSetPatch) error {
+	base := map[string]interface{}{}
+	if r.Base != nil {
+		if err := json.Unmarshal(r.Base.Raw, &base); err != nil {
+			return fmt.Errorf("cannot unmarshal base: %w", err)
+		}
+	}
+	annotations := map[string]interface{}{kclResourceNameAnnotation: r.Name}
+	for _, check := range r.ReadinessChecks {
+		if check.Type == p.ReadinessCheckTypeNone {
+			annotations[kclReadyAnnotation] = "True"
+		}
+	}
+	mergeAnnotations(base, annotations)
+	resource := newKCLNode(base)
+
+	patches, err := resourcePatches(r, patchSets)
+	if err != nil {
+		return err
+	}
+	observed := fmt.Sprintf("ocds?[%s]?.Resource", kclString(r.Name))
+	fmt.Fprintf(&kp.sections, "\n# %s\n", r.Name)
+	required := []string{}
+	for _, patch := range patches {
+		switch patch.Type {
+		case p.PatchTypeFromCompositeFieldPath, "":
+			variable, err := kp.patch(patch.Patch, "oxr", resource)
+			if err != nil {
+				return err
+			}
+			if requiredPatch(patch.Patch) {
+				required = append(required, variable)
+			}
+		case p.PatchTypeToCompositeFieldPath:
+			if _, err := kp.patch(patch.Patch, observed, kp.composite); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("patches of type %s are not supported by the kcl backend", patch.Type)
+		}
+	}
+	for _, detail := range r.ConnectionDetails {
+		if detail.Type != p.ConnectionDetailTypeFromConnectionSecretKey || detail.FromConnectionSecretKey == nil {
+			return fmt.Errorf("connection details of type %s are not supported by the kcl backend", detail.Type)
+		}
+		variable := kp.variable(fmt.Sprintf("ocds?[%s]?.ConnectionDetails?[%s]", kclString(r.Name), kclString(*detail.FromConnectionSecretKey)))
+		if err := kp.details.patch(fieldpath.Segments{fieldpath.Field("data"), fieldpath.Field(detail.Name)}, variable); err != nil {
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("_r%d", i)
+	fmt.Fprintf(&kp.sections, "%s = ", name)
+	resource.writeExpression(&kp.sections, 0)
+	kp.sections.WriteString("\n")
+	if len(required) > 0 {
+		kp.items = append(kp.items, fmt.Sprintf("([%s] if %s else [])", name, kclAllSet(required)))
+	} else {
+		kp.items = append(kp.items, "["+name+"]")
+	}
+	return nil
+}
+
+// variable writes a new variable with the value of the expression
+func (kp *kclProgram) variable(expression string) string {
+	variable := fmt.Sprintf("_v%d", kp.variables)
+	kp.variables++
+	fmt.Fprintf(&kp.sections, "%s = %s\n", variable, expression)
+	return variable
+}
+
+// patch writes the variable with the transformed value at the from field path
+// of source and adds it to the to field path of target
+func (kp *kclProgram) patch(patch p.Patch, source string, target *kclNode) (string, error) {
+	if patch.FromFieldPath == nil || patch.ToFieldPath == nil {
+		return "", errors.New("patches without fromFieldPath or toFieldPath are not supported by the kcl backend")
+	}
+	from, err := fieldpath.Parse(*patch.FromFieldPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse %s: %w", *patch.FromFieldPath, err)
+	}
+	expression := source
+	for _, s := range from {
+		if s.Type != fieldpath.SegmentField || s.Field == "*" {
+			return "", fmt.Errorf("patch from %s: arrays are not supported by the kcl backend", *patch.FromFieldPath)
+		}
+		if kclIdentifier.MatchString(s.Field) && !kclKeywords[s.Field] {
+			expression += "?." + s.Field
+		} else {
+			expression += "?[" + kclString(s.Field) + "]"
+		}
+	}
+	to, err := fieldpath.Parse(*patch.ToFieldPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse %s: %w", *patch.ToFieldPath, err)
+	}
+
+	variable := kp.variable(expression)
+	for _, transform := range patch.Transforms {
+		if err := kp.transform(variable, transform); err != nil {
+			return "", fmt.Errorf("patch to %s: %w", *patch.ToFieldPath, err)
+		}
+	}
+	if err := target.patch(to, variable); err != nil {
+		return "", fmt.Errorf("patch to %s: %w", *patch.ToFieldPath, err)
+	}
+	return variable, nil
+}
+
+// transform writes the statements transforming the variable, map transforms
+// and string formats with one verb are supported
+func (kp *kclProgram) transform(variable string, transform p.Transform) error {
+	switch {
+	case transform.Type == p.TransformTypeMap && transform.Map != nil:
+		pairs := map[string]interface{}{}
+		for k, v := range transform.Map.Pairs {
+			var value interface{}
+			if err := json.Unmarshal(v.Raw, &value); err != nil {
+				return err
+			}
+			pairs[k] = value
+		}
+		pairsVariable := strings.Replace(variable, "_v", "_pairs", 1)
+		fmt.Fprintf(&kp.sections, "%s = %s\n", pairsVariable, kclLiteral(pairs))
+		fmt.Fprintf(&kp.sections, "%s = %s[str(%s)] if %s != None and str(%s) in %s else None\n", variable, pairsVariable, variable, variable, variable, pairsVariable)
+	case transform.Type == p.TransformTypeString && transform.String != nil && transform.String.Type == p.StringTransformTypeFormat && transform.String.Format != nil:
+		format, err := kclFormat(*transform.String.Format)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&kp.sections, "%s = %s.format(%s) if %s != None else None\n", variable, kclString(format), variable, variable)
+	default:
+		return fmt.Errorf("transforms of type %s are not supported by the kcl backend", transform.Type)
+	}
+	return nil
+}
+
+// kclFormat converts a format of fmt with one verb into a format of the
+// format method of KCL strings
+func kclFormat(format string) (string, error) {
+	var b strings.Builder
+	verbs := 0
+	for i := 0; i < len(format); i++ {
+		switch ch := format[i]; {
+		case ch == '%' && i+1 < len(format) && format[i+1] == '%':
+			b.WriteByte('%')
+			i++
+		case ch == '%' && i+1 < len(format) && strings.IndexByte("svd", format[i+1]) >= 0:
+			b.WriteString("{}")
+			verbs++
+			i++
+		case ch == '%':
+			return "", fmt.Errorf("format %s: only the verbs %%s, %%v and %%d are supported by the kcl backend", format)
+		case ch == '{' || ch == '}':
+			b.WriteByte(ch)
+			b.WriteByte(ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if verbs != 1 {
+		return "", fmt.Errorf("format %s: exactly one verb is supported by the kcl backend", format)
+	}
+	return b.String(), nil
+}
+
+// kclKey returns the key of a config entry, keys that aren't identifiers are
+// quoted
+func kclKey(key string) string {
+	if kclIdentifier.MatchString(key) && !kclKeywords[key] {
+		return key
+	}
+	return kclString(key)
+}
+
+// kclString returns a string literal, $ is escaped to prevent interpolation
+func kclString(s string) string {
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(s)
+	return strings.ReplaceAll(strings.TrimSuffix(b.String(), "\n"), "$", "\\$")
+}
+
+// kclLiteral returns the literal of a value unmarshalled from JSON
+func kclLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case string:
+		return kclString(v)
+	case map[string]interface{}:
+		entries := []string{}
+		for _, k := range sortedFields(v) {
+			entries = append(entries, kclString(k)+": "+kclLiteral(v[k]))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			items = append(items, kclLiteral(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
+
+func kclAnySet(variables []string) string {
+	conditions := []string{}
+	for _, v := range variables {
+		conditions = append(conditions, v+" != None")
+	}
+	return strings.Join(conditions, " or ")
+}
+
+func kclAllSet(variables []string) string {
+	conditions := []string{}
+	for _, v := range variables {
+		conditions = append(conditions, v+" != None")
+	}
+	return strings.Join(conditions, " and ")
+}
+
+func kclIndent(indent int) string {
+	return strings.Repeat("    ", indent)
+}
+
+func sortedFields[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
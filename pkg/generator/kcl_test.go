@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	tp "github.com/crossplane-contrib/x-generation/pkg/types"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+func TestKCLComposition(t *testing.T) {
+	g := newBucketGenerator(tp.CompositionBackendKCL)
+	g.KCLFunction = pointer("crossplane-contrib-function-kcl")
+	if _, err := g.GenerateXRD(); err != nil {
+		t.Fatalf("GenerateXRD() error = %v", err)
+	}
+	compositions, err := g.GenerateComposition()
+	if err != nil {
+		t.Fatalf("GenerateComposition() error = %v", err)
+	}
+	step := compositions[0].Composition.Spec.Pipeline[0]
+	if step.Step != "kcl" || step.FunctionRef.Name != "crossplane-contrib-function-kcl" {
+		t.Fatalf("GenerateComposition() first step = %s of %s, want kcl", step.Step, step.FunctionRef.Name)
+	}
+	var input struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			Source string `json:"source"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(step.Input.Raw, &input); err != nil {
+		t.Fatal(err)
+	}
+	if input.APIVersion != kclAPIVersion || input.Kind != "KCLInput" {
+		t.Errorf("GenerateComposition() input %s %s, want KCLInput", input.APIVersion, input.Kind)
+	}
+	for _, want := range []string{
+		"_v4 = oxr?.spec?.location\n",
+		"_v3 = _pairs3[str(_v3)] if _v3 != None and str(_v3) in _pairs3 else None\n",
+		`_v6 = ocds?["Bucket"]?.Resource?.status?.atProvider?.arn` + "\n",
+		"            if _v4 != None:\n                region = _v4\n",
+		"    \"example.cloud/team\" = _v7\n",
+		"items = ([_r0] if _v8 != None else []) + [_composite]\n",
+	} {
+		if !strings.Contains(input.Spec.Source, want) {
+			t.Errorf("GenerateComposition() program should contain %q, got %s", want, input.Spec.Source)
+		}
+	}
+}
+
+func TestKCLProgram_Golden(t *testing.T) {
+	g := newBucketGenerator(tp.CompositionBackendKCL)
+	patch := func(from, to string, transforms ...p.Transform) p.Patch {
+		return p.Patch{FromFieldPath: pointer(from), ToFieldPath: pointer(to), Transforms: transforms}
+	}
+	aclMap := p.Transform{Type: p.TransformTypeMap, Map: &p.MapTransform{Pairs: map[string]v1.JSON{
+		"private": {Raw: []byte(`"private"`)},
+		"public":  {Raw: []byte(`"public-read"`)},
+	}}}
+	nameFormat := p.Transform{Type: p.TransformTypeString, String: &p.StringTransform{
+		Type:   p.StringTransformTypeFormat,
+		Format: pointer("%s-{bucket}"),
+	}}
+	required := p.FromFieldPathPolicyRequired
+	externalName := patch("metadata.labels[crossplane.io/claim-name]", "metadata.annotations[crossplane.io/external-name]", nameFormat)
+	externalName.Policy = &p.PatchPolicy{FromFieldPath: &required}
+	input := p.Resources{
+		PatchSets: []p.PatchSet{{
+			Name: "Parameters",
+			Patches: []p.PatchSetPatch{
+				{Type: p.PatchTypeFromCompositeFieldPath, Patch: patch("spec.parameters.acl", "spec.forProvider.acl", aclMap)},
+				{Type: p.PatchTypeFromCompositeFieldPath, Patch: patch("spec.parameters.region", "spec.forProvider.region")},
+			},
+		}},
+		Resources: []p.ComposedTemplate{{
+			Name: "Bucket",
+			Base: &runtime.RawExtension{Raw: []byte(`{"apiVersion":"s3.aws.crossplane.io/v1beta1","kind":"Bucket","spec":{"forProvider":{"region":"eu-central-1"}}}`)},
+			Patches: []p.ComposedPatch{
+				{Type: p.PatchTypePatchSet, PatchSetName: pointer("Parameters")},
+				{Type: p.PatchTypeFromCompositeFieldPath, Patch: externalName},
+				{Type: p.PatchTypeToCompositeFieldPath, Patch: patch("status.atProvider.arn", "status.arn")},
+			},
+			ConnectionDetails: []p.ConnectionDetail{{
+				Name:                    "arn",
+				Type:                    p.ConnectionDetailTypeFromConnectionSecretKey,
+				FromConnectionSecretKey: pointer("attribute.arn"),
+			}},
+		}},
+	}
+	got, err := g.kclProgram(input)
+	if err != nil {
+		t.Fatalf("kclProgram() error = %v", err)
+	}
+	golden := filepath.Join("testdata", "kcl-program.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("cannot read %s, run the tests with -update to create it: %v", golden, err)
+	}
+	if got != string(want) {
+		t.Errorf("kclProgram() = %s, want %s", got, want)
+	}
+}
+
+func TestKCLProgram_Unsupported(t *testing.T) {
+	g := newBucketGenerator(tp.CompositionBackendKCL)
+	from, to := "spec.parameters.size", "spec.forProvider.size"
+	input := p.Resources{Resources: []p.ComposedTemplate{{
+		Name: "Bucket",
+		Patches: []p.ComposedPatch{{
+			Type: p.PatchTypeFromCompositeFieldPath,
+			Patch: p.Patch{
+				FromFieldPath: &from,
+				ToFieldPath:   &to,
+				Transforms:    []p.Transform{{Type: p.TransformTypeMath}},
+			},
+		}},
+	}}}
+	if _, err := g.kclProgram(input); err == nil || err.Error() != "resource Bucket: patch to spec.forProvider.size: transforms of type math are not supported by the kcl backend" {
+		t.Errorf("kclProgram() error = %v, want unsupported transform", err)
+	}
+}
+
+func Test_kclFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "%s-secret", want: "{}-secret"},
+		{format: "{%d}%%", want: "{{{}}}%"},
+		{format: "%s-%s", wantErr: true},
+		{format: "%.2f", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := kclFormat(tt.format)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("kclFormat(%q) = %q, %v, want %q", tt.format, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Resource is an additional managed resource of the composition, its spec and
+// status are below spec.<Name> and status.<Name> of the composite
+type Resource struct {
+	Name               string
+	Crd                v1.CustomResourceDefinition
+	Provider           t.ProviderConfig
+	OverrideFields     []t.OverrideField
+	References         []t.ResourceReference
+	TagType            *string
+	TagProperty        *string
+	TagKeyField        *string
+	TagValueField      *string
+	InitProviderFields []string
+}
+
+// resourceGenerator returns the generator of the schema, base and patches of
+// the resource, labels and tags are shared with the main resource
+func (g *XGenerator) resourceGenerator(r Resource) *XGenerator {
+	return &XGenerator{
+		Group:              g.Group,
+		Name:               g.Name,
+		Version:            g.Version,
+		Compositions:       g.Compositions,
+		Crd:                r.Crd,
+		Provider:           r.Provider,
+		OverrideFields:     r.OverrideFields,
+		Labels:             g.Labels,
+		Tags:               g.Tags,
+		TagType:            r.TagType,
+		TagProperty:        r.TagProperty,
+		TagKeyField:        r.TagKeyField,
+		TagValueField:      r.TagValueField,
+		GlobalLabels:       g.GlobalLabels,
+		GeneratorConfig:    g.GeneratorConfig,
+		InitProviderFields: r.InitProviderFields,
+	}
+}
+
+// resourceName returns the name of the main resource in the composition
+func (g *XGenerator) resourceName() string {
+	if g.ResourceName != nil {
+		return *g.ResourceName
+	}
+	return g.Crd.Spec.Names.Kind
+}
+
+// resourceSchemas returns the spec and status of the resource in the composite
+func (g *XGenerator) resourceSchemas(r Resource) (*v1.JSONSchemaProps, *v1.JSONSchemaProps, error) {
+	rg := g.resourceGenerator(r)
+	if _, err := rg.getVersion(); err != nil {
+		return nil, nil, fmt.Errorf("resource %s: %w", r.Name, err)
+	}
+	spec, err := rg.generateSchema("spec")
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := rg.generateSchema("status")
+	if err != nil {
+		return nil, nil, err
+	}
+	if spec == nil {
+		spec = &v1.JSONSchemaProps{Type: "object", Properties: map[string]v1.JSONSchemaProps{}}
+	}
+	if status == nil {
+		status = &v1.JSONSchemaProps{Type: "object", Properties: map[string]v1.JSONSchemaProps{}}
+	}
+	return spec, status, nil
+}
+
+// addResourceSchemas adds the spec and status of the resources to the spec and
+// status of the composite
+func (g *XGenerator) addResourceSchemas(spec, status *v1.JSONSchemaProps) error {
+	for _, r := range g.Resources {
+		if _, ok := spec.Properties[r.Name]; ok {
+			return fmt.Errorf("resource %s conflicts with the property spec.%s", r.Name, r.Name)
+		}
+		if _, ok := status.Properties[r.Name]; ok {
+			return fmt.Errorf("resource %s conflicts with the property status.%s", r.Name, r.Name)
+		}
+		rSpec, rStatus, err := g.resourceSchemas(r)
+		if err != nil {
+			return err
+		}
+		spec.Properties[r.Name] = *rSpec
+		status.Properties[r.Name] = *rStatus
+	}
+	return nil
+}
+
+// generateResources returns the patchsets and the templates of the resources
+func (g *XGenerator) generateResources(comp t.Composition, labelPatchset p.PatchSet) ([]p.PatchSet, []p.ComposedTemplate, error) {
+	patchSets := []p.PatchSet{}
+	templates := []p.ComposedTemplate{}
+	for _, r := range g.Resources {
+		rg := g.resourceGenerator(r)
+		spec, status, err := g.resourceSchemas(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		parameters := rg.generateSortedPropertyPatchesFor(*spec, "spec", p.PatchTypeFromCompositeFieldPath)
+		for i := range parameters {
+			parameters[i].FromFieldPath = pointer(resourcePath("spec", r.Name, *parameters[i].FromFieldPath))
+		}
+		statusPatches := rg.generateSortedPropertyPatchesFor(*status, "status", p.PatchTypeToCompositeFieldPath)
+		for i := range statusPatches {
+			statusPatches[i].ToFieldPath = pointer(resourcePath("status", r.Name, *statusPatches[i].ToFieldPath))
+		}
+		resourcePatchSets := []p.PatchSet{
+			{Name: "Parameters-" + r.Name, Patches: parameters},
+			{Name: "Status-" + r.Name, Patches: statusPatches},
+		}
+		if tagPatchset := rg.generateTagPatchset(); len(tagPatchset.Patches) > 0 {
+			tagPatchset.Name = "Tags-" + r.Name
+			resourcePatchSets = append(resourcePatchSets, tagPatchset)
+		}
+
+		template := p.ComposedTemplate{
+			Name: r.Name,
+			Base: &runtime.RawExtension{
+				Raw: rg.generateBase(comp),
+			},
+		}
+		if g.ReadinessChecks != nil && !*g.ReadinessChecks {
+			template.ReadinessChecks = []p.ReadinessCheck{{
+				Type: p.ReadinessCheckTypeNone,
+			}}
+		}
+		shared := []string{"Common"}
+		if len(labelPatchset.Patches) > 0 {
+			shared = append(shared, labelPatchset.Name)
+		}
+		for _, name := range shared {
+			template.Patches = append(template.Patches, p.ComposedPatch{
+				Type:         p.PatchTypePatchSet,
+				PatchSetName: pointer(name),
+			})
+		}
+		for _, ps := range resourcePatchSets {
+			template.Patches = append(template.Patches, p.ComposedPatch{
+				Type:         p.PatchTypePatchSet,
+				PatchSetName: pointer(ps.Name),
+			})
+		}
+		template.Patches = append(template.Patches, g.referencePatches(r.References)...)
+
+		patchSets = append(patchSets, resourcePatchSets...)
+		templates = append(templates, template)
+	}
+	return patchSets, templates, nil
+}
+
+// referencePatches patches the fields of the referenced resources, which are
+// copied to the status of the composite, to the resource. The patches are
+// required, the resource is not created before the referenced fields are known.
+func (g *XGenerator) referencePatches(references []t.ResourceReference) []p.ComposedPatch {
+	patches := []p.ComposedPatch{}
+	for _, ref := range references {
+		from := ref.FromFieldPath
+		if ref.Resource != g.resourceName() {
+			from = resourcePath("status", ref.Resource, from)
+		}
+		patches = append(patches, p.ComposedPatch{
+			Type: p.PatchTypeFromCompositeFieldPath,
+			Patch: p.Patch{
+				FromFieldPath: pointer(from),
+				ToFieldPath:   pointer(ref.ToFieldPath),
+				Policy: &p.PatchPolicy{
+					FromFieldPath: pointer(p.FromFieldPathPolicyRequired),
+				},
+			},
+		})
+	}
+	return patches
+}
+
+// resourcePath moves the path below root to root.<name>, e.g. spec.forProvider
+// of the resource bucket is spec.bucket.forProvider in the composite
+func resourcePath(root, name, path string) string {
+	return root + "." + name + strings.TrimPrefix(path, root)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// Return how spec.initProvider of upjet based providers is handled, the
+// generate.yaml overrides the global configuration
+func (g *Generator) initProviderHandling(generatorConfig *t.GeneratorConfig) t.InitProviderHandling {
+	if g.InitProvider != nil {
+		return *g.InitProvider
+	}
+	if generatorConfig.InitProvider != nil {
+		return *generatorConfig.InitProvider
+	}
+	return t.InitProviderExpose
+}
+
+// Return the crd and its json source the definition and composition are
+// generated from, see applyInitProvider. The merged fields of the crd version
+// of the referenceable version are returned.
+func (g *Generator) renderedCRD(generatorConfig *t.GeneratorConfig) (extv1.CustomResourceDefinition, string, []string, error) {
+	crd, crdSource := g.crd, g.crdSource
+	initProviderFields := []string{}
+	for i, v := range g.xrdVersions() {
+		var merged []string
+		var err error
+		crd, crdSource, merged, err = applyInitProvider(crd, crdSource, v.CRDVersion, g.initProviderHandling(generatorConfig))
+		if err != nil {
+			return crd, crdSource, nil, err
+		}
+		if i == 0 {
+			initProviderFields = merged
+		}
+	}
+	return crd, crdSource, initProviderFields, nil
+}
+
+// Apply the initProvider handling to the given version of the crd. Unless
+// exposed spec.initProvider is removed from the crd, when merging its
+// properties missing in spec.forProvider are added to spec.forProvider, the
+// paths of the merged properties are returned. The given crd is not modified.
+func applyInitProvider(crd extv1.CustomResourceDefinition, crdSource, version string, handling t.InitProviderHandling) (extv1.CustomResourceDefinition, string, []string, error) {
+	if handling == t.InitProviderExpose {
+		return crd, crdSource, []string{}, nil
+	}
+
+	copied := crd.DeepCopy()
+	schema, ok := crdVersionSchema(*copied, version)
+	if !ok {
+		return crd, crdSource, []string{}, nil
+	}
+	spec, ok := schema.Properties["spec"]
+	if !ok {
+		return crd, crdSource, []string{}, nil
+	}
+	initProvider, ok := spec.Properties["initProvider"]
+	if !ok {
+		return crd, crdSource, []string{}, nil
+	}
+
+	merged := []string{}
+	if handling == t.InitProviderMerge {
+		forProvider := spec.Properties["forProvider"]
+		if forProvider.Properties == nil {
+			forProvider.Type = "object"
+			forProvider.Properties = map[string]extv1.JSONSchemaProps{}
+		}
+		for name, property := range initProvider.Properties {
+			if _, ok := forProvider.Properties[name]; ok {
+				continue
+			}
+			forProvider.Properties[name] = property
+			merged = append(merged, "spec.forProvider."+name)
+		}
+		sort.Strings(merged)
+		spec.Properties["forProvider"] = forProvider
+	}
+	delete(spec.Properties, "initProvider")
+	spec.Required = filterInitProvider(spec.Required)
+	schema.Properties["spec"] = spec
+
+	source, err := json.Marshal(copied)
+	if err != nil {
+		return crd, crdSource, nil, errors.Wrap(err, "cannot marshal crd")
+	}
+	return *copied, string(source), merged, nil
+}
+
+func filterInitProvider(required []string) []string {
+	result := []string{}
+	for _, r := range required {
+		if r != "initProvider" {
+			result = append(result, r)
+		}
+	}
+	return result
+}
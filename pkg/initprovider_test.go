@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestGenerator_RenderInitProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		handling xtype.InitProviderHandling
+		// properties of spec.initProvider of the definition, nil if it has none
+		wantInitProvider []string
+		// from and to field paths of patches of the resource
+		wantPatches [][2]string
+		// field paths no patch of the resource reads or writes
+		wantNotPatched []string
+	}{
+		{
+			name:             "Should expose initProvider",
+			handling:         xtype.InitProviderExpose,
+			wantInitProvider: []string{"bucketPrefix", "region"},
+			wantPatches: [][2]string{
+				{"spec.initProvider.bucketPrefix", "spec.initProvider.bucketPrefix"},
+				{"spec.initProvider.region", "spec.initProvider.region"},
+				{"spec.forProvider.region", "spec.forProvider.region"},
+			},
+			wantNotPatched: []string{"spec.initProvider.tags"},
+		},
+		{
+			name:     "Should merge initProvider into forProvider",
+			handling: xtype.InitProviderMerge,
+			wantPatches: [][2]string{
+				{"spec.forProvider.bucketPrefix", "spec.initProvider.bucketPrefix"},
+				{"spec.forProvider.region", "spec.forProvider.region"},
+			},
+			wantNotPatched: []string{"spec.initProvider.region", "spec.initProvider.tags"},
+		},
+		{
+			name:     "Should hide initProvider",
+			handling: xtype.InitProviderHide,
+			wantPatches: [][2]string{
+				{"spec.forProvider.region", "spec.forProvider.region"},
+			},
+			wantNotPatched: []string{"spec.initProvider.bucketPrefix", "spec.initProvider.region", "spec.forProvider.bucketPrefix", "spec.initProvider.tags"},
+		},
+	}
+	for _, tt := range tests {
+		for _, usePipeline := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (usePipeline %v)", tt.name, usePipeline), func(t *testing.T) {
+				g := newTestGenerator(t, t.TempDir(), usePipeline)
+				spec := g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+				spec.Properties["forProvider"].Properties["tags"] = objectOfStrings
+				spec.Properties["initProvider"] = extv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]extv1.JSONSchemaProps{
+						"region":       {Type: "string"},
+						"bucketPrefix": {Type: "string"},
+						"tags":         objectOfStrings,
+					},
+				}
+				g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
+				crdSource, err := json.Marshal(g.crd)
+				if err != nil {
+					t.Fatal(err)
+				}
+				g.crdSource = string(crdSource)
+				tags := detectTags(g.crd, "testv1", defaultTagDetectors)
+				g.TagType, g.TagProperty = &tags.tagType, &tags.property
+
+				gConfig := xtype.GeneratorConfig{
+					CompositionIdentifier: "example.cloud",
+					InitProvider:          &tt.handling,
+				}
+				xrd, compositions := renderTestGenerator(t, &g, &gConfig)
+
+				schema, err := versionSchema(xrd.Spec.Versions[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				var gotInitProvider []string
+				if initProvider, ok := schemaAt(schema, "spec.initProvider"); ok {
+					gotInitProvider = []string{}
+					for name := range initProvider.Properties {
+						gotInitProvider = append(gotInitProvider, name)
+					}
+					sort.Strings(gotInitProvider)
+				}
+				if !reflect.DeepEqual(gotInitProvider, tt.wantInitProvider) {
+					t.Errorf("Render() usePipeline %v initProvider properties = %v, want %v", usePipeline, gotInitProvider, tt.wantInitProvider)
+				}
+
+				patches := resourcePatches(t, patchAndTransformInput(t, &compositions[0]), 0)
+				for _, want := range tt.wantPatches {
+					if findPatch(patches, want[0], want[1]) == nil {
+						t.Errorf("Render() usePipeline %v should patch %s to %s", usePipeline, want[0], want[1])
+					}
+				}
+				for _, path := range tt.wantNotPatched {
+					if findPatch(patches, path, "") != nil || findPatch(patches, "", path) != nil {
+						t.Errorf("Render() usePipeline %v should not patch %s", usePipeline, path)
+					}
+				}
+				if len(g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties["initProvider"].Properties) != 3 {
+					t.Errorf("Render() should not modify the loaded crd")
+				}
+			})
+		}
+	}
+}
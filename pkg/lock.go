@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	lockFileName   = "x-generation.lock"
+	lockFileHeader = "## This file is maintained by x-generation, do not edit it manually.\n" +
+		"## It records the url and the sha256 checksum of every downloaded crd.\n" +
+		"\n"
+)
+
+type lockEntry struct {
+	Provider string `yaml:"provider" json:"provider"`
+	Version  string `yaml:"version" json:"version"`
+	File     string `yaml:"file" json:"file"`
+	URL      string `yaml:"url" json:"url"`
+	SHA256   string `yaml:"sha256" json:"sha256"`
+}
+
+// lockFile records the resolved url and checksum of the crds used for
+// the generation, so later runs can detect changed upstream crds. It is
+// safe for concurrent use.
+type lockFile struct {
+	CRDs []lockEntry `yaml:"crds" json:"crds"`
+
+	mu      sync.Mutex
+	path    string
+	changed bool
+}
+
+// Load the lock file from path, a missing file results in an empty lock file
+func loadLockFile(path string) (*lockFile, error) {
+	l := &lockFile{path: path}
+	y, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(y, l); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse lock file %s", path)
+	}
+	return l, nil
+}
+
+// Find returns a copy of the entry of the crd file of the given provider version
+func (l *lockFile) Find(provider, version, file string) *lockEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.find(provider, version, file); i >= 0 {
+		e := l.CRDs[i]
+		return &e
+	}
+	return nil
+}
+
+func (l *lockFile) find(provider, version, file string) int {
+	for i, e := range l.CRDs {
+		if e.Provider == provider && e.Version == version && e.File == file {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record adds or updates the entry of a crd file
+func (l *lockFile) Record(entry lockEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.find(entry.Provider, entry.Version, entry.File); i >= 0 {
+		if l.CRDs[i] != entry {
+			l.CRDs[i] = entry
+			l.changed = true
+		}
+		return
+	}
+	l.CRDs = append(l.CRDs, entry)
+	l.changed = true
+}
+
+// Write the lock file if any entry was added or changed
+func (l *lockFile) Write() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.changed {
+		return nil
+	}
+	sort.Slice(l.CRDs, func(i, j int) bool {
+		a, b := l.CRDs[i], l.CRDs[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.File < b.File
+	})
+	y, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path, append([]byte(lockFileHeader), y...), 0644); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
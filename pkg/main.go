@@ -1,24 +1,27 @@
 package main
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
+	"sync/atomic"
 
 	"github.com/crossplane-contrib/x-generation/pkg/generator"
 	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/crossplane-contrib/x-generation/pkg/version"
 	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-jsonnet"
-	getter "github.com/hashicorp/go-getter"
 	"github.com/pkg/errors"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
@@ -28,7 +31,7 @@ const (
 	autogenHeader = "## WARNING: This file was autogenerated!\n" +
 		"## Manual modifications will be overwritten\n" +
 		"## unless ignore: true is set in generate.yaml!\n" +
-		"## Last Modification: %s.\n" +
+		"## Generator version: %s, input hash: %s.\n" +
 		"\n"
 	baseURL = "https://raw.githubusercontent.com/crossplane-contrib/"
 )
@@ -45,9 +48,9 @@ type Generator struct {
 	Name                         string                   `yaml:"name" json:"name"`
 	Plural                       *string                  `yaml:"plural,omitempty" json:"plural,omitempty"`
 	Version                      string                   `yaml:"version" json:"version"`
-	ScriptFileName               *string                  `yaml:"scriptFile,omitempty"`
+	ScriptFileName               *string                  `yaml:"scriptFile,omitempty" json:"scriptFile,omitempty"`
 	ConnectionSecretKeys         *[]string                `yaml:"connectionSecretKeys,omitempty" json:"connectionSecretKeys,omitempty"`
-	Ignore                       bool                     `yaml:"ignore"`
+	Ignore                       bool                     `yaml:"ignore" json:"ignore"`
 	PatchExternalName            *bool                    `yaml:"patchExternalName,omitempty" json:"patchExternalName,omitempty"`
 	PatchlName                   *bool                    `yaml:"patchName,omitempty" json:"patchName,omitempty"`
 	ResourceName                 *string                  `yaml:"resourceName,omitempty" json:"resourceName,omitempty"`
@@ -63,172 +66,105 @@ type Generator struct {
 	AdditionalPipelineSteps      []t.PipelineStep         `yaml:"additionalPipelineSteps,omitempty" json:"additionalPipelineSteps,omitempty"`
 	TagType                      *string                  `yaml:"tagType,omitempty" json:"tagType,omitempty"`
 	TagProperty                  *string                  `yaml:"tagProperty,omitempty" json:"tagProperty,omitempty"`
+	TagKeyField                  *string                  `yaml:"tagKeyField,omitempty" json:"tagKeyField,omitempty"`
+	TagValueField                *string                  `yaml:"tagValueField,omitempty" json:"tagValueField,omitempty"`
+	InitProvider                 *t.InitProviderHandling  `yaml:"initProvider,omitempty" json:"initProvider,omitempty"`
+	CrossplaneVersion            *t.CrossplaneVersion     `yaml:"crossplaneVersion,omitempty" json:"crossplaneVersion,omitempty"`
+	Versions                     []t.XRDVersion           `yaml:"versions,omitempty" json:"versions,omitempty"`
+	AcknowledgedBreakingChanges  []string                 `yaml:"acknowledgedBreakingChanges,omitempty" json:"acknowledgedBreakingChanges,omitempty"`
+	Examples                     *t.ExamplesConfig        `yaml:"examples,omitempty" json:"examples,omitempty"`
+	Resources                    []t.Resource             `yaml:"resources,omitempty" json:"resources,omitempty"`
+	References                   []t.ResourceReference    `yaml:"references,omitempty" json:"references,omitempty"`
 	UsePipeline                  *bool                    `yaml:"usePipeline,omitempty" json:"usePipeline,omitempty"`
+	CompositionBackend           *t.CompositionBackend    `yaml:"compositionBackend,omitempty" json:"compositionBackend,omitempty"`
 	DefaultCompositeDeletePolicy *string                  `yaml:"defaultCompositeDeletePolicy,omitempty" json:"defaultCompositeDeletePolicy,omitempty"`
 
-	crd        extv1.CustomResourceDefinition
-	crdSource  string
-	configPath string
+	crd         extv1.CustomResourceDefinition
+	crdSource   string
+	crdChecksum string
+	configPath  string
+	// resources contains the crds of Resources
+	resources []loadedResource
+	// content of the generate.yaml, part of the input hash
+	configContent []byte
+	// log receives the output of the generator, the standard logger is used if nil
+	log *log.Logger
 }
 
 type jsonnetOutput map[string]interface{}
 
-func (g *Generator) LoadConfig(path string) *Generator {
+// LoadConfig loads the generator from the generate.yaml at path. Unknown fields
+// are errors in strict mode, otherwise they are logged as warnings.
+func (g *Generator) LoadConfig(path string, strict bool) (*Generator, error) {
 	g.configPath = filepath.Dir(path)
 	y, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("Error loading generator: %+v\n", err)
+		return nil, errors.Wrap(err, "cannot load generator")
+	}
+	g.configContent = y
+	warnings, err := unmarshalConfig(path, y, g, strict)
+	for _, w := range warnings {
+		logf(g.log, "Warning: %s\n", w)
 	}
-	err = yaml.Unmarshal(y, g)
 	if err != nil {
-		fmt.Printf("Error unmarshaling generator config: %v\n", err)
+		return nil, err
 	}
-	return g
+	return g, nil
 }
 
-func (g *Generator) LoadCRD(generatorConfig *t.GeneratorConfig) error {
-	crdTempDir, err := os.MkdirTemp("", "gencrd")
+func (g *Generator) LoadCRD(generatorConfig *t.GeneratorConfig, loader *crdLoader) error {
+	crd, source, sum, err := g.loadProviderCRD(generatorConfig, g.Provider, loader)
 	if err != nil {
-		return errors.Errorf("Error creating CRD temp dir: %v\n", err)
-	}
-
-	defer os.RemoveAll(crdTempDir)
-
-	crdFileName := filepath.Base(g.Provider.CRD.File)
-	crdTempFile := filepath.Join(crdTempDir, crdFileName)
-
-	var crdUrl string
-	usedBaseURL := baseURL
-	if g.Provider.BaseURL != nil {
-		usedBaseURL = *g.Provider.BaseURL
-	} else if generatorConfig.Provider.BaseURL != nil {
-		usedBaseURL = *generatorConfig.Provider.BaseURL
-	}
-
-	providerName := generatorConfig.Provider.Name
-	if g.Provider.Name != "" {
-		providerName = g.Provider.Name
-	}
-	providerVersion := generatorConfig.Provider.Version
-	if g.Provider.Name != "" {
-		providerVersion = g.Provider.Version
-	}
-
-	if providerName == "" {
-		return errors.Errorf("No provider name given for crd: %v\n", g.Provider.CRD.File)
-	}
-
-	if providerVersion == "" {
-		return errors.Errorf("No provider version given for crd: %v\n", g.Provider.CRD.File)
+		return err
 	}
-
-	crdUrl = fmt.Sprintf(usedBaseURL, providerName, providerVersion, g.Provider.CRD.File)
-	client := &getter.Client{
-		Ctx: context.Background(),
-		Src: crdUrl,
-		Dst: crdTempFile,
+	version := g.xrdVersions()[0].CRDVersion
+	if g.TagType == nil || g.TagProperty == nil {
+		tags := detectTags(crd, version, newTagDetectors(generatorConfig.TagDetectors))
+		if g.TagType == nil {
+			g.TagType = &tags.tagType
+		}
+		if g.TagProperty == nil {
+			g.TagProperty = &tags.property
+		}
+		if g.TagKeyField == nil && tags.keyField != "" {
+			g.TagKeyField = &tags.keyField
+		}
+		if g.TagValueField == nil && tags.valueField != "" {
+			g.TagValueField = &tags.valueField
+		}
 	}
+	g.crdSource = source
+	g.crdChecksum = sum
+	g.crd = crd
+	return g.loadResources(generatorConfig, loader)
+}
 
-	log.Printf("Retrieving CRD file from %s\n", g.Provider.CRD.File)
-	err = client.Get()
+// Load the crd of the provider, returns the crd, its json source and the checksum of the loaded file
+func (g *Generator) loadProviderCRD(generatorConfig *t.GeneratorConfig, provider t.ProviderConfig, loader *crdLoader) (extv1.CustomResourceDefinition, string, string, error) {
+	var crd2 extv1.CustomResourceDefinition
+	source, err := g.getProviderCRDSource(generatorConfig, provider, loader)
 	if err != nil {
-		return errors.Errorf("Get CRD: %v\n", err)
+		return crd2, "", "", err
 	}
 
-	crd, err := os.ReadFile(crdTempFile)
+	crd, err := loader.fetch(source, provider.CRD)
 	if err != nil {
-		return errors.Errorf("Error reading from CRD tempfile: %v\n", err)
+		return crd2, "", "", err
 	}
 
 	if len(crd) < 1 {
-		return errors.Errorf("CRD %s appears to be empty!\n", g.Provider.CRD.File)
+		return crd2, "", "", errors.Errorf("CRD %s appears to be empty!\n", source.Location(provider.CRD))
 	}
 
 	r, err := yaml.YAMLToJSON(crd)
 	if err != nil {
-		return errors.Errorf("Convert YAML to JSON: %v\n", err)
+		return crd2, "", "", errors.Errorf("Convert YAML to JSON: %v\n", err)
 	}
-	var crd2 extv1.CustomResourceDefinition
 	err = json.Unmarshal(r, &crd2)
 	if err != nil {
-		return errors.Errorf("Unmarshal crd content: %v\n", err)
-	}
-	version := g.Provider.CRD.Version
-	if version == "" {
-		version = g.Version
-	}
-	if g.TagType == nil || g.TagProperty == nil {
-		tagType, tagProperty := checkTagType(crd2, version)
-		if g.TagType == nil {
-			g.TagType = &tagType
-		}
-		if g.TagProperty == nil {
-			g.TagProperty = &tagProperty
-		}
-	}
-	if err != nil {
-		return errors.Errorf("Convert CRD to JSON: %v\n", err)
+		return crd2, "", "", errors.Errorf("Unmarshal crd content: %v\n", err)
 	}
-	g.crdSource = string(r)
-	g.crd = crd2
-	return nil
-
-}
-
-// Check if the CRD uses a array of key-value-pairs or an object for tags
-func checkTagType(crd extv1.CustomResourceDefinition, version string) (string, string) {
-	tags, tagProperty, err := tryToGetTags(crd, version)
-	if err != nil {
-		return "noTag", ""
-	}
-	if tags.Type == "array" {
-
-		subType := tags.Items.Schema.Type
-		if subType == "object" {
-			properties := tags.Items.Schema.Properties
-
-			_, ok := properties["key"]
-			_, ok2 := properties["value"]
-			if ok && ok2 {
-				return "keyValueArray", tagProperty
-			}
-			_, ok3 := properties["tagKey"]
-			_, ok4 := properties["tagValue"]
-			if ok3 && ok4 {
-				return "tagKeyTagValueArray", tagProperty
-			}
-		}
-	}
-	if tags.Type == "object" {
-		if tags.AdditionalProperties.Schema.Type == "string" {
-			return "tagObject", tagProperty
-		}
-	}
-
-	return "noTag", ""
-}
-
-// try to load the tags property of the crd from the given object
-func tryToGetTags(crd extv1.CustomResourceDefinition, version string) (*extv1.JSONSchemaProps, string, error) {
-	if len(crd.Spec.Versions) > 0 {
-		for _, schemaVersion := range crd.Spec.Versions {
-			if schemaVersion.Name == version {
-				if specs, ok := schemaVersion.Schema.OpenAPIV3Schema.Properties["spec"]; ok {
-					if forProvider, ok := specs.Properties["forProvider"]; ok {
-						if tags, ok := forProvider.Properties["tags"]; ok {
-							return &tags, "spec.forProvider.tags", nil
-						}
-						if tagging, ok := forProvider.Properties["tagging"]; ok {
-							if tagSet, ok := tagging.Properties["tagSet"]; ok {
-								return &tagSet, "spec.forProvider.tagging.tagSet", nil
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-	return nil, "", errors.New("Could not find tags")
+	return crd2, string(r), checksum(crd), nil
 }
 
 func getTagListAsString(g *Generator) string {
@@ -266,14 +202,18 @@ func appendLists(a *[]string, b *[]string) *[]string {
 	return &list
 }
 
-// add the values of b in map a and return the new map, if a value is given in a and b,
+// add the values of a and b to a new map and return it, if a value is given in a and b,
 // the value in map b is used
 func appendStringMaps(a, b map[string]string) map[string]string {
+	result := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		result[k] = v
+	}
 	for k, v := range b {
-		a[k] = v
+		result[k] = v
 	}
 
-	return a
+	return result
 }
 
 // generate a JSON array representation of the given list, if the list is empty, returns []
@@ -293,15 +233,31 @@ func getJsonStringFromMap(list *map[string]string) string {
 	return string(marshaledMap)
 }
 
-func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptFileOverride, outputPath string) {
+// generatedFile is a file rendered by the generator, content does not
+// contain the autogenerated header
+type generatedFile struct {
+	path    string
+	content []byte
+}
+
+// Check if the pipeline mode is used for this generator
+func (g *Generator) usePipeline(generatorConfig *t.GeneratorConfig) bool {
+	return (generatorConfig.UsePipeline != nil && *generatorConfig.UsePipeline) || (g.UsePipeline != nil && *g.UsePipeline)
+}
+
+// Render the definition and compositions in memory without writing them
+func (g *Generator) Render(generatorConfig *t.GeneratorConfig, scriptPath, scriptFileOverride, outputPath string) ([]generatedFile, error) {
 	outPath := g.configPath
 	if outputPath != "" {
 		outPath = outputPath
 	}
-	header := []byte(fmt.Sprintf(autogenHeader,
-		time.Now().Format("15:04:05 on 01-02-2006"),
-	))
-	if (generatorConfig.UsePipeline == nil || !*generatorConfig.UsePipeline) && (g.UsePipeline == nil || !*g.UsePipeline) {
+	files := []generatedFile{}
+	crd, crdSource, initProviderFields, err := g.renderedCRD(generatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	versions := g.xrdVersions()
+	if !g.usePipeline(generatorConfig) {
 		var fl string
 		if scriptFileOverride != "" {
 			fl = filepath.Join(scriptPath, scriptFileOverride)
@@ -323,12 +279,12 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			}
 		}
 
-		j, err := json.Marshal(&g)
-		// a := string(j)
-		// if a != "" {
-		// }
+		// the composition is generated for the referenceable version
+		config := *g
+		config.Version, config.Provider.CRD.Version = versions[0].Name, versions[0].CRDVersion
+		j, err := json.Marshal(&config)
 		if err != nil {
-			fmt.Printf("Error creating jsonnet input: %s", err)
+			return nil, errors.Wrap(err, "cannot create jsonnet input")
 		}
 		readinessChecks := "true"
 		if g.ReadinessChecks != nil {
@@ -337,7 +293,8 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			}
 		}
 		vm.ExtVar("config", string(j))
-		vm.ExtVar("crd", g.crdSource)
+		vm.ExtVar("crd", crdSource)
+		vm.ExtVar("initProviderFields", getJsonStringFromList(&initProviderFields))
 		vm.ExtVar("globalLabels", getJsonStringFromList(&globalLabels))
 
 		vm.ExtVar("tagList", getTagListAsString(g))
@@ -352,52 +309,50 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			vm.ExtVar("tagType", "")
 		}
 		if g.TagProperty != nil {
-			tagPropertyPath := strings.Split(*g.TagProperty, ".")
-			tagProperty := tagPropertyPath[len(tagPropertyPath)-1]
-			vm.ExtVar("tagProperty", tagProperty)
+			vm.ExtVar("tagProperty", *g.TagProperty)
 		} else {
 			vm.ExtVar("tagProperty", "")
 		}
 		vm.ExtVar("compositionIdentifier", generatorConfig.CompositionIdentifier)
 		vm.ExtVar("readinessChecks", readinessChecks)
+		vm.ExtVar("crossplaneVersion", string(g.crossplaneVersion(generatorConfig)))
+		servedVersions, err := json.Marshal(versions[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create jsonnet input")
+		}
+		vm.ExtVar("servedVersions", string(servedVersions))
 
 		r, err := vm.EvaluateFile(fl)
 		if err != nil {
-			fmt.Printf("Error applying function %s: %s", fl, err)
+			return nil, errors.Wrapf(err, "cannot apply function %s", fl)
 		}
 
 		jso := make(jsonnetOutput)
 
 		err = json.Unmarshal([]byte(r), &jso)
 		if err != nil {
-			fmt.Printf("Error decoding jsonnet output: %s", err)
+			return nil, errors.Wrap(err, "cannot decode jsonnet output")
 		}
 
-		// header := []byte{}
-
 		for fn, fc := range jso {
 			yo, err := yaml.Marshal(fc)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot convert %s to YAML", fn)
+			}
 
 			// Override x-kubernetes-validations fields if OverrideFieldsInClaim is given
 			if g.OverrideFieldsInClaim != nil && fn == "definition" {
 				var xrd crossplanev1.CompositeResourceDefinition
-				err := yaml.Unmarshal(yo, &xrd)
+				if err := yaml.Unmarshal(yo, &xrd); err != nil {
+					return nil, errors.Wrap(err, "cannot unmarshal xrd")
+				}
+				updated, err := g.updateKubernetesValidation(&xrd)
 				if err != nil {
-					fmt.Printf("Error unmarshalling xrd %v", err)
-				} else {
-					updated, err := g.updateKubernetesValidation(&xrd)
-					if err != nil {
-						fmt.Printf("Error updating x-kubernetes-validations: %v", err)
-					}
-					if updated {
-						yo, err = yaml.Marshal(xrd)
-						if err != nil {
-							fmt.Printf("Error updating definition with new x-kubernetes-validations: %v", err)
-						}
-						err = yaml.Unmarshal(yo, &fc)
-						if err != nil {
-							fmt.Printf("Error unmarshalling object %v", err)
-						}
+					return nil, errors.Wrap(err, "cannot update x-kubernetes-validations")
+				}
+				if updated {
+					if yo, err = yaml.Marshal(xrd); err != nil {
+						return nil, errors.Wrap(err, "cannot update definition with new x-kubernetes-validations")
 					}
 				}
 			}
@@ -405,55 +360,33 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			// add defaultCompositeDeletePolicy property if its set
 			if g.DefaultCompositeDeletePolicy != nil && fn == "definition" {
 				var xrd crossplanev1.CompositeResourceDefinition
-				err := yaml.Unmarshal(yo, &xrd)
-				if err != nil {
-					fmt.Printf("Error unmarshalling xrd %v", err)
-				} else {
-					updated, err := g.setDefaultCompositeDeletePolicy(&xrd)
-					if err != nil {
-						fmt.Printf("Error updating defaultCompositeDeletePolicy: %v", err)
-					}
-					if updated {
-						yo, err = yaml.Marshal(xrd)
-						if err != nil {
-							fmt.Printf("Error updating definition with new defaultCompositeDeletePolicy: %v", err)
-						}
-						err = yaml.Unmarshal(yo, &fc)
-						if err != nil {
-							fmt.Printf("Error unmarshalling object %v", err)
-						}
-					}
+				if err := yaml.Unmarshal(yo, &xrd); err != nil {
+					return nil, errors.Wrap(err, "cannot unmarshal xrd")
 				}
-			}
-
-			if err != nil {
-				fmt.Printf("Error converting %s to YAML: %v", fn, err)
-			}
-			fp := filepath.Join(outPath, fn) + ".yaml"
-
-			// Check if file already exists
-			if _, err := os.Stat(fp); err == nil {
-				yi, err := os.ReadFile(fp)
+				updated, err := g.setDefaultCompositeDeletePolicy(&xrd)
 				if err != nil {
-					fmt.Printf("Error reading from existing output file: %v", err)
+					return nil, errors.Wrap(err, "cannot update defaultCompositeDeletePolicy")
 				}
-				ec := map[string]interface{}{}
-				if err := yaml.Unmarshal(yi, &ec); err != nil {
-					fmt.Printf("Error unmarshaling existing output file: %v", err)
+				if updated {
+					if yo, err = yaml.Marshal(xrd); err != nil {
+						return nil, errors.Wrap(err, "cannot update definition with new defaultCompositeDeletePolicy")
+					}
 				}
+			}
 
-				if cmp.Equal(fc, ec) {
-					continue
+			if g.crossplaneVersion(generatorConfig) == t.CrossplaneV2 && fn == "definition" {
+				if yo, err = setNamespacedScope(yo); err != nil {
+					return nil, err
 				}
 			}
 
-			fc := append(header, yo...)
-			err = os.WriteFile(fp, fc, 0644)
-			if err != nil {
-				fmt.Printf("Error writing Generated File %s: %v", fp, err)
-			}
+			fp := filepath.Join(outPath, fn) + ".yaml"
+			files = append(files, generatedFile{path: fp, content: yo})
 		}
 	} else {
+		crossplaneVersion := g.crossplaneVersion(generatorConfig)
+		provider := g.Provider
+		provider.CRD.Version = versions[0].CRDVersion
 		g2 := generator.XGenerator{
 			Group:                        g.Group,
 			Name:                         g.Name,
@@ -462,11 +395,12 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			PatchlName:                   g.PatchlName,
 			ConnectionSecretKeys:         g.ConnectionSecretKeys,
 			Compositions:                 g.Compositions,
-			Version:                      g.Version,
-			Crd:                          g.crd,
-			Provider:                     g.Provider,
+			Version:                      versions[0].Name,
+			Crd:                          crd,
+			Provider:                     provider,
 			OverrideFields:               g.OverrideFields,
 			Labels:                       g.Labels,
+			Tags:                         g.Tags,
 			GlobalLabels:                 globalLabels,
 			GeneratorConfig:              *generatorConfig,
 			ReadinessChecks:              g.ReadinessChecks,
@@ -475,11 +409,24 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			ExpandCompositionName:        generatorConfig.ExpandCompositionName,
 			TagType:                      g.TagType,
 			TagProperty:                  g.TagProperty,
+			TagKeyField:                  g.TagKeyField,
+			TagValueField:                g.TagValueField,
+			InitProviderFields:           initProviderFields,
+			CrossplaneVersion:            &crossplaneVersion,
+			ServedVersions:               versions[1:],
 			AutoReadyFunction:            generatorConfig.AutoReadyFunction,
 			OverrideFieldsInClaim:        g.OverrideFieldsInClaim,
 			PatchAndTransfromFunction:    generatorConfig.PatchAndTransfromFunction,
+			CompositionBackend:           g.compositionBackend(generatorConfig),
+			GoTemplatingFunction:         generatorConfig.GoTemplatingFunction,
+			KCLFunction:                  generatorConfig.KCLFunction,
 			DefaultCompositeDeletePolicy: g.DefaultCompositeDeletePolicy,
 		}
+		g2.Resources, err = g.generatorResources(generatorConfig)
+		if err != nil {
+			return nil, err
+		}
+		g2.References = g.References
 		if g.AdditionalPipelineSteps != nil {
 			g2.AdditionalPipelineSteps = g.AdditionalPipelineSteps
 		} else {
@@ -488,16 +435,18 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 		filename := filepath.Join(outPath, "definition") + ".yaml"
 		xrd, err := g2.GenerateXRD()
 		if err != nil {
-			log.Fatalf("Error creating xrd: %v", err)
+			return nil, errors.Wrap(err, "cannot create xrd")
 		}
-		rawContent, err := json.Marshal(xrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Object)
-		if err != nil {
-			fmt.Printf("Error marhalling object: %v", err)
+		for i, v := range xrd.Spec.Versions {
+			rawContent, err := json.Marshal(v.Schema.OpenAPIV3Schema.Object)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot marshal xrd schema")
+			}
+			xrd.Spec.Versions[i].Schema.OpenAPIV3Schema.Raw = rawContent
 		}
-		xrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Raw = rawContent
 		_, err = g.updateKubernetesValidation(xrd)
 		if err != nil {
-			fmt.Printf("Error updating x-kubernetes-validations: %v", err)
+			return nil, errors.Wrap(err, "cannot update x-kubernetes-validations")
 		}
 		xrd2 := map[string]interface{}{
 			"apiVersion": xrd.APIVersion,
@@ -515,17 +464,17 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 		}
 		fileContent, err := yaml.Marshal(xrd2)
 		if err != nil {
-			fmt.Printf("Error updating definition with new x-kubernetes-validations: %v", err)
+			return nil, errors.Wrap(err, "cannot convert definition to YAML")
 		}
-		content := append(header, fileContent...)
-		err = os.WriteFile(filename, content, 0644)
-		if err != nil {
-			fmt.Printf("Error writing Generated File %s: %v", filename, err)
+		if crossplaneVersion == t.CrossplaneV2 {
+			if fileContent, err = setNamespacedScope(fileContent); err != nil {
+				return nil, err
+			}
 		}
-		// filename = filepath.Join(outPath, "commposition") + ".yaml"
+		files = append(files, generatedFile{path: filename, content: fileContent})
 		compositions, err := g2.GenerateComposition()
 		if err != nil {
-			log.Fatalf("Error creating composition: %v", err)
+			return nil, errors.Wrap(err, "cannot create composition")
 		}
 		for _, p := range compositions {
 
@@ -547,15 +496,102 @@ func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptF
 			}
 			fileContent, err = yaml.Marshal(compositionContent)
 			if err != nil {
-				fmt.Printf("Error updating definition with new x-kubernetes-validations: %v", err)
-			}
-			content := append(header, fileContent...)
-			err = os.WriteFile(filename, content, 0644)
-			if err != nil {
-				fmt.Printf("Error writing Generated File %s: %v", filename, err)
+				return nil, errors.Wrapf(err, "cannot convert composition %s to YAML", p.Name)
 			}
+			files = append(files, generatedFile{path: filename, content: fileContent})
+		}
+	}
+	return g.addExamples(generatorConfig, files, outPath)
+}
+
+// Hash of the inputs of the generation: the generate.yaml, the global
+// configuration, the crd and the version of the generator
+func (g *Generator) inputHash(generatorConfig *t.GeneratorConfig) string {
+	globalConfig, _ := json.Marshal(generatorConfig)
+	h := sha256.New()
+	for _, input := range [][]byte{g.configContent, globalConfig, []byte(g.crdChecksum), []byte(version.Version)} {
+		// length prefix the inputs, so moving bytes between them changes the hash
+		fmt.Fprintf(h, "%d:", len(input))
+		h.Write(input)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Exec renders the definition and compositions and writes them to the output
+// path. Existing files are only rewritten if their content changed.
+func (g *Generator) Exec(generatorConfig *t.GeneratorConfig, scriptPath, scriptFileOverride, outputPath string) error {
+	files, err := g.Render(generatorConfig, scriptPath, scriptFileOverride, outputPath)
+	if err != nil {
+		return err
+	}
+	return g.writeFiles(generatorConfig, files)
+}
+
+// Write the rendered files with the autogenerated header
+func (g *Generator) writeFiles(generatorConfig *t.GeneratorConfig, files []generatedFile) error {
+	header := []byte(fmt.Sprintf(autogenHeader, version.Version, g.inputHash(generatorConfig)))
+	for _, f := range files {
+		// Check if file already exists
+		if existing, err := os.ReadFile(f.path); err == nil && sameContent(existing, f.content) {
+			continue
+		}
+		err := os.WriteFile(f.path, append(header, f.content...), 0644)
+		if err != nil {
+			return errors.Wrapf(err, "cannot write generated file %s", f.path)
+		}
+	}
+	return nil
+}
+
+// Check renders the definition and compositions and compares them with the
+// files in the output path. A unified diff is written to out for each file
+// that would change, the result is true if all files are up to date.
+func (g *Generator) Check(generatorConfig *t.GeneratorConfig, scriptPath, scriptFileOverride, outputPath string, out io.Writer) (bool, error) {
+	files, err := g.Render(generatorConfig, scriptPath, scriptFileOverride, outputPath)
+	if err != nil {
+		return false, err
+	}
+	return checkFiles(files, out), nil
+}
+
+// Compare the rendered files with the existing ones and write a diff of the
+// files that would change to out
+func checkFiles(files []generatedFile, out io.Writer) bool {
+	upToDate := true
+	for _, f := range files {
+		existing, err := os.ReadFile(f.path)
+		if err == nil && sameContent(existing, f.content) {
+			continue
 		}
+		upToDate = false
+		from := f.path
+		if err != nil {
+			from = "/dev/null"
+		}
+		fmt.Fprint(out, unifiedDiff(from, f.path, stripHeader(existing), f.content))
+	}
+	return upToDate
+}
+
+// Check if an existing file has the same content as a generated file,
+// ignoring comments and formatting
+func sameContent(existing, generated []byte) bool {
+	var e, c interface{}
+	if err := yaml.Unmarshal(existing, &e); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(generated, &c); err != nil {
+		return false
+	}
+	return cmp.Equal(e, c)
+}
+
+// Remove the autogenerated header from the content of a generated file
+func stripHeader(content []byte) []byte {
+	for bytes.HasPrefix(content, []byte("##")) {
+		_, content, _ = bytes.Cut(content, []byte("\n"))
 	}
+	return bytes.TrimPrefix(content, []byte("\n"))
 }
 
 func (g *Generator) setDefaultCompositeDeletePolicy(xrd *crossplanev1.CompositeResourceDefinition) (bool, error) {
@@ -588,7 +624,19 @@ func (g *Generator) setDefaultCompositeDeletePolicy(xrd *crossplanev1.CompositeR
 }
 
 func (g *Generator) updateKubernetesValidation(xrd *crossplanev1.CompositeResourceDefinition) (bool, error) {
-	schemaRaw := xrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Raw
+	updated := false
+	for i := range xrd.Spec.Versions {
+		u, err := g.updateVersionKubernetesValidation(&xrd.Spec.Versions[i])
+		if err != nil {
+			return false, err
+		}
+		updated = updated || u
+	}
+	return updated, nil
+}
+
+func (g *Generator) updateVersionKubernetesValidation(version *crossplanev1.CompositeResourceDefinitionVersion) (bool, error) {
+	schemaRaw := version.Schema.OpenAPIV3Schema.Raw
 	var schema map[string]interface{}
 	err := json.Unmarshal(schemaRaw, &schema)
 	if err != nil {
@@ -658,7 +706,7 @@ func (g *Generator) updateKubernetesValidation(xrd *crossplanev1.CompositeResour
 	if err != nil {
 		return false, err
 	}
-	xrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Raw = newSchema
+	version.Schema.OpenAPIV3Schema.Raw = newSchema
 
 	return true, nil
 }
@@ -678,7 +726,16 @@ func (g *Generator) CheckConfig(generatorConfig *t.GeneratorConfig) error {
 	if len(listOfErrFields) > 0 {
 		return errors.New("Not all tags.fromLables entries exist in labels.fromCRD or global generator config or globalLabels: " + getJsonStringFromList(&listOfErrFields))
 	}
-	return nil
+	if err := g.checkCrossplaneVersion(generatorConfig); err != nil {
+		return err
+	}
+	if err := g.checkCompositionBackend(generatorConfig); err != nil {
+		return err
+	}
+	if err := g.checkVersions(); err != nil {
+		return err
+	}
+	return g.checkResources(generatorConfig)
 }
 
 func (g *Generator) UpdateConfig(generatorConfig *t.GeneratorConfig) {
@@ -707,7 +764,38 @@ func (g *Generator) UpdateConfig(generatorConfig *t.GeneratorConfig) {
 	}
 }
 
-func parseArgs(configFile, generatorFile, inputPath, scriptFile, scriptPath, outputPath *string) error {
+// Command line arguments of the generator
+type arguments struct {
+	configFile    string
+	generatorFile string
+	inputPath     string
+	scriptFile    string
+	scriptPath    string
+	outputPath    string
+	crdDir        string
+	cacheDir      string
+	noCache       bool
+	lockFile      string
+	verifyLock    bool
+	check         bool
+	keepGoing     bool
+	concurrency   int
+	// unknown fields in config files are only warnings instead of errors
+	allowUnknownFields bool
+}
+
+func parseArgs(args *arguments) error {
+	if err := addFlags(flag.CommandLine, args); err != nil {
+		return err
+	}
+	flag.Parse()
+	setDefaultArgs(args)
+	return nil
+}
+
+// Add the flags of the generator to the flag set, the subcommands processing
+// the generate files share them
+func addFlags(fs *flag.FlagSet, args *arguments) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
@@ -718,29 +806,100 @@ func parseArgs(configFile, generatorFile, inputPath, scriptFile, scriptPath, out
 	}
 	sp := filepath.Join(filepath.Dir(b), "functions")
 
-	flag.StringVar(generatorFile, "inputName", "generate.yaml", "input filename to search for in current directory")
-	flag.StringVar(inputPath, "inputPath", cwd, "input filename to search for in current directory")
-	flag.StringVar(scriptFile, "scriptName", "", "script filename to execute against input file(s) (default: generate.jsonnet or specified in each input file)")
-	flag.StringVar(scriptPath, "scriptPath", sp, "path where script files are loaded from ")
-	flag.StringVar(outputPath, "outputPath", "", "path where output files are created (default: same directory as input file)")
-	flag.StringVar(configFile, "configFile", "./generator-config.yaml", "path where global config file can be found (default: ./generator-config.yaml)")
-
-	flag.Parse()
+	fs.StringVar(&args.generatorFile, "inputName", "generate.yaml", "input filename to search for in current directory")
+	fs.StringVar(&args.inputPath, "inputPath", cwd, "input filename to search for in current directory")
+	fs.StringVar(&args.scriptFile, "scriptName", "", "script filename to execute against input file(s) (default: generate.jsonnet or specified in each input file)")
+	fs.StringVar(&args.scriptPath, "scriptPath", sp, "path where script files are loaded from ")
+	fs.StringVar(&args.outputPath, "outputPath", "", "path where output files are created (default: same directory as input file)")
+	fs.StringVar(&args.configFile, "configFile", "./generator-config.yaml", "path where global config file can be found (default: ./generator-config.yaml)")
+	fs.StringVar(&args.crdDir, "crdDir", "", "local directory (or file) the crd files are loaded from instead of downloading them, overrides provider.crdPath and provider.baseURL")
+	fs.StringVar(&args.cacheDir, "cacheDir", defaultCacheDir(), "directory where downloaded crd files are cached")
+	fs.BoolVar(&args.noCache, "noCache", false, "always download crd files instead of using the cache")
+	fs.StringVar(&args.lockFile, "lockFile", "", "path of the lock file recording the checksums of downloaded crds (default: "+lockFileName+" next to the global config file)")
+	fs.BoolVar(&args.verifyLock, "verifyLock", false, "fail if a downloaded crd does not match the checksum recorded in the lock file")
+	fs.BoolVar(&args.keepGoing, "keepGoing", false, "continue with the remaining generate files after a failure, the generator still exits with a non-zero exit code")
+	fs.IntVar(&args.concurrency, "concurrency", runtime.NumCPU(), "number of generate files processed in parallel")
+	fs.BoolVar(&args.allowUnknownFields, "allowUnknownFields", false, "only warn about unknown fields in generate files and the global config file instead of failing")
+	fs.BoolVar(&args.check, "check", false, "only compare the generated files with the existing ones, print a diff and fail if any file is out of date")
 
 	return nil
 }
 
-// Load the GeneratorConfig from the given path
-func loadGeneratorConfig(path string) (*t.GeneratorConfig, error) {
+// Set the defaults of the arguments depending on other arguments
+func setDefaultArgs(args *arguments) {
+	if args.lockFile == "" {
+		args.lockFile = filepath.Join(filepath.Dir(args.configFile), lockFileName)
+	}
+}
+
+// Find the generate files below the input path
+func findGenerators(args *arguments) ([]string, error) {
+	list := []string{}
+	err := filepath.Walk(args.inputPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if filepath.Base(path) == args.generatorFile {
+			list = append(list, path)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Create the loader of the crds of all generators
+func newCRDLoader(args *arguments) (*crdLoader, error) {
+	loader := &crdLoader{
+		crdDir:     args.crdDir,
+		verifyLock: args.verifyLock,
+		bundles:    newBundleCache(),
+		fetches:    newFetchGroup(),
+	}
+	if !args.noCache {
+		loader.cache = &crdCache{dir: args.cacheDir}
+	}
+	lock, err := loadLockFile(args.lockFile)
+	if err != nil {
+		return nil, err
+	}
+	loader.lock = lock
+	return loader, nil
+}
+
+// Load the GeneratorConfig from the given path. Unknown fields are errors in
+// strict mode, otherwise they are logged as warnings.
+func loadGeneratorConfig(path string, strict bool) (*t.GeneratorConfig, error) {
 	var generatorConfig t.GeneratorConfig
 	y, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	err = yaml.Unmarshal(y, &generatorConfig)
+	warnings, err := unmarshalConfig(path, y, &generatorConfig, strict)
+	for _, w := range warnings {
+		log.Printf("Warning: %s\n", w)
+	}
 	if err != nil {
 		return nil, err
 	}
+	// a relative crdPath or package is relative to the global config file
+	if generatorConfig.Provider.CRDPath != nil {
+		crdPath := resolvePath(filepath.Dir(path), *generatorConfig.Provider.CRDPath)
+		generatorConfig.Provider.CRDPath = &crdPath
+	}
+	if generatorConfig.Provider.Package != nil {
+		pkg := resolvePath(filepath.Dir(path), *generatorConfig.Provider.Package)
+		generatorConfig.Provider.Package = &pkg
+	}
+	if generatorConfig.Configuration != nil {
+		generatorConfig.Configuration.Path = resolvePath(filepath.Dir(path), generatorConfig.Configuration.Path)
+	}
+	if generatorConfig.FunctionsPath != nil {
+		functionsPath := resolvePath(filepath.Dir(path), *generatorConfig.FunctionsPath)
+		generatorConfig.FunctionsPath = &functionsPath
+	}
 
 	return &generatorConfig, nil
 }
@@ -776,33 +935,152 @@ func checkConfig(generatorConfig *t.GeneratorConfig) error {
 	return nil
 }
 
-func main() {
-	var configFile, generatorFile, inputPath, scriptFile, scriptPath, outputPath string
+// Generate the files of the generate.yaml at path. In check mode the files are
+// only compared with the existing ones, the result is false if any is out of date.
+// The packages used by the files are added to dependencies unless it is nil.
+// All output is written to out.
+func generate(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, dependencies *packageDependencies, out io.Writer) (bool, error) {
+	g, err := loadGenerator(path, args, generatorConfig, loader, out)
+	if err != nil || g == nil {
+		return err == nil, err
+	}
 
-	if err := parseArgs(&configFile, &generatorFile, &inputPath, &scriptFile, &scriptPath, &outputPath); err != nil {
-		fmt.Printf("Error parsing arguments: %s", err)
+	files, err := g.Render(generatorConfig, args.scriptPath, args.scriptFile, args.outputPath)
+	if err != nil {
+		return false, err
 	}
+	if err := checkFunctions(generatorConfig, files); err != nil {
+		return false, err
+	}
+	if dependencies != nil {
+		if err := dependencies.add(g, generatorConfig, files); err != nil {
+			return false, err
+		}
+	}
+	if args.check {
+		return checkFiles(files, out), nil
+	}
+	return true, g.writeFiles(generatorConfig, files)
+}
 
-	list := []string{}
+// Load the generate file at path and its crd, nil is returned if the generator
+// asks to be ignored
+func loadGenerator(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, out io.Writer) (*Generator, error) {
+	g, err := (&Generator{
+		OverrideFields:        []t.OverrideField{},
+		Compositions:          []t.Composition{},
+		OverrideFieldsInClaim: []t.OverrideFieldInClaim{},
+		log:                   log.New(out, "", log.LstdFlags),
+	}).LoadConfig(path, !args.allowUnknownFields)
+	if err != nil {
+		return nil, err
+	}
+	if g.Ignore {
+		fmt.Fprintf(out, "Generator for %s asks to be ignored, skipping...\n", g.Name)
+		return nil, nil
+	}
+	if err := g.LoadCRD(generatorConfig, loader); err != nil {
+		return nil, errors.Wrap(err, "CRD config not valid")
+	}
 
-	err := filepath.Walk(inputPath, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
+	g.UpdateConfig(generatorConfig)
+	if err := g.CheckConfig(generatorConfig); err != nil {
+		return nil, errors.Wrap(err, "CRD config not valid")
+	}
+	return g, nil
+}
+
+// generateResult is the result of generating a single generate.yaml
+type generateResult struct {
+	// output is the buffered output of the generator
+	output   bytes.Buffer
+	upToDate bool
+	err      error
+	// skipped is set if the generate.yaml was not processed because of a previous failure
+	skipped bool
+	// done is closed once the result is available
+	done chan struct{}
+}
+
+// Run generate for all paths using the given number of workers. The results
+// are returned in the order of paths and become available as soon as the
+// corresponding path is processed. Unless keepGoing is set, paths not yet
+// started after a failure are skipped.
+func generateAll(paths []string, concurrency int, keepGoing bool, generate func(path string, out io.Writer) (bool, error)) []*generateResult {
+	results := make([]*generateResult, len(paths))
+	for i := range results {
+		results[i] = &generateResult{done: make(chan struct{})}
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan int)
+	var failed atomic.Bool
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			for i := range jobs {
+				r := results[i]
+				if failed.Load() && !keepGoing {
+					r.skipped = true
+				} else {
+					r.upToDate, r.err = generate(paths[i], &r.output)
+					if r.err != nil {
+						failed.Store(true)
+					}
+				}
+				close(r.done)
+			}
+		}()
+	}
+	go func() {
+		for i := range paths {
+			jobs <- i
 		}
-		if filepath.Base(path) == generatorFile {
-			list = append(list, path)
+		close(jobs)
+	}()
+	return results
+}
+
+// A generate.yaml that could not be processed
+type failure struct {
+	path string
+	err  error
+}
+
+// Print a summary of all failed generate.yaml files
+func printFailures(out io.Writer, failures []failure, total int) {
+	fmt.Fprintf(out, "\n%d of %d generators failed:\n", len(failures), total)
+	for _, f := range failures {
+		fmt.Fprintf(out, "  %s: %s\n", f.path, strings.TrimSpace(f.err.Error()))
+	}
+}
+
+func main() {
+	var args arguments
+
+	if ok, err := runSubcommand(os.Args[1:], os.Stdout); ok || err != nil {
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
 		}
-		return nil
-	})
+		os.Exit(0)
+	}
+
+	if err := parseArgs(&args); err != nil {
+		fmt.Printf("Error parsing arguments: %s\n", err)
+		os.Exit(1)
+	}
 
+	list, err := findGenerators(&args)
 	if err != nil {
-		fmt.Printf("Error finding generator files: %s", err)
+		fmt.Printf("Error finding generator files: %s\n", err)
+		os.Exit(1)
 	}
 
-	fmt.Println(configFile)
-	generatorConfig, err := loadGeneratorConfig(configFile)
+	fmt.Println(args.configFile)
+	generatorConfig, err := loadGeneratorConfig(args.configFile, !args.allowUnknownFields)
 	if err != nil {
-		fmt.Println("Could not find generator config file")
+		fmt.Printf("Could not load generator config file: %s\n", err)
 		os.Exit(1)
 	}
 	err = checkConfig(generatorConfig)
@@ -811,27 +1089,68 @@ func main() {
 		os.Exit(1)
 	}
 
-	for _, m := range list {
-		g := (&Generator{
-			OverrideFields:        []t.OverrideField{},
-			Compositions:          []t.Composition{},
-			OverrideFieldsInClaim: []t.OverrideFieldInClaim{},
-		}).LoadConfig(m)
-		if g.Ignore {
-			fmt.Printf("Generator for %s asks to be ignored, skipping...\n", g.Name)
+	loader, err := newCRDLoader(&args)
+	if err != nil {
+		fmt.Printf("Could not load lock file: %s\n", err)
+		os.Exit(1)
+	}
+
+	var dependencies *packageDependencies
+	if generatorConfig.Configuration != nil || generatorConfig.FunctionsPath != nil {
+		dependencies = newPackageDependencies()
+	}
+
+	outdated := false
+	failures := []failure{}
+	skipped := 0
+	results := generateAll(list, args.concurrency, args.keepGoing, func(path string, out io.Writer) (bool, error) {
+		return generate(path, &args, generatorConfig, loader, dependencies, out)
+	})
+	// print the output of the generators in the order of the input files
+	for i, r := range results {
+		<-r.done
+		if r.skipped {
+			skipped++
 			continue
 		}
-		if err := g.LoadCRD(generatorConfig); err != nil {
-			fmt.Printf("CRD config not valid, skiping this : %s\n", err)
+		os.Stdout.Write(r.output.Bytes())
+		if r.err != nil {
+			fmt.Printf("Error generating %s: %s\n", list[i], strings.TrimSpace(r.err.Error()))
+			failures = append(failures, failure{path: list[i], err: r.err})
 			continue
 		}
-
-		g.UpdateConfig(generatorConfig)
-		if err := g.CheckConfig(generatorConfig); err != nil {
-			fmt.Printf("CRD config not valid, skiping this : %s\n", err)
-			continue
+		if !r.upToDate {
+			outdated = true
 		}
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d remaining generators, use --keepGoing to continue after failures\n", skipped)
+	}
 
-		g.Exec(generatorConfig, scriptPath, scriptFile, outputPath)
+	exitCode := 0
+	if !args.check {
+		if err := loader.lock.Write(); err != nil {
+			fmt.Printf("Could not write lock file: %s\n", err)
+			exitCode = 1
+		}
+	}
+	if len(failures) > 0 {
+		printFailures(os.Stdout, failures, len(list))
+		exitCode = 1
+	}
+	// the dependencies are only complete if all generators succeeded
+	if dependencies != nil && len(failures) == 0 && skipped == 0 {
+		upToDate, err := updatePackageFiles(&args, generatorConfig, dependencies, os.Stdout)
+		if err != nil {
+			fmt.Printf("Could not update the package files: %s\n", err)
+			exitCode = 1
+		} else if !upToDate {
+			outdated = true
+		}
+	}
+	if outdated {
+		fmt.Println("Generated files are out of date, run the generator to update them")
+		exitCode = 1
 	}
+	os.Exit(exitCode)
 }
@@ -1,33 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/crossplane-contrib/x-generation/pkg/version"
 	cv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/ghodss/yaml"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 )
 
-func Test_tryToGetTags(t *testing.T) {
+func Test_detectTagsDefault(t *testing.T) {
 	type args struct {
 		crd     extv1.CustomResourceDefinition
 		version string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    *extv1.JSONSchemaProps
-		want1   string
-		wantErr bool
+		name string
+		args args
+		want tagDetection
 	}{
 		{
-			name: "Should have tags",
+			name: "Should find keyValueArray",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -70,56 +75,14 @@ func Test_tryToGetTags(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want: &extv1.JSONSchemaProps{
-				Type: "array",
-				Items: &extv1.JSONSchemaPropsOrArray{
-					Schema: &extv1.JSONSchemaProps{
-						Type: "object",
-						Properties: map[string]extv1.JSONSchemaProps{
-							"key": {
-								Type: "string",
-							},
-							"value": {
-								Type: "string",
-							},
-						},
-					},
-				},
-			},
-			want1:   "spec.forProvider.tags",
-			wantErr: false,
+			want: tagDetection{tagType: "keyValueArray", property: "spec.forProvider.tags", keyField: "key", valueField: "value"},
 		},
 		{
-			name: "Should have find the right version",
+			name: "Should find tagKeyTagValueArray",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
 						Versions: []extv1.CustomResourceDefinitionVersion{
-							{
-								Name: "v1beta1",
-								Schema: &extv1.CustomResourceValidation{
-									OpenAPIV3Schema: &extv1.JSONSchemaProps{
-										Properties: map[string]extv1.JSONSchemaProps{
-											"spec": {
-												Properties: map[string]extv1.JSONSchemaProps{
-													"forProvider": {
-														Properties: map[string]extv1.JSONSchemaProps{
-															"tags": {
-																Type: "object",
-																AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
-																	Schema: &extv1.JSONSchemaProps{
-																		Type: "string",
-																	},
-																},
-															},
-														},
-													},
-												},
-											},
-										},
-									},
-								},
-							},
 							{
 								Name: "v1alpha1",
 								Schema: &extv1.CustomResourceValidation{
@@ -135,10 +98,10 @@ func Test_tryToGetTags(t *testing.T) {
 																	Schema: &extv1.JSONSchemaProps{
 																		Type: "object",
 																		Properties: map[string]extv1.JSONSchemaProps{
-																			"key": {
+																			"tagKey": {
 																				Type: "string",
 																			},
-																			"value": {
+																			"tagValue": {
 																				Type: "string",
 																			},
 																		},
@@ -158,27 +121,10 @@ func Test_tryToGetTags(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want: &extv1.JSONSchemaProps{
-				Type: "array",
-				Items: &extv1.JSONSchemaPropsOrArray{
-					Schema: &extv1.JSONSchemaProps{
-						Type: "object",
-						Properties: map[string]extv1.JSONSchemaProps{
-							"key": {
-								Type: "string",
-							},
-							"value": {
-								Type: "string",
-							},
-						},
-					},
-				},
-			},
-			want1:   "spec.forProvider.tags",
-			wantErr: false,
+			want: tagDetection{tagType: "tagKeyTagValueArray", property: "spec.forProvider.tags", keyField: "tagKey", valueField: "tagValue"},
 		},
 		{
-			name: "Should find tagging",
+			name: "Should find tagObject",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -192,23 +138,11 @@ func Test_tryToGetTags(t *testing.T) {
 												Properties: map[string]extv1.JSONSchemaProps{
 													"forProvider": {
 														Properties: map[string]extv1.JSONSchemaProps{
-															"tagging": {
-																Properties: map[string]extv1.JSONSchemaProps{
-																	"tagSet": {
-																		Type: "array",
-																		Items: &extv1.JSONSchemaPropsOrArray{
-																			Schema: &extv1.JSONSchemaProps{
-																				Type: "object",
-																				Properties: map[string]extv1.JSONSchemaProps{
-																					"keyA": {
-																						Type: "string",
-																					},
-																					"valueA": {
-																						Type: "string",
-																					},
-																				},
-																			},
-																		},
+															"tags": {
+																Type: "object",
+																AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
+																	Schema: &extv1.JSONSchemaProps{
+																		Type: "string",
 																	},
 																},
 															},
@@ -225,27 +159,10 @@ func Test_tryToGetTags(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want: &extv1.JSONSchemaProps{
-				Type: "array",
-				Items: &extv1.JSONSchemaPropsOrArray{
-					Schema: &extv1.JSONSchemaProps{
-						Type: "object",
-						Properties: map[string]extv1.JSONSchemaProps{
-							"keyA": {
-								Type: "string",
-							},
-							"valueA": {
-								Type: "string",
-							},
-						},
-					},
-				},
-			},
-			want1:   "spec.forProvider.tagging.tagSet",
-			wantErr: false,
+			want: tagDetection{tagType: "tagObject", property: "spec.forProvider.tags"},
 		},
 		{
-			name: "Should find no tags",
+			name: "Should find no tags if no tags",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -258,11 +175,7 @@ func Test_tryToGetTags(t *testing.T) {
 											"spec": {
 												Properties: map[string]extv1.JSONSchemaProps{
 													"forProvider": {
-														Properties: map[string]extv1.JSONSchemaProps{
-															"tagging": {
-																Properties: map[string]extv1.JSONSchemaProps{},
-															},
-														},
+														Properties: map[string]extv1.JSONSchemaProps{},
 													},
 												},
 											},
@@ -275,41 +188,10 @@ func Test_tryToGetTags(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:    nil,
-			want1:   "",
-			wantErr: true,
+			want: noTags,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, got1, err := tryToGetTags(tt.args.crd, tt.args.version)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("tryToGetTags() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("tryToGetTags() got = %v, want %v", got, tt.want)
-			}
-			if got1 != tt.want1 {
-				t.Errorf("tryToGetTags() got1 = %v, want %v", got1, tt.want1)
-			}
-		})
-	}
-}
-
-func Test_checkTagType(t *testing.T) {
-	type args struct {
-		crd     extv1.CustomResourceDefinition
-		version string
-	}
-	tests := []struct {
-		name  string
-		args  args
-		want  string
-		want1 string
-	}{
 		{
-			name: "Should find keyValueArray",
+			name: "Should find no tags if wrong types array",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -329,7 +211,7 @@ func Test_checkTagType(t *testing.T) {
 																	Schema: &extv1.JSONSchemaProps{
 																		Type: "object",
 																		Properties: map[string]extv1.JSONSchemaProps{
-																			"key": {
+																			"key1": {
 																				Type: "string",
 																			},
 																			"value": {
@@ -352,11 +234,10 @@ func Test_checkTagType(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:  "keyValueArray",
-			want1: "spec.forProvider.tags",
+			want: noTags,
 		},
 		{
-			name: "Should find tagKeyTagValueArray",
+			name: "Should find no tags if wrong types object",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -371,18 +252,10 @@ func Test_checkTagType(t *testing.T) {
 													"forProvider": {
 														Properties: map[string]extv1.JSONSchemaProps{
 															"tags": {
-																Type: "array",
-																Items: &extv1.JSONSchemaPropsOrArray{
+																Type: "object",
+																AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
 																	Schema: &extv1.JSONSchemaProps{
-																		Type: "object",
-																		Properties: map[string]extv1.JSONSchemaProps{
-																			"tagKey": {
-																				Type: "string",
-																			},
-																			"tagValue": {
-																				Type: "string",
-																			},
-																		},
+																		Type: "int",
 																	},
 																},
 															},
@@ -399,17 +272,16 @@ func Test_checkTagType(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:  "tagKeyTagValueArray",
-			want1: "spec.forProvider.tags",
+			want: noTags,
 		},
 		{
-			name: "Should find tagObject",
+			name: "Should have find the right version",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
 						Versions: []extv1.CustomResourceDefinitionVersion{
 							{
-								Name: "v1alpha1",
+								Name: "v1beta1",
 								Schema: &extv1.CustomResourceValidation{
 									OpenAPIV3Schema: &extv1.JSONSchemaProps{
 										Properties: map[string]extv1.JSONSchemaProps{
@@ -433,20 +305,6 @@ func Test_checkTagType(t *testing.T) {
 									},
 								},
 							},
-						},
-					},
-				},
-				version: "v1alpha1",
-			},
-			want:  "tagObject",
-			want1: "spec.forProvider.tags",
-		},
-		{
-			name: "Should find no tags if no tags",
-			args: args{
-				crd: extv1.CustomResourceDefinition{
-					Spec: extv1.CustomResourceDefinitionSpec{
-						Versions: []extv1.CustomResourceDefinitionVersion{
 							{
 								Name: "v1alpha1",
 								Schema: &extv1.CustomResourceValidation{
@@ -455,7 +313,24 @@ func Test_checkTagType(t *testing.T) {
 											"spec": {
 												Properties: map[string]extv1.JSONSchemaProps{
 													"forProvider": {
-														Properties: map[string]extv1.JSONSchemaProps{},
+														Properties: map[string]extv1.JSONSchemaProps{
+															"tags": {
+																Type: "array",
+																Items: &extv1.JSONSchemaPropsOrArray{
+																	Schema: &extv1.JSONSchemaProps{
+																		Type: "object",
+																		Properties: map[string]extv1.JSONSchemaProps{
+																			"key": {
+																				Type: "string",
+																			},
+																			"value": {
+																				Type: "string",
+																			},
+																		},
+																	},
+																},
+															},
+														},
 													},
 												},
 											},
@@ -468,11 +343,10 @@ func Test_checkTagType(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:  "noTag",
-			want1: "",
+			want: tagDetection{tagType: "keyValueArray", property: "spec.forProvider.tags", keyField: "key", valueField: "value"},
 		},
 		{
-			name: "Should find no tags if wrong types array",
+			name: "Should find no tags if tagSet items have unknown names",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -486,17 +360,21 @@ func Test_checkTagType(t *testing.T) {
 												Properties: map[string]extv1.JSONSchemaProps{
 													"forProvider": {
 														Properties: map[string]extv1.JSONSchemaProps{
-															"tags": {
-																Type: "array",
-																Items: &extv1.JSONSchemaPropsOrArray{
-																	Schema: &extv1.JSONSchemaProps{
-																		Type: "object",
-																		Properties: map[string]extv1.JSONSchemaProps{
-																			"key1": {
-																				Type: "string",
-																			},
-																			"value": {
-																				Type: "string",
+															"tagging": {
+																Properties: map[string]extv1.JSONSchemaProps{
+																	"tagSet": {
+																		Type: "array",
+																		Items: &extv1.JSONSchemaPropsOrArray{
+																			Schema: &extv1.JSONSchemaProps{
+																				Type: "object",
+																				Properties: map[string]extv1.JSONSchemaProps{
+																					"keyA": {
+																						Type: "string",
+																					},
+																					"valueA": {
+																						Type: "string",
+																					},
+																				},
 																			},
 																		},
 																	},
@@ -515,11 +393,10 @@ func Test_checkTagType(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:  "noTag",
-			want1: "",
+			want: noTags,
 		},
 		{
-			name: "Should find no tags if wrong types object",
+			name: "Should find no tags if tagging has no tagSet",
 			args: args{
 				crd: extv1.CustomResourceDefinition{
 					Spec: extv1.CustomResourceDefinitionSpec{
@@ -533,13 +410,8 @@ func Test_checkTagType(t *testing.T) {
 												Properties: map[string]extv1.JSONSchemaProps{
 													"forProvider": {
 														Properties: map[string]extv1.JSONSchemaProps{
-															"tags": {
-																Type: "object",
-																AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
-																	Schema: &extv1.JSONSchemaProps{
-																		Type: "int",
-																	},
-																},
+															"tagging": {
+																Properties: map[string]extv1.JSONSchemaProps{},
 															},
 														},
 													},
@@ -554,18 +426,14 @@ func Test_checkTagType(t *testing.T) {
 				},
 				version: "v1alpha1",
 			},
-			want:  "noTag",
-			want1: "",
+			want: noTags,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := checkTagType(tt.args.crd, tt.args.version)
+			got := detectTags(tt.args.crd, tt.args.version, defaultTagDetectors)
 			if got != tt.want {
-				t.Errorf("checkTagType() got = %v, want %v", got, tt.want)
-			}
-			if got1 != tt.want1 {
-				t.Errorf("checkTagType() got1 = %v, want %v", got1, tt.want1)
+				t.Errorf("detectTags() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
@@ -2486,7 +2354,10 @@ func Test_tryProperties(t *testing.T) {
 			cwd, _ := os.Getwd()
 
 			sp := filepath.Join(cwd, "functions")
-			g.Exec(&gConfig, sp, "", "")
+			err = g.Exec(&gConfig, sp, "", "")
+			if err != nil {
+				t.Errorf("could not generate files: %v", err)
+			}
 
 			path := filepath.Join(tempDir, "definition.yaml")
 			y, err := os.ReadFile(path)
@@ -2651,7 +2522,10 @@ func Test_noDefaultPatch(t *testing.T) {
 		cwd, _ := os.Getwd()
 
 		sp := filepath.Join(cwd, "functions")
-		g.Exec(&gConfig, sp, "", "")
+		err = g.Exec(&gConfig, sp, "", "")
+		if err != nil {
+			t.Errorf("could not generate files: %v", err)
+		}
 
 		path := filepath.Join(tempDir, "composition-configuration.yaml")
 		y, err := os.ReadFile(path)
@@ -2865,7 +2739,10 @@ func Test_setDefaultCompositeDeletePolicy(t *testing.T) {
 			cwd, _ := os.Getwd()
 
 			sp := filepath.Join(cwd, "functions")
-			g.Exec(&gConfig, sp, "", "")
+			err = g.Exec(&gConfig, sp, "", "")
+			if err != nil {
+				t.Errorf("could not generate files: %v", err)
+			}
 
 			path := filepath.Join(tempDir, "definition.yaml")
 			y, err := os.ReadFile(path)
@@ -2906,3 +2783,422 @@ func Test_setDefaultCompositeDeletePolicy(t *testing.T) {
 		})
 	}
 }
+
+// Create a generator for a simple crd writing its files to dir
+func newTestGenerator(t *testing.T, dir string, usePipeline bool) Generator {
+	t.Helper()
+	crd := extv1.CustomResourceDefinition{
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Versions: []extv1.CustomResourceDefinitionVersion{
+				{
+					Name: "testv1",
+					AdditionalPrinterColumns: []extv1.CustomResourceColumnDefinition{{
+						JSONPath: ".metadata.annotations.crossplane.io/external-name",
+						Name:     "EXTERNAL-NAME",
+						Type:     "string",
+					}},
+					Schema: &extv1.CustomResourceValidation{
+						OpenAPIV3Schema: &extv1.JSONSchemaProps{
+							Properties: map[string]extv1.JSONSchemaProps{
+								"spec": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"forProvider": {
+											Type: "object",
+											Properties: map[string]extv1.JSONSchemaProps{
+												"region": {
+													Type: "string",
+												},
+											},
+										},
+									},
+								},
+								"status": {
+									Type: "object",
+									Properties: map[string]extv1.JSONSchemaProps{
+										"name": {
+											Type: "string",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	crdSource, err := json.Marshal(crd)
+	if err != nil {
+		t.Fatalf("could not marshal crdSource")
+	}
+	plural := "TestObjects"
+	g := Generator{
+		Group:       "example.cloud",
+		Name:        "TestObject",
+		Version:     "testv1",
+		crd:         crd,
+		crdSource:   string(crdSource),
+		configPath:  dir,
+		UsePipeline: &usePipeline,
+		Provider: xtype.ProviderConfig{
+			CRD: xtype.CrdConfig{
+				Version: "testv1",
+			},
+		},
+		Plural: &plural,
+		Compositions: []xtype.Composition{
+			{
+				Name:     "configuration",
+				Provider: "sop",
+				Default:  true,
+			},
+		},
+		OverrideFields:        []xtype.OverrideField{},
+		OverrideFieldsInClaim: []xtype.OverrideFieldInClaim{},
+	}
+	return g
+}
+
+func TestGenerator_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		usePipeline bool
+	}{
+		{
+			name:        "Should detect changes of jsonnet output",
+			usePipeline: false,
+		},
+		{
+			name:        "Should detect changes of pipeline output",
+			usePipeline: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			g := newTestGenerator(t, tempDir, tt.usePipeline)
+
+			gConfig := xtype.GeneratorConfig{
+				CompositionIdentifier: "example.cloud",
+			}
+
+			cwd, _ := os.Getwd()
+			sp := filepath.Join(cwd, "functions")
+
+			var out bytes.Buffer
+			if upToDate, err := g.Check(&gConfig, sp, "", "", &out); err != nil || upToDate {
+				t.Errorf("Check() should report missing files, error = %v", err)
+			}
+			if !strings.Contains(out.String(), "--- /dev/null\n+++ "+filepath.Join(tempDir, "definition.yaml")) {
+				t.Errorf("Check() should print a diff for the missing definition, got %s", out.String())
+			}
+
+			if err := g.Exec(&gConfig, sp, "", ""); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			out.Reset()
+			if upToDate, err := g.Check(&gConfig, sp, "", "", &out); err != nil || !upToDate {
+				t.Errorf("Check() should report up to date files, got %s, error = %v", out.String(), err)
+			}
+
+			path := filepath.Join(tempDir, "composition-configuration.yaml")
+			y, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not load composition file")
+			}
+			y = []byte(strings.Replace(string(y), "  name: configuration", "  name: changed", 1))
+			if err := os.WriteFile(path, y, 0644); err != nil {
+				t.Fatalf("could not modify composition file")
+			}
+			out.Reset()
+			if upToDate, err := g.Check(&gConfig, sp, "", "", &out); err != nil || upToDate {
+				t.Errorf("Check() should report the modified composition, error = %v", err)
+			}
+			if !strings.Contains(out.String(), "-  name: changed") {
+				t.Errorf("Check() should print a diff for the modified composition, got %s", out.String())
+			}
+		})
+	}
+}
+
+func TestGenerator_Exec(t *testing.T) {
+	tests := []struct {
+		name        string
+		usePipeline bool
+	}{
+		{
+			name:        "Should write deterministic jsonnet output",
+			usePipeline: false,
+		},
+		{
+			name:        "Should write deterministic pipeline output",
+			usePipeline: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firstDir := t.TempDir()
+			secondDir := t.TempDir()
+
+			gConfig := xtype.GeneratorConfig{
+				CompositionIdentifier: "example.cloud",
+			}
+
+			cwd, _ := os.Getwd()
+			sp := filepath.Join(cwd, "functions")
+
+			first := newTestGenerator(t, firstDir, tt.usePipeline)
+			first.configContent = []byte("name: TestObject")
+			if err := first.Exec(&gConfig, sp, "", ""); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			second := newTestGenerator(t, secondDir, tt.usePipeline)
+			second.configContent = []byte("name: TestObject")
+			if err := second.Exec(&gConfig, sp, "", ""); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+
+			for _, file := range []string{"definition.yaml", "composition-configuration.yaml"} {
+				a, err := os.ReadFile(filepath.Join(firstDir, file))
+				if err != nil {
+					t.Fatalf("could not load %s", file)
+				}
+				b, err := os.ReadFile(filepath.Join(secondDir, file))
+				if err != nil {
+					t.Fatalf("could not load %s", file)
+				}
+				if string(a) != string(b) {
+					t.Errorf("%s differs between runs", file)
+				}
+			}
+
+			// a changed input must not rewrite files with unchanged content
+			path := filepath.Join(firstDir, "definition.yaml")
+			y, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not load definition.yaml file")
+			}
+			first.configContent = []byte("name: TestObject\n")
+			if err := first.Exec(&gConfig, sp, "", ""); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			y2, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not load definition.yaml file")
+			}
+			if string(y) != string(y2) {
+				t.Errorf("definition.yaml should not be rewritten")
+			}
+
+			// a changed content rewrites the file with the new input hash
+			first.Compositions[0].Name = "other"
+			if err := first.Exec(&gConfig, sp, "", ""); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			y, err = os.ReadFile(filepath.Join(firstDir, "composition-other.yaml"))
+			if err != nil {
+				t.Fatalf("could not load composition-other.yaml file")
+			}
+			header := fmt.Sprintf(autogenHeader, version.Version, first.inputHash(&gConfig))
+			if !strings.HasPrefix(string(y), header) {
+				t.Errorf("composition-other.yaml should start with %q", header)
+			}
+		})
+	}
+}
+
+func TestGenerator_LoadConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string][]byte{
+		"valid/generate.yaml":   []byte("name: TestObject\ngroup: example.cloud\n"),
+		"invalid/generate.yaml": []byte("name: [TestObject\n"),
+	})
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Should load a valid generate.yaml",
+			path: filepath.Join(tempDir, "valid", "generate.yaml"),
+			want: "TestObject",
+		},
+		{
+			name:    "Should fail for an invalid generate.yaml",
+			path:    filepath.Join(tempDir, "invalid", "generate.yaml"),
+			wantErr: true,
+		},
+		{
+			name:    "Should fail for a missing generate.yaml",
+			path:    filepath.Join(tempDir, "missing", "generate.yaml"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := (&Generator{}).LoadConfig(tt.path, true)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && g.Name != tt.want {
+				t.Errorf("LoadConfig() name = %s, want %s", g.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_ExecError(t *testing.T) {
+	tempDir := t.TempDir()
+	g := newTestGenerator(t, tempDir, false)
+	gConfig := xtype.GeneratorConfig{
+		CompositionIdentifier: "example.cloud",
+	}
+	cwd, _ := os.Getwd()
+	sp := filepath.Join(cwd, "functions")
+
+	err := g.Exec(&gConfig, sp, "missing.jsonnet", "")
+	if err == nil || !strings.Contains(err.Error(), "missing.jsonnet") {
+		t.Errorf("Exec() error = %v, want error for missing.jsonnet", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "definition.yaml")); err == nil {
+		t.Errorf("Exec() should not write files after an error")
+	}
+}
+
+func Test_generateAll(t *testing.T) {
+	paths := []string{"a", "b", "c", "d", "e", "f"}
+	tests := []struct {
+		name        string
+		concurrency int
+		keepGoing   bool
+		fail        string
+		wantOutput  string
+		wantSkipped int
+	}{
+		{
+			name:        "Should return the output in the order of the paths",
+			concurrency: 4,
+			wantOutput:  "abcdef",
+		},
+		{
+			name:        "Should skip the remaining paths after a failure",
+			concurrency: 1,
+			fail:        "c",
+			wantOutput:  "abc",
+			wantSkipped: 3,
+		},
+		{
+			name:        "Should continue after a failure",
+			concurrency: 3,
+			keepGoing:   true,
+			fail:        "c",
+			wantOutput:  "abcdef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := generateAll(paths, tt.concurrency, tt.keepGoing, func(path string, out io.Writer) (bool, error) {
+				// later paths finish first
+				time.Sleep(time.Duration(len(paths)-strings.Index("abcdef", path)) * time.Millisecond)
+				fmt.Fprint(out, path)
+				if path == tt.fail {
+					return false, errors.New("failed")
+				}
+				return true, nil
+			})
+			output := ""
+			skipped := 0
+			for i, r := range results {
+				<-r.done
+				if r.skipped {
+					skipped++
+					continue
+				}
+				output += r.output.String()
+				if (r.err != nil) != (paths[i] == tt.fail) {
+					t.Errorf("generateAll() error = %v for %s", r.err, paths[i])
+				}
+			}
+			if output != tt.wantOutput {
+				t.Errorf("generateAll() output = %s, want %s", output, tt.wantOutput)
+			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("generateAll() skipped = %d, want %d", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func Test_generateConcurrently(t *testing.T) {
+	tempDir := t.TempDir()
+	g := newTestGenerator(t, tempDir, false)
+	crd := g.crd
+	crd.APIVersion = "apiextensions.k8s.io/v1"
+	crd.Kind = "CustomResourceDefinition"
+	crdContent, err := json.Marshal(crd)
+	if err != nil {
+		t.Fatalf("could not marshal crd")
+	}
+	files := map[string][]byte{"crds/test.yaml": crdContent}
+	paths := []string{}
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("object%d", i)
+		files[name+"/generate.yaml"] = []byte(fmt.Sprintf(`group: example.cloud
+name: TestObject%d
+version: v1alpha1
+usePipeline: %t
+provider:
+  crdPath: ../crds/test.yaml
+  crd:
+    file: test.yaml
+    version: testv1
+labels:
+  common:
+    label%d: value
+  globalHandling:
+    common: append
+compositions:
+  - name: configuration
+    provider: sop
+    default: true
+`, i, i%2 == 0, i))
+		paths = append(paths, filepath.Join(tempDir, name, "generate.yaml"))
+	}
+	writeFiles(t, tempDir, files)
+
+	gConfig := xtype.GeneratorConfig{
+		CompositionIdentifier: "example.cloud",
+		Labels: xtype.LabelConfig{
+			Common: map[string]string{"global": "value"},
+		},
+	}
+	cwd, _ := os.Getwd()
+	args := arguments{scriptPath: filepath.Join(cwd, "functions")}
+	loader := &crdLoader{bundles: newBundleCache(), fetches: newFetchGroup()}
+
+	results := generateAll(paths, 4, false, func(path string, out io.Writer) (bool, error) {
+		return generate(path, &args, &gConfig, loader, nil, out)
+	})
+	reads := 0
+	for i, r := range results {
+		<-r.done
+		if r.err != nil {
+			t.Errorf("generate() error = %v for %s", r.err, paths[i])
+		}
+		if strings.Contains(r.output.String(), "Reading CRD file from") {
+			reads++
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(paths[i]), "definition.yaml")); err != nil {
+			t.Errorf("generate() should write definition.yaml for %s", paths[i])
+		}
+	}
+	if reads != 1 {
+		t.Errorf("crd should be read once, got %d reads", reads)
+	}
+	if !reflect.DeepEqual(gConfig.Labels.Common, map[string]string{"global": "value"}) {
+		t.Errorf("generate() should not change the global config, got %v", gConfig.Labels.Common)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Return the version of crossplane the definitions are generated for, the
+// generate.yaml overrides the global configuration
+func (g *Generator) crossplaneVersion(generatorConfig *t.GeneratorConfig) t.CrossplaneVersion {
+	if g.CrossplaneVersion != nil {
+		return *g.CrossplaneVersion
+	}
+	if generatorConfig.CrossplaneVersion != nil {
+		return *generatorConfig.CrossplaneVersion
+	}
+	return t.CrossplaneV1
+}
+
+// Check the settings only supported by composites with a claim
+func (g *Generator) checkCrossplaneVersion(generatorConfig *t.GeneratorConfig) error {
+	if g.crossplaneVersion(generatorConfig) != t.CrossplaneV2 {
+		return nil
+	}
+	if g.ConnectionSecretKeys != nil {
+		return errors.New("connectionSecretKeys are not supported by namespaced composites of crossplane v2")
+	}
+	if g.DefaultCompositeDeletePolicy != nil {
+		return errors.New("defaultCompositeDeletePolicy is not supported by namespaced composites of crossplane v2, there is no claim")
+	}
+	return nil
+}
+
+// Set the scope of the definition to Namespaced. The crossplane v1 api used to
+// generate definitions has no scope, so it is added to the rendered definition.
+func setNamespacedScope(definition []byte) ([]byte, error) {
+	xrd := map[string]interface{}{}
+	if err := yaml.Unmarshal(definition, &xrd); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal xrd")
+	}
+	spec, ok := xrd["spec"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("xrd has no spec")
+	}
+	spec["scope"] = "Namespaced"
+	return yaml.Marshal(xrd)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerator_RenderNamespaced(t *testing.T) {
+	v1, v2 := xtype.CrossplaneV1, xtype.CrossplaneV2
+	tests := []struct {
+		name           string
+		global         *xtype.CrossplaneVersion
+		generator      *xtype.CrossplaneVersion
+		wantAPIVersion string
+		wantName       string
+		wantKind       string
+		wantPlural     string
+		wantScope      string
+		wantClaim      bool
+		// field path the external name is patched from
+		wantNameFrom string
+	}{
+		{
+			name:           "Should generate a claim by default",
+			wantAPIVersion: "apiextensions.crossplane.io/v1",
+			wantName:       "compositetestobjects.example.cloud",
+			wantKind:       "CompositeTestObject",
+			wantPlural:     "compositetestobjects",
+			wantClaim:      true,
+			wantNameFrom:   "metadata.labels[crossplane.io/claim-name]",
+		},
+		{
+			name:           "Should generate a namespaced composite from the global configuration",
+			global:         &v2,
+			wantAPIVersion: "apiextensions.crossplane.io/v2",
+			wantName:       "testobjects.example.cloud",
+			wantKind:       "TestObject",
+			wantPlural:     "testobjects",
+			wantScope:      "Namespaced",
+			wantNameFrom:   "metadata.name",
+		},
+		{
+			name:           "Should prefer the generator over the global configuration",
+			global:         &v2,
+			generator:      &v1,
+			wantAPIVersion: "apiextensions.crossplane.io/v1",
+			wantName:       "compositetestobjects.example.cloud",
+			wantKind:       "CompositeTestObject",
+			wantPlural:     "compositetestobjects",
+			wantClaim:      true,
+			wantNameFrom:   "metadata.labels[crossplane.io/claim-name]",
+		},
+	}
+	for _, tt := range tests {
+		for _, usePipeline := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (usePipeline %v)", tt.name, usePipeline), func(t *testing.T) {
+				g := newTestGenerator(t, t.TempDir(), usePipeline)
+				g.CrossplaneVersion = tt.generator
+				g.OverrideFieldsInClaim = []xtype.OverrideFieldInClaim{}
+				gConfig := xtype.GeneratorConfig{
+					CompositionIdentifier: "example.cloud",
+					CrossplaneVersion:     tt.global,
+				}
+				if err := g.CheckConfig(&gConfig); err != nil {
+					t.Fatalf("CheckConfig() error = %v", err)
+				}
+				cwd, _ := os.Getwd()
+				files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+				if err != nil {
+					t.Fatalf("Render() usePipeline %v error = %v", usePipeline, err)
+				}
+				// the crossplane v1 api types have no scope, so the definition
+				// is read unstructured
+				var xrd *unstructured.Unstructured
+				var composition *crossplanev1.Composition
+				for _, f := range files {
+					switch name := filepath.Base(f.path); {
+					case name == "definition.yaml":
+						xrd = unstructuredFromYAML(t, string(f.content))
+					case strings.HasPrefix(name, "composition"):
+						composition = &crossplanev1.Composition{}
+						if err := yaml.Unmarshal(f.content, composition); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+				if xrd == nil || composition == nil {
+					t.Fatalf("Render() usePipeline %v should generate a definition and a composition", usePipeline)
+				}
+
+				if xrd.GetAPIVersion() != tt.wantAPIVersion || xrd.GetName() != tt.wantName {
+					t.Errorf("Render() usePipeline %v definition = %s %s, want %s %s", usePipeline, xrd.GetAPIVersion(), xrd.GetName(), tt.wantAPIVersion, tt.wantName)
+				}
+				kind, _, _ := unstructured.NestedString(xrd.Object, "spec", "names", "kind")
+				plural, _, _ := unstructured.NestedString(xrd.Object, "spec", "names", "plural")
+				if kind != tt.wantKind || plural != tt.wantPlural {
+					t.Errorf("Render() usePipeline %v names = %s %s, want %s %s", usePipeline, kind, plural, tt.wantKind, tt.wantPlural)
+				}
+				scope, _, _ := unstructured.NestedString(xrd.Object, "spec", "scope")
+				if scope != tt.wantScope {
+					t.Errorf("Render() usePipeline %v scope = %q, want %q", usePipeline, scope, tt.wantScope)
+				}
+				_, hasClaim, _ := unstructured.NestedMap(xrd.Object, "spec", "claimNames")
+				if hasClaim != tt.wantClaim {
+					t.Errorf("Render() usePipeline %v claimNames = %v, want %v", usePipeline, hasClaim, tt.wantClaim)
+				}
+
+				if composition.Spec.CompositeTypeRef.Kind != tt.wantKind {
+					t.Errorf("Render() usePipeline %v compositeTypeRef kind = %s, want %s", usePipeline, composition.Spec.CompositeTypeRef.Kind, tt.wantKind)
+				}
+				nameFrom := []string{}
+				for _, patch := range resourcePatches(t, patchAndTransformInput(t, composition), 0) {
+					if samePath(patch.ToFieldPath, "metadata.annotations[crossplane.io/external-name]") && patch.FromFieldPath != nil {
+						nameFrom = append(nameFrom, *patch.FromFieldPath)
+					}
+				}
+				// by the Name patch set, then by the External-Name patch set
+				if want := []string{tt.wantNameFrom, "metadata.annotations[crossplane.io/external-name]"}; !reflect.DeepEqual(nameFrom, want) {
+					t.Errorf("Render() usePipeline %v external name patched from %v, want %v", usePipeline, nameFrom, want)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerator_CheckCrossplaneVersion(t *testing.T) {
+	v2 := xtype.CrossplaneV2
+	g := newTestGenerator(t, t.TempDir(), true)
+	g.CrossplaneVersion = &v2
+	g.ConnectionSecretKeys = &[]string{"password"}
+	err := g.CheckConfig(&xtype.GeneratorConfig{})
+	if err == nil || !strings.Contains(err.Error(), "connectionSecretKeys") {
+		t.Errorf("CheckConfig() error = %v, want connectionSecretKeys not supported", err)
+	}
+}
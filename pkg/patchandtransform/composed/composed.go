@@ -0,0 +1,11 @@
+// Package composed replaces the composed resource of function-sdk-go for the copied
+// patches of function-patch-and-transform, which only use it as
+// runtime.Object.
+package composed
+
+import "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+// Unstructured is a composed resource.
+type Unstructured struct {
+	unstructured.Unstructured
+}
@@ -0,0 +1,11 @@
+// Package composite replaces the composite resource of function-sdk-go for the copied
+// patches of function-patch-and-transform, which only use it as
+// runtime.Object.
+package composite
+
+import "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+// Unstructured is a composite resource.
+type Unstructured struct {
+	unstructured.Unstructured
+}
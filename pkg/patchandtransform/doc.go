@@ -0,0 +1,7 @@
+// Package patchandtransform contains the patches, transforms and validation
+// of function-patch-and-transform, copied from the version in go.mod by
+// `make copy-patch-and-transform`, because the function is a main package
+// and can't be imported. The resources of function-sdk-go are replaced by the
+// composed and composite packages, the copied files are not modified
+// otherwise. The render command runs them like RunFunction of the function.
+package patchandtransform
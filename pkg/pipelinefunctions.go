@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Return the names of the functions referenced by the pipeline steps of the
+// rendered compositions
+func pipelineFunctions(files []generatedFile) ([]string, error) {
+	functions := []string{}
+	for _, f := range files {
+		if !strings.HasPrefix(filepath.Base(f.path), "composition") {
+			continue
+		}
+		var composition crossplanev1.Composition
+		if err := yaml.Unmarshal(f.content, &composition); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal %s", f.path)
+		}
+		for _, step := range composition.Spec.Pipeline {
+			if !listHas(&functions, step.FunctionRef.Name) {
+				functions = append(functions, step.FunctionRef.Name)
+			}
+		}
+	}
+	return functions, nil
+}
+
+// Check that the functions referenced by the rendered compositions are
+// registered in functions of the global configuration, functions are not
+// checked without a registry
+func checkFunctions(generatorConfig *t.GeneratorConfig, files []generatedFile) error {
+	if generatorConfig.Functions == nil {
+		return nil
+	}
+	functions, err := pipelineFunctions(files)
+	if err != nil {
+		return err
+	}
+	missing := []string{}
+	for _, name := range functions {
+		if _, ok := generatorConfig.Functions[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("functions %s are not registered in functions of the global configuration", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Write the Function manifests of the functions used by the generated
+// compositions to functionsPath, e.g. to install them in a development
+// cluster or to render the compositions with crossplane render. In check mode
+// a diff is written to out instead, the result is false if the file is out of
+// date.
+func writeFunctions(generatorConfig *t.GeneratorConfig, dependencies *packageDependencies, check bool, out io.Writer) (bool, error) {
+	content := []byte{}
+	for _, name := range sortedKeys(dependencies.functions) {
+		f, ok := generatorConfig.Functions[name]
+		if !ok {
+			return false, errors.Errorf("function %s is not registered in functions of the global configuration", name)
+		}
+		metadata := map[string]interface{}{"name": name}
+		if len(f.Annotations) > 0 {
+			metadata["annotations"] = f.Annotations
+		}
+		manifest, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "pkg.crossplane.io/v1beta1",
+			"kind":       "Function",
+			"metadata":   metadata,
+			"spec": map[string]interface{}{
+				"package": f.Package,
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		content = append(append(content, "---\n"...), manifest...)
+	}
+	return updateFile(*generatorConfig.FunctionsPath, content, check, out)
+}
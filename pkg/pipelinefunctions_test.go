@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+)
+
+func Test_checkFunctions(t *testing.T) {
+	files := []generatedFile{{path: "bucket/composition-example.yaml", content: pipelineComposition}}
+	if err := checkFunctions(&xtype.GeneratorConfig{}, files); err != nil {
+		t.Errorf("checkFunctions() without registry error = %v", err)
+	}
+	generatorConfig := &xtype.GeneratorConfig{Functions: map[string]xtype.Function{
+		"function-patch-and-transform": {Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0"},
+	}}
+	err := checkFunctions(generatorConfig, files)
+	if err == nil || err.Error() != "functions function-auto-ready are not registered in functions of the global configuration" {
+		t.Errorf("checkFunctions() error = %v, want function-auto-ready not registered", err)
+	}
+}
+
+func Test_writeFunctions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "functions.yaml")
+	generatorConfig := &xtype.GeneratorConfig{
+		FunctionsPath: &path,
+		Functions: map[string]xtype.Function{
+			"function-patch-and-transform": {
+				Package:     "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0",
+				Annotations: map[string]string{"render.crossplane.io/runtime": "Development"},
+			},
+			"function-auto-ready": {Package: "xpkg.upbound.io/crossplane-contrib/function-auto-ready:v0.2.1"},
+			"function-unused":     {Package: "xpkg.upbound.io/example/function-unused:v1.0.0"},
+		},
+	}
+	dependencies := newPackageDependencies()
+	dependencies.functions["function-patch-and-transform"] = true
+	dependencies.functions["function-auto-ready"] = true
+
+	var out bytes.Buffer
+	if upToDate, err := writeFunctions(generatorConfig, dependencies, true, &out); err != nil || upToDate {
+		t.Fatalf("writeFunctions() check = %v, %v, want out of date", upToDate, err)
+	}
+	if _, err := writeFunctions(generatorConfig, dependencies, false, &out); err != nil {
+		t.Fatalf("writeFunctions() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `---
+apiVersion: pkg.crossplane.io/v1beta1
+kind: Function
+metadata:
+  name: function-auto-ready
+spec:
+  package: xpkg.upbound.io/crossplane-contrib/function-auto-ready:v0.2.1
+---
+apiVersion: pkg.crossplane.io/v1beta1
+kind: Function
+metadata:
+  annotations:
+    render.crossplane.io/runtime: Development
+  name: function-patch-and-transform
+spec:
+  package: xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0
+`
+	if string(content) != want {
+		t.Errorf("writeFunctions() wrote %s, want %s", content, want)
+	}
+	if upToDate, err := writeFunctions(generatorConfig, dependencies, true, &out); err != nil || !upToDate {
+		t.Errorf("writeFunctions() check after writing = %v, %v, want up to date", upToDate, err)
+	}
+
+	// a changed second document must be detected
+	generatorConfig.Functions["function-patch-and-transform"] = xtype.Function{Package: "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.4.0"}
+	out.Reset()
+	if upToDate, err := writeFunctions(generatorConfig, dependencies, true, &out); err != nil || upToDate || !strings.Contains(out.String(), "+  package: xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.4.0") {
+		t.Errorf("writeFunctions() check after change = %v, %v, %s", upToDate, err, out.String())
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	pt "github.com/crossplane-contrib/x-generation/pkg/patchandtransform"
+	"github.com/crossplane-contrib/x-generation/pkg/patchandtransform/composed"
+	"github.com/crossplane-contrib/x-generation/pkg/patchandtransform/composite"
+	xpfieldpath "github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	labelClaimName           = "crossplane.io/claim-name"
+	labelClaimNamespace      = "crossplane.io/claim-namespace"
+	labelComposite           = "crossplane.io/composite"
+	annotationResourceName   = "crossplane.io/composition-resource-name"
+	annotationLastApplied    = "kubectl.kubernetes.io/last-applied-configuration"
+	patchAndTransformVersion = "pt.fn.crossplane.io/v1beta1"
+	// suffix of the name of composites rendered from a claim, crossplane
+	// generates a random suffix instead
+	renderedCompositeSuffix = "-xxxxx"
+)
+
+// errNoPatchAndTransform is returned for compositions rendering their
+// resources with another function, e.g. the go-templating backend
+var errNoPatchAndTransform = errors.New("the composition has no patch-and-transform step")
+
+// Run the render subcommand: render the composed resources of a composition
+// for a claim or a composite like function-patch-and-transform does. The
+// function can't be imported, it is a main package, so its patches and
+// transforms are copied to the patchandtransform package.
+func runRender(argv []string, out io.Writer) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(out)
+	observedPath := fs.String("observed", "", "file with the observed composed resources, used by patches to the composite")
+	environmentPath := fs.String("environment", "", "file with the composition environment, used by patches from the environment")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: x-generation render [flags] composition.yaml claim.yaml\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(argv); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("a composition and a claim or composite must be given")
+	}
+
+	content, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var composition crossplanev1.Composition
+	if err := yaml.Unmarshal(content, &composition); err != nil {
+		return errors.Wrapf(err, "cannot parse composition %s", fs.Arg(0))
+	}
+	claims, err := readObjects(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(claims) != 1 {
+		return errors.Errorf("%s must contain exactly one claim or composite", fs.Arg(1))
+	}
+	observed := []*unstructured.Unstructured{}
+	if *observedPath != "" {
+		if observed, err = readObjects(*observedPath); err != nil {
+			return err
+		}
+	}
+
+	environment := map[string]interface{}{}
+	if *environmentPath != "" {
+		content, err := os.ReadFile(*environmentPath)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(content, &environment); err != nil {
+			return errors.Wrapf(err, "cannot parse environment %s", *environmentPath)
+		}
+	}
+
+	result, err := renderComposition(&composition, claims[0], observed, environment)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.warnings {
+		fmt.Fprintf(out, "# warning: %s\n", w)
+	}
+	for _, o := range append([]*unstructured.Unstructured{result.composite}, result.resources...) {
+		content, err := yaml.Marshal(o.Object)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "---\n%s", content)
+	}
+	return nil
+}
+
+// Read the objects of a multi document yaml file
+func readObjects(path string) ([]*unstructured.Unstructured, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	objects := []*unstructured.Unstructured{}
+	for _, doc := range splitYAMLDocuments(content) {
+		o := map[string]interface{}{}
+		if err := yaml.Unmarshal(doc, &o); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s", path)
+		}
+		if len(o) > 0 {
+			objects = append(objects, &unstructured.Unstructured{Object: o})
+		}
+	}
+	return objects, nil
+}
+
+// renderResult is the desired state rendered from a composite
+type renderResult struct {
+	composite *unstructured.Unstructured
+	resources []*unstructured.Unstructured
+	warnings  []string
+}
+
+// renderer runs the patch-and-transform steps like RunFunction of
+// function-patch-and-transform, using the patches and transforms copied from
+// it
+type renderer struct {
+	// the observed composite, patches from the composite read from it
+	observedComposite *composite.Unstructured
+	composite         *composite.Unstructured
+	environment       *unstructured.Unstructured
+	observed          map[string]*composed.Unstructured
+	names             []string
+	desired           map[string]*composed.Unstructured
+	warnings          []string
+}
+
+// Render the composed resources of the composition for a claim or a
+// composite. The observed composed resources are matched by the annotation
+// crossplane.io/composition-resource-name.
+func renderComposition(composition *crossplanev1.Composition, claim *unstructured.Unstructured, observed []*unstructured.Unstructured, environment map[string]interface{}) (*renderResult, error) {
+	inputs, err := patchAndTransformInputs(composition)
+	if err != nil {
+		return nil, err
+	}
+	xr := compositeOf(claim, composition.Spec.CompositeTypeRef)
+	r := &renderer{
+		observedComposite: &composite.Unstructured{Unstructured: *xr},
+		composite:         &composite.Unstructured{Unstructured: *xr.DeepCopy()},
+		environment:       &unstructured.Unstructured{Object: environment},
+		observed:          map[string]*composed.Unstructured{},
+		desired:           map[string]*composed.Unstructured{},
+	}
+	for _, o := range observed {
+		if name := o.GetAnnotations()[annotationResourceName]; name != "" {
+			r.observed[name] = &composed.Unstructured{Unstructured: *o}
+		}
+	}
+	for _, input := range inputs {
+		if err := r.render(input); err != nil {
+			return nil, err
+		}
+	}
+	result := &renderResult{composite: &r.composite.Unstructured, resources: []*unstructured.Unstructured{}, warnings: r.warnings}
+	for _, name := range r.names {
+		result.resources = append(result.resources, &r.desired[name].Unstructured)
+	}
+	return result, nil
+}
+
+// Return the inputs of the patch-and-transform steps of a composition in
+// pipeline mode, or the resources and patch sets of a composition in resources
+// mode, they use the same format
+func patchAndTransformInputs(composition *crossplanev1.Composition) ([]p.Resources, error) {
+	if composition.Spec.Mode == nil || *composition.Spec.Mode != crossplanev1.CompositionModePipeline {
+		raw, err := json.Marshal(map[string]interface{}{
+			"patchSets": composition.Spec.PatchSets,
+			"resources": composition.Spec.Resources,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var input p.Resources
+		if err := json.Unmarshal(raw, &input); err != nil {
+			return nil, errors.Wrap(err, "cannot convert the resources of the composition")
+		}
+		for i := range input.Resources {
+			if input.Resources[i].Name == "" {
+				input.Resources[i].Name = fmt.Sprintf("resource-%d", i)
+			}
+		}
+		return []p.Resources{input}, nil
+	}
+
+	inputs := []p.Resources{}
+	for _, step := range composition.Spec.Pipeline {
+		if step.Input == nil {
+			continue
+		}
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(step.Input.Raw, &typeMeta); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the input of step %s", step.Step)
+		}
+		if typeMeta.APIVersion != patchAndTransformVersion || typeMeta.Kind != "Resources" {
+			continue
+		}
+		var input p.Resources
+		if err := json.Unmarshal(step.Input.Raw, &input); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the input of step %s", step.Step)
+		}
+		inputs = append(inputs, input)
+	}
+	if len(inputs) == 0 {
+		return nil, errNoPatchAndTransform
+	}
+	return inputs, nil
+}
+
+// Return the composite crossplane creates for a claim, a composite is
+// returned as is
+func compositeOf(claim *unstructured.Unstructured, ref crossplanev1.TypeReference) *unstructured.Unstructured {
+	if claim.GetKind() == ref.Kind {
+		composite := claim.DeepCopy()
+		labels := composite.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[labelComposite] = composite.GetName()
+		composite.SetLabels(labels)
+		return composite
+	}
+
+	composite := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	composite.SetAPIVersion(ref.APIVersion)
+	composite.SetKind(ref.Kind)
+	name := claim.GetName() + renderedCompositeSuffix
+	composite.SetName(name)
+	labels := map[string]string{}
+	for k, v := range claim.GetLabels() {
+		labels[k] = v
+	}
+	labels[labelClaimName] = claim.GetName()
+	labels[labelClaimNamespace] = claim.GetNamespace()
+	labels[labelComposite] = name
+	composite.SetLabels(labels)
+	annotations := map[string]string{}
+	for k, v := range claim.GetAnnotations() {
+		if k != annotationLastApplied {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) > 0 {
+		composite.SetAnnotations(annotations)
+	}
+
+	spec, _, _ := unstructured.NestedMap(claim.Object, "spec")
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	delete(spec, "resourceRef")
+	delete(spec, "writeConnectionSecretToRef")
+	spec["claimRef"] = map[string]interface{}{
+		"apiVersion": claim.GetAPIVersion(),
+		"kind":       claim.GetKind(),
+		"name":       claim.GetName(),
+		"namespace":  claim.GetNamespace(),
+	}
+	composite.Object["spec"] = spec
+	return composite
+}
+
+// Render the composed templates of a patch-and-transform input
+func (r *renderer) render(input p.Resources) error {
+	if err := pt.ValidateResources(&input); err != nil {
+		return errors.Wrap(err, "invalid patch-and-transform input")
+	}
+	templates, err := pt.ComposedTemplates(input.PatchSets, input.Resources)
+	if err != nil {
+		return errors.Wrap(err, "cannot resolve patch sets")
+	}
+	if input.Environment != nil {
+		for i := range input.Environment.Patches {
+			patch := &input.Environment.Patches[i]
+			if err := pt.ApplyEnvironmentPatch(patch, r.environment, r.observedComposite, r.composite); err != nil {
+				return errors.Wrapf(err, "cannot apply the %q environment patch at index %d", patch.GetType(), i)
+			}
+		}
+	}
+	for _, template := range templates {
+		desired := &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{}}}
+		switch {
+		case template.Base != nil:
+			if err := json.Unmarshal(template.Base.Raw, &desired.Object); err != nil {
+				return errors.Wrapf(err, "cannot parse base template of composed resource %q", template.Name)
+			}
+		case r.desired[template.Name] != nil:
+			desired = &composed.Unstructured{Unstructured: *r.desired[template.Name].DeepCopy()}
+		default:
+			return errors.Errorf("composed resource %q has no base template, and was not produced by a previous step", template.Name)
+		}
+		r.setMetadata(&desired.Unstructured, template.Name)
+
+		observed, exists := r.observed[template.Name]
+		skip := false
+		for i := range template.Patches {
+			patch := &template.Patches[i]
+			err := pt.ApplyComposedPatch(patch, observed, desired, r.observedComposite, r.composite, r.environment)
+			if err == nil {
+				continue
+			}
+			if !xpfieldpath.IsNotFound(err) {
+				return errors.Wrapf(err, "cannot render composed resource %q %q patch at index %d", template.Name, patch.GetType(), i)
+			}
+			if patch.GetPolicy().GetFromFieldPathPolicy() != p.FromFieldPathPolicyRequired {
+				continue
+			}
+			// a required field path that does not exist blocks the
+			// creation of the composed resource
+			if pt.ToComposedResource(patch) && !exists {
+				r.warnings = append(r.warnings, fmt.Sprintf("not rendering composed resource %q because %q patch at index %d has 'policy.fromFieldPath: Required': %s", template.Name, patch.GetType(), i, err))
+				skip = true
+				break
+			}
+			r.warnings = append(r.warnings, fmt.Sprintf("ignoring 'policy.fromFieldPath: Required' of %q patch at index %d of composed resource %q because it already exists: %s", patch.GetType(), i, template.Name, err))
+		}
+		if skip {
+			continue
+		}
+		if _, ok := r.desired[template.Name]; !ok {
+			r.names = append(r.names, template.Name)
+		}
+		r.desired[template.Name] = desired
+	}
+	return nil
+}
+
+// Set the metadata crossplane sets on composed resources
+func (r *renderer) setMetadata(desired *unstructured.Unstructured, name string) {
+	annotations := desired.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotationResourceName] = name
+	desired.SetAnnotations(annotations)
+	labels := desired.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for _, l := range []string{labelComposite, labelClaimName, labelClaimNamespace} {
+		if v, ok := r.composite.GetLabels()[l]; ok {
+			labels[l] = v
+		}
+	}
+	desired.SetLabels(labels)
+	if observed, ok := r.observed[name]; ok {
+		desired.SetName(observed.GetName())
+		desired.SetNamespace(observed.GetNamespace())
+	} else if desired.GetName() == "" {
+		desired.SetGenerateName(r.composite.GetName() + "-")
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	xpfieldpath "github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	crossplanev1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func unstructuredFromYAML(t *testing.T, content string) *unstructured.Unstructured {
+	t.Helper()
+	o := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(content), &o); err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: o}
+}
+
+// Render the generator and return the generated definition and compositions
+func renderTestGenerator(t *testing.T, g *Generator, gConfig *xtype.GeneratorConfig) (*crossplanev1.CompositeResourceDefinition, []crossplanev1.Composition) {
+	t.Helper()
+	cwd, _ := os.Getwd()
+	files, err := g.Render(gConfig, filepath.Join(cwd, "functions"), "", "")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	var xrd *crossplanev1.CompositeResourceDefinition
+	compositions := []crossplanev1.Composition{}
+	for _, f := range files {
+		name := filepath.Base(f.path)
+		switch {
+		case name == "definition.yaml":
+			xrd = &crossplanev1.CompositeResourceDefinition{}
+			if err := yaml.Unmarshal(f.content, xrd); err != nil {
+				t.Fatalf("cannot unmarshal %s: %v", f.path, err)
+			}
+		case strings.HasPrefix(name, "composition"):
+			var composition crossplanev1.Composition
+			if err := yaml.Unmarshal(f.content, &composition); err != nil {
+				t.Fatalf("cannot unmarshal %s: %v", f.path, err)
+			}
+			compositions = append(compositions, composition)
+		}
+	}
+	if xrd == nil {
+		t.Fatal("Render() generated no definition")
+	}
+	return xrd, compositions
+}
+
+// Return the patch-and-transform input of the composition, in pipeline mode
+// and without pipeline
+func patchAndTransformInput(t *testing.T, composition *crossplanev1.Composition) p.Resources {
+	t.Helper()
+	inputs, err := patchAndTransformInputs(composition)
+	if err != nil || len(inputs) != 1 {
+		t.Fatalf("patchAndTransformInputs() = %d inputs, error = %v, want one input", len(inputs), err)
+	}
+	return inputs[0]
+}
+
+// Return the base of the i-th resource of the input
+func resourceBase(t *testing.T, input p.Resources, i int) *unstructured.Unstructured {
+	t.Helper()
+	if i >= len(input.Resources) || input.Resources[i].Base == nil {
+		t.Fatalf("no resource %d with a base in the composition", i)
+	}
+	base := map[string]interface{}{}
+	if err := json.Unmarshal(input.Resources[i].Base.Raw, &base); err != nil {
+		t.Fatalf("cannot unmarshal the base of resource %d: %v", i, err)
+	}
+	return &unstructured.Unstructured{Object: base}
+}
+
+// Return the patches applied to the i-th resource of the input, including the
+// patches of the patch sets it uses
+func resourcePatches(t *testing.T, input p.Resources, i int) []p.Patch {
+	t.Helper()
+	if i >= len(input.Resources) {
+		t.Fatalf("no resource %d in the composition", i)
+	}
+	patches := []p.Patch{}
+	for _, patch := range input.Resources[i].Patches {
+		if patch.GetType() != p.PatchTypePatchSet {
+			patches = append(patches, patch.Patch)
+			continue
+		}
+		for _, set := range input.PatchSets {
+			if set.Name != patch.GetPatchSetName() {
+				continue
+			}
+			for _, setPatch := range set.Patches {
+				patches = append(patches, setPatch.Patch)
+			}
+		}
+	}
+	return patches
+}
+
+// Return the patch from the field path from to the field path to, an empty
+// path matches any path. Paths are compared after parsing, so quoted and
+// unquoted keys are equal.
+func findPatch(patches []p.Patch, from, to string) *p.Patch {
+	for i, patch := range patches {
+		if (from == "" || samePath(patch.FromFieldPath, from)) && (to == "" || samePath(patch.ToFieldPath, to)) {
+			return &patches[i]
+		}
+	}
+	return nil
+}
+
+func samePath(path *string, want string) bool {
+	if path == nil {
+		return false
+	}
+	got, err := xpfieldpath.Parse(*path)
+	if err != nil {
+		return false
+	}
+	parsed, err := xpfieldpath.Parse(want)
+	return err == nil && got.String() == parsed.String()
+}
+
+func TestRenderComposition_Generated(t *testing.T) {
+	for _, usePipeline := range []bool{false, true} {
+		g := newTestGenerator(t, t.TempDir(), usePipeline)
+		g.crd.Spec.Names.Kind = "Bucket"
+		crdSource, err := json.Marshal(g.crd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.crdSource = string(crdSource)
+		gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+		if err := g.CheckConfig(&gConfig); err != nil {
+			t.Fatalf("CheckConfig() error = %v", err)
+		}
+		cwd, _ := os.Getwd()
+		files, err := g.Render(&gConfig, filepath.Join(cwd, "functions"), "", "")
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		var composition crossplanev1.Composition
+		for _, f := range files {
+			if strings.HasPrefix(filepath.Base(f.path), "composition") {
+				if err := yaml.Unmarshal(f.content, &composition); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		claim := unstructuredFromYAML(t, `
+apiVersion: example.cloud/testv1
+kind: Bucket
+metadata:
+  name: example
+  namespace: team
+spec:
+  forProvider:
+    region: eu-central-1
+`)
+		result, err := renderComposition(&composition, claim, nil, map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("renderComposition() usePipeline %v error = %v", usePipeline, err)
+		}
+		if len(result.resources) != 1 {
+			t.Fatalf("renderComposition() usePipeline %v resources = %v, want 1", usePipeline, result.resources)
+		}
+		resource := result.resources[0]
+		region, _, _ := unstructured.NestedString(resource.Object, "spec", "forProvider", "region")
+		if region != "eu-central-1" {
+			t.Errorf("renderComposition() usePipeline %v region = %q, want eu-central-1", usePipeline, region)
+		}
+		if got := resource.GetLabels()[labelClaimName]; got != "example" {
+			t.Errorf("renderComposition() usePipeline %v claim name label = %q, want example", usePipeline, got)
+		}
+		if got := result.composite.GetKind(); got != composition.Spec.CompositeTypeRef.Kind {
+			t.Errorf("renderComposition() usePipeline %v composite kind = %q, want %q", usePipeline, got, composition.Spec.CompositeTypeRef.Kind)
+		}
+	}
+}
+
+func TestRenderComposition_Observed(t *testing.T) {
+	var composition crossplanev1.Composition
+	if err := yaml.Unmarshal([]byte(`
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  compositeTypeRef:
+    apiVersion: example.cloud/v1alpha1
+    kind: XBucket
+  mode: Pipeline
+  pipeline:
+  - step: patch-and-transform
+    functionRef:
+      name: function-patch-and-transform
+    input:
+      apiVersion: pt.fn.crossplane.io/v1beta1
+      kind: Resources
+      resources:
+      - name: key
+        base:
+          apiVersion: kms.aws.upbound.io/v1beta1
+          kind: Key
+        patches:
+        - type: ToCompositeFieldPath
+          fromFieldPath: status.atProvider.arn
+          toFieldPath: status.key.arn
+      - name: bucket
+        base:
+          apiVersion: s3.aws.upbound.io/v1beta1
+          kind: Bucket
+        patches:
+        - type: FromCompositeFieldPath
+          fromFieldPath: status.key.arn
+          toFieldPath: spec.forProvider.kmsKeyArn
+          policy:
+            fromFieldPath: Required
+`), &composition); err != nil {
+		t.Fatal(err)
+	}
+	composite := unstructuredFromYAML(t, `
+apiVersion: example.cloud/v1alpha1
+kind: XBucket
+metadata:
+  name: example
+`)
+	observedKey := unstructuredFromYAML(t, `
+apiVersion: kms.aws.upbound.io/v1beta1
+kind: Key
+metadata:
+  name: example-key
+  annotations:
+    crossplane.io/composition-resource-name: key
+status:
+  atProvider:
+    arn: arn:aws:kms:key
+`)
+
+	result, err := renderComposition(&composition, composite, nil, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("renderComposition() error = %v", err)
+	}
+	if len(result.resources) != 1 || len(result.warnings) != 1 || !strings.Contains(result.warnings[0], `not rendering composed resource "bucket"`) {
+		t.Errorf("renderComposition() should skip the bucket, got %v, warnings %v", result.resources, result.warnings)
+	}
+
+	result, err = renderComposition(&composition, composite, []*unstructured.Unstructured{observedKey}, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("renderComposition() error = %v", err)
+	}
+	arn, _, _ := unstructured.NestedString(result.composite.Object, "status", "key", "arn")
+	if arn != "arn:aws:kms:key" {
+		t.Errorf("renderComposition() composite arn = %q, want arn:aws:kms:key", arn)
+	}
+	// the composite is patched for the next reconciliation, the bucket is
+	// rendered from the observed composite
+	if len(result.resources) != 1 || result.resources[0].GetName() != "example-key" {
+		t.Errorf("renderComposition() resources = %v, want the observed key", result.resources)
+	}
+
+	result, err = renderComposition(&composition, result.composite, []*unstructured.Unstructured{observedKey}, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("renderComposition() error = %v", err)
+	}
+	if len(result.resources) != 2 {
+		t.Fatalf("renderComposition() resources = %v, want the key and the bucket", result.resources)
+	}
+	kmsKeyArn, _, _ := unstructured.NestedString(result.resources[1].Object, "spec", "forProvider", "kmsKeyArn")
+	if kmsKeyArn != "arn:aws:kms:key" {
+		t.Errorf("renderComposition() kmsKeyArn = %q, want arn:aws:kms:key", kmsKeyArn)
+	}
+}
+
+func TestRenderComposition_Transforms(t *testing.T) {
+	var composition crossplanev1.Composition
+	if err := yaml.Unmarshal([]byte(`
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: example
+spec:
+  compositeTypeRef:
+    apiVersion: example.cloud/v1alpha1
+    kind: XDatabase
+  mode: Pipeline
+  pipeline:
+  - step: patch-and-transform
+    functionRef:
+      name: function-patch-and-transform
+    input:
+      apiVersion: pt.fn.crossplane.io/v1beta1
+      kind: Resources
+      resources:
+      - name: instance
+        base:
+          apiVersion: rds.aws.upbound.io/v1beta1
+          kind: Instance
+        patches:
+        - fromFieldPath: spec.storage
+          toFieldPath: spec.forProvider.allocatedStorage
+          transforms:
+          - type: convert
+            convert:
+              toType: float64
+              format: quantity
+          - type: math
+            math:
+              type: ClampMax
+              clampMax: 100
+        - fromFieldPath: spec.engine
+          toFieldPath: spec.forProvider.engineVersion
+          transforms:
+          - type: match
+            match:
+              patterns:
+              - type: regexp
+                regexp: ^postgres
+                result: "16.1"
+              fallbackValue: "8.0"
+`), &composition); err != nil {
+		t.Fatal(err)
+	}
+	composite := unstructuredFromYAML(t, `
+apiVersion: example.cloud/v1alpha1
+kind: XDatabase
+metadata:
+  name: example
+spec:
+  storage: 1Ki
+  engine: postgresql
+`)
+	result, err := renderComposition(&composition, composite, nil, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("renderComposition() error = %v", err)
+	}
+	forProvider, _, _ := unstructured.NestedMap(result.resources[0].Object, "spec", "forProvider")
+	if forProvider["allocatedStorage"] != int64(100) || forProvider["engineVersion"] != "16.1" {
+		t.Errorf("renderComposition() forProvider = %v, want clamped storage and the matched version", forProvider)
+	}
+}
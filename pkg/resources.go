@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/crossplane-contrib/x-generation/pkg/generator"
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// loadedResource is a resource of the generate.yaml with its crd
+type loadedResource struct {
+	crd       extv1.CustomResourceDefinition
+	crdSource string
+	tags      tagDetection
+}
+
+// Return the provider of the resource, the provider settings of the generator
+// are used if the resource does not configure its own provider
+func (g *Generator) resourceProvider(r t.Resource) t.ProviderConfig {
+	provider := r.Provider
+	if provider.Name == "" {
+		provider.Name = g.Provider.Name
+		provider.Version = g.Provider.Version
+	}
+	if provider.BaseURL == nil && provider.CRDPath == nil && provider.Package == nil {
+		provider.BaseURL = g.Provider.BaseURL
+		provider.CRDPath = g.Provider.CRDPath
+		provider.Package = g.Provider.Package
+	}
+	if provider.IndexURL == nil {
+		provider.IndexURL = g.Provider.IndexURL
+	}
+	return provider
+}
+
+// Load the crds of the resources of the generator
+func (g *Generator) loadResources(generatorConfig *t.GeneratorConfig, loader *crdLoader) error {
+	g.resources = []loadedResource{}
+	for _, r := range g.Resources {
+		provider := g.resourceProvider(r)
+		crd, source, sum, err := g.loadProviderCRD(generatorConfig, provider, loader)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load crd of resource %s", r.Name)
+		}
+		g.resources = append(g.resources, loadedResource{
+			crd:       crd,
+			crdSource: source,
+			tags:      detectTags(crd, provider.CRD.Version, newTagDetectors(generatorConfig.TagDetectors)),
+		})
+		g.crdChecksum += "," + sum
+	}
+	return nil
+}
+
+// Check the resources and references of the generator
+func (g *Generator) checkResources(generatorConfig *t.GeneratorConfig) error {
+	if len(g.Resources) == 0 && len(g.References) == 0 {
+		return nil
+	}
+	if !g.usePipeline(generatorConfig) {
+		return errors.New("resources and references are only supported in pipeline mode, set usePipeline to true")
+	}
+	names := []string{g.resourceName()}
+	for _, r := range g.Resources {
+		if r.Name == "" {
+			return errors.New("resources must have a name")
+		}
+		if listHas(&names, r.Name) {
+			return errors.Errorf("resource name %s is not unique", r.Name)
+		}
+		if r.Provider.CRD.Version == "" {
+			return errors.Errorf("resource %s has no provider.crd.version", r.Name)
+		}
+		names = append(names, r.Name)
+	}
+	references := append([]t.ResourceReference{}, g.References...)
+	for _, r := range g.Resources {
+		references = append(references, r.References...)
+	}
+	for _, ref := range references {
+		if !listHas(&names, ref.Resource) {
+			return errors.Errorf("reference to unknown resource %s, must be one of %s", ref.Resource, strings.Join(names, ", "))
+		}
+		if !strings.HasPrefix(ref.FromFieldPath, "status.") {
+			return errors.Errorf("fromFieldPath %s of the reference to %s must be a field of the status", ref.FromFieldPath, ref.Resource)
+		}
+		if ref.ToFieldPath == "" {
+			return errors.Errorf("reference to %s has no toFieldPath", ref.Resource)
+		}
+	}
+	return nil
+}
+
+// Return the name of the resource of the generator in the composition
+func (g *Generator) resourceName() string {
+	if g.ResourceName != nil {
+		return *g.ResourceName
+	}
+	return g.crd.Spec.Names.Kind
+}
+
+// Return the resources passed to the pipeline generator
+func (g *Generator) generatorResources(generatorConfig *t.GeneratorConfig) ([]generator.Resource, error) {
+	resources := []generator.Resource{}
+	for i, r := range g.Resources {
+		loaded := g.resources[i]
+		crd, _, initProviderFields, err := applyInitProvider(loaded.crd, loaded.crdSource, r.Provider.CRD.Version, g.initProviderHandling(generatorConfig))
+		if err != nil {
+			return nil, err
+		}
+		resource := generator.Resource{
+			Name:               r.Name,
+			Crd:                crd,
+			Provider:           g.resourceProvider(r),
+			OverrideFields:     r.OverrideFields,
+			References:         r.References,
+			TagType:            &loaded.tags.tagType,
+			TagProperty:        &loaded.tags.property,
+			InitProviderFields: initProviderFields,
+		}
+		if loaded.tags.keyField != "" {
+			resource.TagKeyField = &loaded.tags.keyField
+			resource.TagValueField = &loaded.tags.valueField
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	p "github.com/crossplane-contrib/function-patch-and-transform/input/v1beta1"
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestResource(t *testing.T, g *Generator, name string, references []xtype.ResourceReference) {
+	t.Helper()
+	crd := extv1.CustomResourceDefinition{
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Group: "kms.example.cloud",
+			Names: extv1.CustomResourceDefinitionNames{Kind: "Key"},
+			Versions: []extv1.CustomResourceDefinitionVersion{{
+				Name: "v1beta1",
+				Schema: &extv1.CustomResourceValidation{
+					OpenAPIV3Schema: &extv1.JSONSchemaProps{
+						Properties: map[string]extv1.JSONSchemaProps{
+							"spec": {
+								Type: "object",
+								Properties: map[string]extv1.JSONSchemaProps{
+									"forProvider": {
+										Type: "object",
+										Properties: map[string]extv1.JSONSchemaProps{
+											"description": {Type: "string"},
+											"tags":        objectOfStrings,
+										},
+									},
+								},
+							},
+							"status": {
+								Type: "object",
+								Properties: map[string]extv1.JSONSchemaProps{
+									"atProvider": {
+										Type: "object",
+										Properties: map[string]extv1.JSONSchemaProps{
+											"arn": {Type: "string"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+	source, err := json.Marshal(crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Resources = append(g.Resources, xtype.Resource{
+		Name: name,
+		Provider: xtype.ProviderConfig{
+			CRD: xtype.CrdConfig{Version: "v1beta1"},
+		},
+		References: references,
+	})
+	g.resources = append(g.resources, loadedResource{
+		crd:       crd,
+		crdSource: string(source),
+		tags:      detectTags(crd, "v1beta1", defaultTagDetectors),
+	})
+}
+
+func TestGenerator_RenderResources(t *testing.T) {
+	g := newTestGenerator(t, t.TempDir(), true)
+	g.crd.Spec.Names.Kind = "Bucket"
+	g.References = []xtype.ResourceReference{{
+		Resource:      "key",
+		FromFieldPath: "status.atProvider.arn",
+		ToFieldPath:   "spec.forProvider.kmsKeyArn",
+	}}
+	newTestResource(t, &g, "key", []xtype.ResourceReference{{
+		Resource:      "Bucket",
+		FromFieldPath: "status.name",
+		ToFieldPath:   "spec.forProvider.description",
+	}})
+	g.Tags.Common = map[string]string{"team": "storage"}
+
+	gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+	if err := g.CheckConfig(&gConfig); err != nil {
+		t.Fatalf("CheckConfig() error = %v", err)
+	}
+	xrd, compositions := renderTestGenerator(t, &g, &gConfig)
+
+	schema, err := versionSchema(xrd.Spec.Versions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"spec.key.forProvider.description", "status.key.atProvider.arn"} {
+		if _, ok := schemaAt(schema, path); !ok {
+			t.Errorf("Render() definition should have property %s", path)
+		}
+	}
+
+	input := patchAndTransformInput(t, &compositions[0])
+	if len(input.Resources) != 2 || input.Resources[1].Name != "key" {
+		t.Fatalf("Render() should compose the bucket and the key, got %+v", input.Resources)
+	}
+	key := resourceBase(t, input, 1)
+	if key.GetAPIVersion() != "kms.example.cloud/v1beta1" || key.GetKind() != "Key" {
+		t.Errorf("Render() key base = %s %s, want kms.example.cloud/v1beta1 Key", key.GetAPIVersion(), key.GetKind())
+	}
+	keyTags, _, _ := unstructured.NestedStringMap(key.Object, "spec", "forProvider", "tags")
+	if want := map[string]string{"team": "storage"}; !reflect.DeepEqual(keyTags, want) {
+		t.Errorf("Render() key tags = %v, want %v", keyTags, want)
+	}
+
+	tests := []struct {
+		resource int
+		from     string
+		to       string
+		policy   p.FromFieldPathPolicy
+	}{
+		{resource: 0, from: "status.key.atProvider.arn", to: "spec.forProvider.kmsKeyArn", policy: p.FromFieldPathPolicyRequired},
+		{resource: 1, from: "spec.key.forProvider.description", to: "spec.forProvider.description", policy: p.FromFieldPathPolicyOptional},
+		{resource: 1, from: "status.atProvider.arn", to: "status.key.atProvider.arn", policy: p.FromFieldPathPolicyOptional},
+		{resource: 1, from: "status.name", to: "spec.forProvider.description", policy: p.FromFieldPathPolicyRequired},
+	}
+	for _, tt := range tests {
+		patch := findPatch(resourcePatches(t, input, tt.resource), tt.from, tt.to)
+		if patch == nil {
+			t.Errorf("Render() resource %d should patch %s to %s", tt.resource, tt.from, tt.to)
+			continue
+		}
+		if got := patch.Policy.GetFromFieldPathPolicy(); got != tt.policy {
+			t.Errorf("Render() resource %d patch of %s policy = %s, want %s", tt.resource, tt.from, got, tt.policy)
+		}
+	}
+}
+
+func TestGenerator_CheckResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		usePipeline bool
+		references  []xtype.ResourceReference
+		wantErr     string
+	}{
+		{
+			name:        "Should accept references to resources",
+			usePipeline: true,
+			references:  []xtype.ResourceReference{{Resource: "key", FromFieldPath: "status.atProvider.arn", ToFieldPath: "spec.forProvider.kmsKeyArn"}},
+		},
+		{
+			name:    "Should require pipeline mode",
+			wantErr: "only supported in pipeline mode",
+		},
+		{
+			name:        "Should reject unknown resources",
+			usePipeline: true,
+			references:  []xtype.ResourceReference{{Resource: "role", FromFieldPath: "status.atProvider.arn", ToFieldPath: "spec.forProvider.roleArn"}},
+			wantErr:     "unknown resource role",
+		},
+		{
+			name:        "Should reject references to the spec",
+			usePipeline: true,
+			references:  []xtype.ResourceReference{{Resource: "key", FromFieldPath: "spec.forProvider.description", ToFieldPath: "spec.forProvider.description"}},
+			wantErr:     "must be a field of the status",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, t.TempDir(), tt.usePipeline)
+			g.crd.Spec.Names.Kind = "Bucket"
+			g.References = tt.references
+			newTestResource(t, &g, "key", nil)
+			err := g.CheckConfig(&xtype.GeneratorConfig{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
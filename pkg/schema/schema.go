@@ -0,0 +1,204 @@
+// Package schema generates JSON schemas from the go types of the
+// configuration files, so editors can complete and validate them
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Schema is the subset of JSON schema used to describe the configuration files
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	// forbidden is set for the schema false, which does not match any value
+	forbidden bool
+}
+
+// False is the schema not matching any value, used as additionalProperties
+// of objects with a fixed set of properties
+var False = &Schema{forbidden: true}
+
+type schemaAlias Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.forbidden {
+		return []byte("false"), nil
+	}
+	return json.Marshal((*schemaAlias)(s))
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" {
+		*s = Schema{forbidden: true}
+		return nil
+	}
+	return json.Unmarshal(data, (*schemaAlias)(s))
+}
+
+// Forbidden returns true for the schema false
+func (s *Schema) Forbidden() bool {
+	return s.forbidden
+}
+
+// Resolve returns the definition referenced by s, root contains the definitions
+func (s *Schema) Resolve(root *Schema) *Schema {
+	for s.Ref != "" {
+		def, ok := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			return &Schema{}
+		}
+		s = def
+	}
+	return s
+}
+
+// Reflector creates schemas from go types. Fields are named like
+// encoding/json names them, embedded structs are inlined and types
+// decoding themselves accept any value.
+type Reflector struct {
+	// Enums contains the allowed values of string types
+	Enums map[reflect.Type][]string
+
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+// Reflect creates the schema of t, all named struct types except t are
+// added as definitions
+func (r *Reflector) Reflect(t reflect.Type, title string) *Schema {
+	r.defs = map[string]*Schema{}
+	r.names = map[reflect.Type]string{}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := r.structSchema(t)
+	s.Schema = draft
+	s.Title = title
+	if len(r.defs) > 0 {
+		s.Defs = r.defs
+	}
+	return s
+}
+
+func (r *Reflector) reflect(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return &Schema{}
+	}
+	if enum, ok := r.Enums[t]; ok {
+		return &Schema{Type: "string", Enum: enum}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return &Schema{Ref: "#/$defs/" + r.define(t)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: r.reflect(t.Elem())}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+	return &Schema{}
+}
+
+// Add the definition of the struct type t and return its name
+func (r *Reflector) define(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := r.defs[name]; taken || name == "" {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	r.names[t] = name
+	// register before reflecting the fields to support recursive types
+	r.defs[name] = &Schema{}
+	*r.defs[name] = *r.structSchema(t)
+	return name
+}
+
+func (r *Reflector) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: False,
+	}
+	for _, f := range Fields(t) {
+		s.Properties[f.Name] = r.reflect(f.Type)
+	}
+	return s
+}
+
+// Field is a field of a struct as seen by encoding/json
+type Field struct {
+	Name string
+	Type reflect.Type
+}
+
+// Fields returns the fields of the struct type t by their json name, the
+// fields of embedded structs are included unless shadowed
+func Fields(t reflect.Type) []Field {
+	fields := []Field{}
+	seen := map[string]bool{}
+	embedded := []Field{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, Fields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, Field{Name: name, Type: f.Type})
+		seen[name] = true
+	}
+	for _, f := range embedded {
+		if !seen[f.Name] {
+			fields = append(fields, f)
+			seen[f.Name] = true
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type level string
+
+type inner struct {
+	Value string `json:"value"`
+}
+
+type base struct {
+	Name  string `json:"name"`
+	Shown string `json:"shown"`
+}
+
+type node struct {
+	base
+	Shown    int               `json:"shown"`
+	Level    level             `json:"level,omitempty"`
+	Inner    *inner            `json:"inner,omitempty"`
+	Children []node            `json:"children"`
+	Labels   map[string]string `json:"labels"`
+	Raw      json.RawMessage   `json:"raw"`
+	Any      interface{}       `json:"any"`
+	Skipped  string            `json:"-"`
+	Untagged bool
+	hidden   bool
+}
+
+func TestReflector_Reflect(t *testing.T) {
+	r := &Reflector{Enums: map[reflect.Type][]string{reflect.TypeOf(level("")): {"low", "high"}}}
+	got := r.Reflect(reflect.TypeOf(&node{}), "node")
+
+	nodeProperties := map[string]*Schema{
+		"Untagged": {Type: "boolean"},
+		"any":      {},
+		"children": {Type: "array", Items: &Schema{Ref: "#/$defs/node"}},
+		"inner":    {Ref: "#/$defs/inner"},
+		"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		"level":    {Type: "string", Enum: []string{"low", "high"}},
+		"name":     {Type: "string"},
+		"raw":      {},
+		"shown":    {Type: "integer"},
+	}
+	want := &Schema{
+		Schema:               draft,
+		Title:                "node",
+		Type:                 "object",
+		Properties:           nodeProperties,
+		AdditionalProperties: False,
+		Defs: map[string]*Schema{
+			"inner": {
+				Type:                 "object",
+				Properties:           map[string]*Schema{"value": {Type: "string"}},
+				AdditionalProperties: False,
+			},
+			"node": {
+				Type:                 "object",
+				Properties:           nodeProperties,
+				AdditionalProperties: False,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Schema{})); diff != "" {
+		t.Errorf("Reflect() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchema_MarshalJSON(t *testing.T) {
+	s := &Schema{Type: "object", AdditionalProperties: False}
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `{"type":"object","additionalProperties":false}` {
+		t.Errorf("Marshal() = %s", j)
+	}
+	got := &Schema{}
+	if err := json.Unmarshal(j, got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.AdditionalProperties.Forbidden() {
+		t.Errorf("Unmarshal() additionalProperties = %v, want false", got.AdditionalProperties)
+	}
+}
+
+func TestSchema_Resolve(t *testing.T) {
+	root := &Schema{Defs: map[string]*Schema{
+		"a": {Ref: "#/$defs/b"},
+		"b": {Type: "string"},
+	}}
+	if got := (&Schema{Ref: "#/$defs/a"}).Resolve(root); got.Type != "string" {
+		t.Errorf("Resolve() = %v, want the definition of b", got)
+	}
+	if got := (&Schema{Ref: "#/$defs/missing"}).Resolve(root); got.Type != "" {
+		t.Errorf("Resolve() = %v, want an empty schema", got)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane-contrib/x-generation/pkg/schema"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// fieldError is a problem found while checking a config file against its schema
+type fieldError struct {
+	line    int
+	column  int
+	message string
+	// unknown is set for unknown fields, all other errors are type errors
+	unknown bool
+}
+
+// Unmarshal the yaml content of the config file at path into v. The content
+// is validated against the schema of v, unknown fields and values of the
+// wrong type are reported with their position in the file. If strict is
+// false, unknown fields are returned as warnings instead of failing.
+func unmarshalConfig(path string, content []byte, v interface{}, strict bool) ([]string, error) {
+	fieldErrors, err := checkFields(content, configSchema(v))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", path)
+	}
+	warnings := []string{}
+	messages := []string{}
+	for _, e := range fieldErrors {
+		message := fmt.Sprintf("%s:%d:%d: %s", path, e.line, e.column, e.message)
+		if e.unknown && !strict {
+			warnings = append(warnings, message)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) > 0 {
+		return warnings, errors.New(strings.Join(messages, "\n"))
+	}
+	if err := yaml.Unmarshal(content, v); err != nil {
+		return warnings, errors.Wrapf(err, "cannot parse %s", path)
+	}
+	return warnings, nil
+}
+
+// Check the yaml content against the schema s, like encoding/json does
+// field names are matched case insensitive
+func checkFields(content []byte, s *schema.Schema) ([]fieldError, error) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	c := &fieldChecker{root: s}
+	c.check(&root, s, "")
+	return c.errors, nil
+}
+
+type fieldChecker struct {
+	// root contains the definitions referenced by the schemas
+	root   *schema.Schema
+	errors []fieldError
+}
+
+func (c *fieldChecker) check(n *yamlv3.Node, s *schema.Schema, path string) {
+	switch n.Kind {
+	case yamlv3.DocumentNode:
+		for _, d := range n.Content {
+			c.check(d, s, path)
+		}
+		return
+	case yamlv3.AliasNode:
+		c.check(n.Alias, s, path)
+		return
+	}
+	if s == nil || n.Tag == "!!null" {
+		return
+	}
+	s = s.Resolve(c.root)
+
+	if len(s.Enum) > 0 {
+		if n.Kind != yamlv3.ScalarNode || !listHas(&s.Enum, n.Value) {
+			c.errors = append(c.errors, fieldError{
+				line:    n.Line,
+				column:  n.Column,
+				message: fmt.Sprintf("%s must be one of %s, got %s", path, strings.Join(s.Enum, ", "), describeNode(n)) + didYouMean(suggest(n.Value, s.Enum)),
+			})
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if n.Kind != yamlv3.MappingNode {
+			c.typeError(n, path, "an object")
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" {
+				c.check(value, s, path)
+				continue
+			}
+			if property, ok := lookupProperty(s.Properties, key.Value); ok {
+				c.check(value, property, joinPath(path, key.Value))
+				continue
+			}
+			if s.AdditionalProperties != nil && s.AdditionalProperties.Forbidden() {
+				c.unknownField(key, path, s.Properties)
+				continue
+			}
+			if s.AdditionalProperties != nil {
+				c.check(value, s.AdditionalProperties, joinPath(path, key.Value))
+			}
+		}
+	case "array":
+		if n.Kind != yamlv3.SequenceNode {
+			c.typeError(n, path, "a list")
+			return
+		}
+		for i, e := range n.Content {
+			c.check(e, s.Items, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "boolean":
+		if n.Kind != yamlv3.ScalarNode || n.Tag != "!!bool" {
+			c.typeError(n, path, "a boolean")
+		}
+	case "integer":
+		if n.Kind != yamlv3.ScalarNode || n.Tag != "!!int" {
+			c.typeError(n, path, "an integer")
+		}
+	case "number":
+		if n.Kind != yamlv3.ScalarNode || (n.Tag != "!!int" && n.Tag != "!!float") {
+			c.typeError(n, path, "a number")
+		}
+	case "string":
+		// scalars of any type are converted to strings
+		if n.Kind != yamlv3.ScalarNode {
+			c.typeError(n, path, "a string")
+		}
+	}
+}
+
+func (c *fieldChecker) unknownField(key *yamlv3.Node, path string, properties map[string]*schema.Schema) {
+	names := []string{}
+	for name := range properties {
+		names = append(names, name)
+	}
+	message := fmt.Sprintf("unknown field %q", key.Value)
+	if path != "" {
+		message += " in " + path
+	}
+	c.errors = append(c.errors, fieldError{
+		line:    key.Line,
+		column:  key.Column,
+		message: message + didYouMean(suggest(key.Value, names)),
+		unknown: true,
+	})
+}
+
+func (c *fieldChecker) typeError(n *yamlv3.Node, path, want string) {
+	c.errors = append(c.errors, fieldError{
+		line:    n.Line,
+		column:  n.Column,
+		message: fmt.Sprintf("%s must be %s, got %s", path, want, describeNode(n)),
+	})
+}
+
+func describeNode(n *yamlv3.Node) string {
+	switch n.Kind {
+	case yamlv3.SequenceNode:
+		return "a list"
+	case yamlv3.ScalarNode:
+		return fmt.Sprintf("%q", n.Value)
+	}
+	return "an object"
+}
+
+func lookupProperty(properties map[string]*schema.Schema, name string) (*schema.Schema, bool) {
+	if s, ok := properties[name]; ok {
+		return s, true
+	}
+	for n, s := range properties {
+		if strings.EqualFold(n, name) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
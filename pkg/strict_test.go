@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+)
+
+func Test_unmarshalConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		target       interface{}
+		strict       bool
+		wantErr      []string
+		wantWarnings int
+	}{
+		{
+			name: "Should accept a valid generate.yaml",
+			content: `group: example.cloud
+name: Bucket
+ignore: false
+scriptFile: other.jsonnet
+provider:
+  name: provider-aws
+  crd:
+    file: s3.aws.crossplane.io_buckets.yaml
+overrideFieldsInClaim:
+  - claimPath: spec.parameters.name
+    overrideSettings:
+      property:
+        type: string
+        default: test
+`,
+			target: &Generator{},
+			strict: true,
+		},
+		{
+			name: "Should report unknown fields with position and suggestion",
+			content: `compositionIdentifier: example.cloud
+patchAndTransformFunction: function-patch-and-transform
+additionalPipelineSteps:
+  - step: test
+    bevore: true
+`,
+			target: &xtype.GeneratorConfig{},
+			strict: true,
+			wantErr: []string{
+				`generator-config.yaml:2:1: unknown field "patchAndTransformFunction", did you mean patchAndTransfromFunction?`,
+				`generator-config.yaml:5:5: unknown field "bevore" in additionalPipelineSteps[0], did you mean before?`,
+			},
+		},
+		{
+			name: "Should find fields of embedded structs",
+			content: `provider:
+  baseURL: https://example.cloud
+  crd:
+    fiel: test.yaml
+`,
+			target:  &Generator{},
+			strict:  true,
+			wantErr: []string{`generator-config.yaml:4:5: unknown field "fiel" in provider.crd, did you mean file?`},
+		},
+		{
+			name: "Should report type errors",
+			content: `ignore: yes please
+compositions:
+  name: test
+`,
+			target: &Generator{},
+			strict: true,
+			wantErr: []string{
+				`generator-config.yaml:1:9: ignore must be a boolean, got "yes please"`,
+				`generator-config.yaml:3:3: compositions must be a list, got an object`,
+			},
+		},
+		{
+			name: "Should only warn about unknown fields if not strict",
+			content: `name: Bucket
+overrideFieldsInClaims: []
+`,
+			target:       &Generator{},
+			strict:       false,
+			wantWarnings: 1,
+		},
+		{
+			name: "Should report type errors if not strict",
+			content: `usePipeline: []
+`,
+			target:  &xtype.GeneratorConfig{},
+			strict:  false,
+			wantErr: []string{`generator-config.yaml:1:14: usePipeline must be a boolean, got a list`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := unmarshalConfig("generator-config.yaml", []byte(tt.content), tt.target, tt.strict)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !reflect.DeepEqual(strings.Split(err.Error(), "\n"), tt.wantErr) {
+					t.Errorf("unmarshalConfig() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unmarshalConfig() error = %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("unmarshalConfig() warnings = %v, want %d warnings", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggest returns the candidates similar to value, the most similar first.
+// A candidate is similar if it differs only in case or its edit distance to
+// value is at most a third of the length of value (but at least 2).
+func suggest(value string, candidates []string) []string {
+	maxDistance := len(value) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	type match struct {
+		candidate string
+		distance  int
+	}
+	matches := []match{}
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if seen[c] || c == value {
+			continue
+		}
+		seen[c] = true
+		d := levenshtein(strings.ToLower(value), strings.ToLower(c))
+		if d <= maxDistance {
+			matches = append(matches, match{candidate: c, distance: d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].candidate < matches[j].candidate
+	})
+	result := []string{}
+	for _, m := range matches {
+		result = append(result, m.candidate)
+	}
+	return result
+}
+
+// Format suggestions as part of an error message
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+	return ", did you mean " + strings.Join(suggestions, " or ") + "?"
+}
+
+// Calculate the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// tagDetection is the shape of the tags of a managed resource
+type tagDetection struct {
+	tagType  string
+	property string
+	// keyField and valueField are the names of the key and value of array tags
+	keyField   string
+	valueField string
+}
+
+var noTags = tagDetection{tagType: "noTag"}
+
+// tagDetector detects the tags in the schema of a managed resource
+type tagDetector interface {
+	detect(schema *extv1.JSONSchemaProps) (tagDetection, bool)
+}
+
+// pathTagDetector finds tags at a fixed path of the schema, which are either
+// an object of strings or an array of objects with a key and a value field
+type pathTagDetector struct {
+	path string
+	// fields are the names of key and value the items of array tags are
+	// checked for, the first matching pair is used
+	fields [][2]string
+}
+
+// array tag items known by the generator, other names need a tag detector
+var knownTagFields = [][2]string{{"key", "value"}, {"tagKey", "tagValue"}}
+
+// The default detectors, used after the detectors of the generator config
+var defaultTagDetectors = []tagDetector{
+	&pathTagDetector{path: "spec.forProvider.tags", fields: knownTagFields},
+	&pathTagDetector{path: "spec.forProvider.tagging.tagSet", fields: knownTagFields},
+	&pathTagDetector{path: "spec.initProvider.tags", fields: knownTagFields},
+	&pathTagDetector{path: "spec.initProvider.tagging.tagSet", fields: knownTagFields},
+}
+
+// Create the tag detectors from the tagDetectors of the generator config,
+// followed by the default detectors
+func newTagDetectors(config []t.TagDetector) []tagDetector {
+	detectors := []tagDetector{}
+	for _, c := range config {
+		d := &pathTagDetector{path: c.Path, fields: knownTagFields}
+		if c.KeyField != "" || c.ValueField != "" {
+			keyField, valueField := c.KeyField, c.ValueField
+			if keyField == "" {
+				keyField = "key"
+			}
+			if valueField == "" {
+				valueField = "value"
+			}
+			d.fields = [][2]string{{keyField, valueField}}
+		}
+		detectors = append(detectors, d)
+	}
+	return append(detectors, defaultTagDetectors...)
+}
+
+func (d *pathTagDetector) detect(schema *extv1.JSONSchemaProps) (tagDetection, bool) {
+	tags, ok := schemaAt(schema, d.path)
+	if !ok {
+		return noTags, false
+	}
+	switch tags.Type {
+	case "array":
+		if tags.Items == nil || tags.Items.Schema == nil || tags.Items.Schema.Type != "object" {
+			return noTags, false
+		}
+		properties := tags.Items.Schema.Properties
+		for _, f := range d.fields {
+			_, hasKey := properties[f[0]]
+			_, hasValue := properties[f[1]]
+			if !hasKey || !hasValue {
+				continue
+			}
+			tagType := "keyValueArray"
+			if f[0] == "tagKey" && f[1] == "tagValue" {
+				tagType = "tagKeyTagValueArray"
+			}
+			return tagDetection{tagType: tagType, property: d.path, keyField: f[0], valueField: f[1]}, true
+		}
+	case "object":
+		if tags.AdditionalProperties != nil && tags.AdditionalProperties.Schema != nil && tags.AdditionalProperties.Schema.Type == "string" {
+			return tagDetection{tagType: "tagObject", property: d.path}, true
+		}
+	}
+	return noTags, false
+}
+
+// Return the schema of the property at the dot separated path
+func schemaAt(schema *extv1.JSONSchemaProps, path string) (*extv1.JSONSchemaProps, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		property, ok := schema.Properties[segment]
+		if !ok {
+			return nil, false
+		}
+		schema = &property
+	}
+	return schema, true
+}
+
+// Return the schema of the given version of the crd
+func crdVersionSchema(crd extv1.CustomResourceDefinition, version string) (*extv1.JSONSchemaProps, bool) {
+	for _, v := range crd.Spec.Versions {
+		if v.Name == version && v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+			return v.Schema.OpenAPIV3Schema, true
+		}
+	}
+	return nil, false
+}
+
+// Detect the tags of the crd using the first matching detector
+func detectTags(crd extv1.CustomResourceDefinition, version string, detectors []tagDetector) tagDetection {
+	schema, ok := crdVersionSchema(crd, version)
+	if !ok {
+		return noTags
+	}
+	for _, d := range detectors {
+		if tags, ok := d.detect(schema); ok {
+			return tags
+		}
+	}
+	return noTags
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func crdWithForProvider(forProvider map[string]extv1.JSONSchemaProps) extv1.CustomResourceDefinition {
+	return extv1.CustomResourceDefinition{
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Versions: []extv1.CustomResourceDefinitionVersion{{
+				Name: "v1beta1",
+				Schema: &extv1.CustomResourceValidation{
+					OpenAPIV3Schema: &extv1.JSONSchemaProps{
+						Properties: map[string]extv1.JSONSchemaProps{
+							"spec": {
+								Type: "object",
+								Properties: map[string]extv1.JSONSchemaProps{
+									"forProvider": {Type: "object", Properties: forProvider},
+								},
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func arrayOf(properties ...string) extv1.JSONSchemaProps {
+	items := &extv1.JSONSchemaProps{Type: "object", Properties: map[string]extv1.JSONSchemaProps{}}
+	for _, p := range properties {
+		items.Properties[p] = extv1.JSONSchemaProps{Type: "string"}
+	}
+	return extv1.JSONSchemaProps{Type: "array", Items: &extv1.JSONSchemaPropsOrArray{Schema: items}}
+}
+
+var objectOfStrings = extv1.JSONSchemaProps{
+	Type:                 "object",
+	AdditionalProperties: &extv1.JSONSchemaPropsOrBool{Schema: &extv1.JSONSchemaProps{Type: "string"}},
+}
+
+func Test_detectTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		forProvider map[string]extv1.JSONSchemaProps
+		config      []xtype.TagDetector
+		want        tagDetection
+	}{
+		{
+			name:        "Should detect key value tags by default",
+			forProvider: map[string]extv1.JSONSchemaProps{"tags": arrayOf("key", "value")},
+			want:        tagDetection{tagType: "keyValueArray", property: "spec.forProvider.tags", keyField: "key", valueField: "value"},
+		},
+		{
+			name:        "Should detect tagKey tagValue tags in tagSet by default",
+			forProvider: map[string]extv1.JSONSchemaProps{"tagging": {Type: "object", Properties: map[string]extv1.JSONSchemaProps{"tagSet": arrayOf("tagKey", "tagValue")}}},
+			want:        tagDetection{tagType: "tagKeyTagValueArray", property: "spec.forProvider.tagging.tagSet", keyField: "tagKey", valueField: "tagValue"},
+		},
+		{
+			name:        "Should not detect labels without detector",
+			forProvider: map[string]extv1.JSONSchemaProps{"labels": objectOfStrings},
+			want:        noTags,
+		},
+		{
+			name:        "Should detect labels with a configured detector",
+			forProvider: map[string]extv1.JSONSchemaProps{"labels": objectOfStrings},
+			config:      []xtype.TagDetector{{Path: "spec.forProvider.labels"}},
+			want:        tagDetection{tagType: "tagObject", property: "spec.forProvider.labels"},
+		},
+		{
+			name:        "Should detect arrays with configured item names",
+			forProvider: map[string]extv1.JSONSchemaProps{"resourceTags": arrayOf("name", "value")},
+			config:      []xtype.TagDetector{{Path: "spec.forProvider.resourceTags", KeyField: "name"}},
+			want:        tagDetection{tagType: "keyValueArray", property: "spec.forProvider.resourceTags", keyField: "name", valueField: "value"},
+		},
+		{
+			name: "Should prefer configured detectors over the defaults",
+			forProvider: map[string]extv1.JSONSchemaProps{
+				"tags":   arrayOf("key", "value"),
+				"labels": objectOfStrings,
+			},
+			config: []xtype.TagDetector{{Path: "spec.forProvider.labels"}},
+			want:   tagDetection{tagType: "tagObject", property: "spec.forProvider.labels"},
+		},
+		{
+			name:        "Should not detect arrays with other item names",
+			forProvider: map[string]extv1.JSONSchemaProps{"resourceTags": arrayOf("name", "value")},
+			config:      []xtype.TagDetector{{Path: "spec.forProvider.resourceTags"}},
+			want:        noTags,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectTags(crdWithForProvider(tt.forProvider), "v1beta1", newTagDetectors(tt.config))
+			if got != tt.want {
+				t.Errorf("detectTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderDetectedTags(t *testing.T) {
+	for _, usePipeline := range []bool{false, true} {
+		tempDir := t.TempDir()
+		g := newTestGenerator(t, tempDir, usePipeline)
+		g.crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties["forProvider"].Properties["resourceTags"] = arrayOf("name", "value")
+		g.Tags.FromLabels = []string{"tags.example.cloud/zone"}
+		gConfig := xtype.GeneratorConfig{
+			CompositionIdentifier: "example.cloud",
+			TagDetectors:          []xtype.TagDetector{{Path: "spec.forProvider.resourceTags", KeyField: "name"}},
+		}
+		tags := detectTags(g.crd, "testv1", newTagDetectors(gConfig.TagDetectors))
+		g.TagType, g.TagProperty, g.TagKeyField, g.TagValueField = &tags.tagType, &tags.property, &tags.keyField, &tags.valueField
+
+		_, compositions := renderTestGenerator(t, &g, &gConfig)
+		input := patchAndTransformInput(t, &compositions[0])
+		base := resourceBase(t, input, 0)
+		resourceTags, _, _ := unstructured.NestedSlice(base.Object, "spec", "forProvider", "resourceTags")
+		if want := []interface{}{map[string]interface{}{"name": "tags.example.cloud/zone"}}; !reflect.DeepEqual(resourceTags, want) {
+			t.Errorf("Render() usePipeline %v base resourceTags = %v, want %v", usePipeline, resourceTags, want)
+		}
+		patches := resourcePatches(t, input, 0)
+		if findPatch(patches, "metadata.labels[tags.example.cloud/zone]", "spec.forProvider.resourceTags[0].value") == nil {
+			t.Errorf("Render() usePipeline %v should patch the label to the tag value, got %+v", usePipeline, patches)
+		}
+	}
+}
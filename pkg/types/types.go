@@ -19,15 +19,118 @@ type Composition struct {
 }
 
 type GeneratorConfig struct {
-	CompositionIdentifier     string               `yaml:"compositionIdentifier" json:"compositionIdentifier"`
-	Provider                  GlobalProviderConfig `yaml:"provider" json:"provider"`
-	Tags                      TagConfig            `yaml:"tags,omitempty" json:"tags,omitempty"`
-	Labels                    LabelConfig          `yaml:"labels,omitempty" json:"labels,omitempty"`
-	UsePipeline               *bool                `yaml:"usePipeline,omitempty" json:"usePipeline,omitempty"`
-	PatchAndTransfromFunction *string              `yaml:"patchAndTransfromFunction,omitempty" json:"patchAndTransfromFunction,omitempty"`
-	ExpandCompositionName     *bool                `yaml:"expandCompositionName,omitempty" json:"expandCompositionName,omitempty"`
-	AdditionalPipelineSteps   []PipelineStep       `yaml:"additionalPipelineSteps,omitempty" json:"additionalPipelineSteps,omitempty"`
-	AutoReadyFunction         *AutoReadyFunction   `yaml:"autoReadyFunction,omitempty" json:"autoReadyFunction,omitempty"`
+	CompositionIdentifier     string                `yaml:"compositionIdentifier" json:"compositionIdentifier"`
+	Provider                  GlobalProviderConfig  `yaml:"provider" json:"provider"`
+	Tags                      TagConfig             `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Labels                    LabelConfig           `yaml:"labels,omitempty" json:"labels,omitempty"`
+	UsePipeline               *bool                 `yaml:"usePipeline,omitempty" json:"usePipeline,omitempty"`
+	PatchAndTransfromFunction *string               `yaml:"patchAndTransfromFunction,omitempty" json:"patchAndTransfromFunction,omitempty"`
+	CompositionBackend        *CompositionBackend   `yaml:"compositionBackend,omitempty" json:"compositionBackend,omitempty"`
+	GoTemplatingFunction      *string               `yaml:"goTemplatingFunction,omitempty" json:"goTemplatingFunction,omitempty"`
+	KCLFunction               *string               `yaml:"kclFunction,omitempty" json:"kclFunction,omitempty"`
+	ExpandCompositionName     *bool                 `yaml:"expandCompositionName,omitempty" json:"expandCompositionName,omitempty"`
+	AdditionalPipelineSteps   []PipelineStep        `yaml:"additionalPipelineSteps,omitempty" json:"additionalPipelineSteps,omitempty"`
+	AutoReadyFunction         *AutoReadyFunction    `yaml:"autoReadyFunction,omitempty" json:"autoReadyFunction,omitempty"`
+	TagDetectors              []TagDetector         `yaml:"tagDetectors,omitempty" json:"tagDetectors,omitempty"`
+	InitProvider              *InitProviderHandling `yaml:"initProvider,omitempty" json:"initProvider,omitempty"`
+	CrossplaneVersion         *CrossplaneVersion    `yaml:"crossplaneVersion,omitempty" json:"crossplaneVersion,omitempty"`
+	Examples                  *ExamplesConfig       `yaml:"examples,omitempty" json:"examples,omitempty"`
+	Configuration             *ConfigurationConfig  `yaml:"configuration,omitempty" json:"configuration,omitempty"`
+	Functions                 map[string]Function   `yaml:"functions,omitempty" json:"functions,omitempty"`
+	FunctionsPath             *string               `yaml:"functionsPath,omitempty" json:"functionsPath,omitempty"`
+}
+
+// Function is a composition function of the functions registry, every
+// function referenced by a generated composition must be registered
+type Function struct {
+	// Package is the package of the function including its tag, e.g.
+	// xpkg.upbound.io/crossplane-contrib/function-auto-ready:v0.2.1
+	Package string `yaml:"package" json:"package"`
+	// Annotations of the generated Function manifest, e.g.
+	// render.crossplane.io/runtime: Development
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+}
+
+// ConfigurationConfig configures the crossplane.yaml of the Configuration
+// package, its spec.dependsOn lists the providers and functions used by the
+// generated compositions
+type ConfigurationConfig struct {
+	// Path of the crossplane.yaml, relative to the global configuration file.
+	// Only spec.dependsOn of an existing file is replaced.
+	Path string `yaml:"path" json:"path"`
+	// Registry the packages are pulled from, including the organization,
+	// defaults to xpkg.upbound.io/crossplane-contrib
+	Registry *string `yaml:"registry,omitempty" json:"registry,omitempty"`
+	// Packages overrides the package and the version constraint of providers
+	// and functions by their name
+	Packages map[string]PackageReference `yaml:"packages,omitempty" json:"packages,omitempty"`
+}
+
+// PackageReference is the package of a provider or a function
+type PackageReference struct {
+	// Package is the package without tag, defaults to <registry>/<name>
+	Package *string `yaml:"package,omitempty" json:"package,omitempty"`
+	// Version is the version constraint, defaults to >= the lowest version
+	// used for providers and to any version for functions
+	Version *string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+// ExamplesConfig configures the generation of example claims from the
+// generated definition
+type ExamplesConfig struct {
+	Generate *bool `yaml:"generate,omitempty" json:"generate,omitempty"`
+	// Path is the directory the examples are written to, into a subdirectory
+	// named like the directory of the generate.yaml. By default the examples
+	// are written next to the definition.
+	Path *string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// CrossplaneVersion is the version of crossplane the definitions are generated for
+type CrossplaneVersion string
+
+const (
+	// CrossplaneV1 generates cluster scoped composites with a claim
+	CrossplaneV1 CrossplaneVersion = "v1"
+	// CrossplaneV2 generates namespaced composites without a claim
+	CrossplaneV2 CrossplaneVersion = "v2"
+)
+
+// CompositionBackend is the composition function the resources of generated
+// compositions are rendered with in pipeline mode
+type CompositionBackend string
+
+const (
+	// CompositionBackendPatchAndTransform renders the resources with the
+	// Resources input of function-patch-and-transform
+	CompositionBackendPatchAndTransform CompositionBackend = "patch-and-transform"
+	// CompositionBackendGoTemplating renders the resources with an inline
+	// template of function-go-templating
+	CompositionBackendGoTemplating CompositionBackend = "go-templating"
+	// CompositionBackendKCL renders the resources with a program of
+	// function-kcl
+	CompositionBackendKCL CompositionBackend = "kcl"
+)
+
+// InitProviderHandling configures how spec.initProvider of upjet based providers
+// is shown in the claim
+type InitProviderHandling string
+
+const (
+	// InitProviderExpose exposes spec.initProvider like spec.forProvider
+	InitProviderExpose InitProviderHandling = "expose"
+	// InitProviderMerge adds the properties only existing in spec.initProvider
+	// to spec.forProvider of the claim, those are patched to spec.initProvider
+	InitProviderMerge InitProviderHandling = "merge"
+	// InitProviderHide removes spec.initProvider from the claim
+	InitProviderHide InitProviderHandling = "hide"
+)
+
+// TagDetector configures where the tags of managed resources are detected,
+// either an object of strings or an array of objects with a key and a value
+type TagDetector struct {
+	Path       string `yaml:"path" json:"path"`
+	KeyField   string `yaml:"keyField,omitempty" json:"keyField,omitempty"`
+	ValueField string `yaml:"valueField,omitempty" json:"valueField,omitempty"`
 }
 
 type AutoReadyFunction struct {
@@ -80,18 +183,49 @@ type LocalLabelConfig struct {
 type CrdConfig struct {
 	File    string `yaml:"file" json:"file"`
 	Version string `yaml:"version" json:"version"`
+	Group   string `yaml:"group,omitempty" json:"group,omitempty"`
+	Kind    string `yaml:"kind,omitempty" json:"kind,omitempty"`
 }
 
 type GlobalProviderConfig struct {
-	Name    string  `yaml:"name" json:"name"`
-	Version string  `yaml:"version" json:"version"`
-	BaseURL *string `yaml:"baseURL,omitempty" json:"baseURL,omitempty"`
+	Name     string  `yaml:"name" json:"name"`
+	Version  string  `yaml:"version" json:"version"`
+	BaseURL  *string `yaml:"baseURL,omitempty" json:"baseURL,omitempty"`
+	CRDPath  *string `yaml:"crdPath,omitempty" json:"crdPath,omitempty"`
+	Package  *string `yaml:"package,omitempty" json:"package,omitempty"`
+	IndexURL *string `yaml:"indexURL,omitempty" json:"indexURL,omitempty"`
 }
 type ProviderConfig struct {
 	GlobalProviderConfig
 	CRD CrdConfig `yaml:"crd" json:"crd"`
 }
 
+// Resource is an additional managed resource composed together with the
+// resource of the generator
+type Resource struct {
+	Name           string              `yaml:"name" json:"name"`
+	Provider       ProviderConfig      `yaml:"provider" json:"provider"`
+	OverrideFields []OverrideField     `yaml:"overrideFields,omitempty" json:"overrideFields,omitempty"`
+	References     []ResourceReference `yaml:"references,omitempty" json:"references,omitempty"`
+}
+
+// XRDVersion is an additional served version of the xrd
+type XRDVersion struct {
+	Name string `yaml:"name" json:"name"`
+	// CRDVersion is the version of the crd the version is generated from,
+	// defaults to provider.crd.version
+	CRDVersion    string `yaml:"crdVersion,omitempty" json:"crdVersion,omitempty"`
+	Referenceable bool   `yaml:"referenceable,omitempty" json:"referenceable,omitempty"`
+}
+
+// ResourceReference patches a status field of another resource of the
+// composition to a field of this resource
+type ResourceReference struct {
+	Resource      string `yaml:"resource" json:"resource"`
+	FromFieldPath string `yaml:"fromFieldPath" json:"fromFieldPath"`
+	ToFieldPath   string `yaml:"toFieldPath" json:"toFieldPath"`
+}
+
 type OverrideFieldInClaim struct {
 	ClaimPath        string            `yaml:"claimPath" json:"claimPath"`
 	ManagedPath      *string           `yaml:"managedPath,omitempty" json:"managedPath,omitempty"`
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// upgradeReport lists the changes of the crds of all generators using a
+// provider when the provider is upgraded to a new version
+type upgradeReport struct {
+	Provider   string             `json:"provider"`
+	Version    string             `json:"version"`
+	Generators []generatorUpgrade `json:"generators"`
+}
+
+type generatorUpgrade struct {
+	Path      string            `json:"path"`
+	Resources []resourceUpgrade `json:"resources"`
+}
+
+// resourceUpgrade lists the changes of a version of the crd of a resource
+type resourceUpgrade struct {
+	Name               string        `json:"name"`
+	CRDVersion         string        `json:"crdVersion"`
+	OldVersion         string        `json:"oldVersion"`
+	NewVersion         string        `json:"newVersion"`
+	AddedFields        []string      `json:"addedFields"`
+	RemovedFields      []string      `json:"removedFields"`
+	ChangedValidations []fieldChange `json:"changedValidations"`
+	// the tags of the old and the new crd, only set if they differ
+	OldTags *tagShape `json:"oldTags,omitempty"`
+	NewTags *tagShape `json:"newTags,omitempty"`
+	// error is set if the crds could not be compared
+	Error string `json:"error,omitempty"`
+}
+
+type fieldChange struct {
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+	Breaking bool   `json:"breaking"`
+}
+
+type tagShape struct {
+	Type       string `json:"type"`
+	Property   string `json:"property,omitempty"`
+	KeyField   string `json:"keyField,omitempty"`
+	ValueField string `json:"valueField,omitempty"`
+}
+
+// providerUpgrade is the new version of a provider
+type providerUpgrade struct {
+	name    string
+	version string
+}
+
+// Run the upgrade-report subcommand: compare the crds of all generators using
+// the provider with the crds of the new version of the provider and write the
+// report as markdown and json
+func runUpgradeReport(argv []string, out io.Writer) error {
+	var args arguments
+	fs := flag.NewFlagSet("upgrade-report", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := addFlags(fs, &args); err != nil {
+		return err
+	}
+	provider := fs.String("provider", "", "name of the upgraded provider (default: provider.name of the global config file)")
+	version := fs.String("version", "", "new version of the provider")
+	reportPath := fs.String("reportPath", "upgrade-report", "path of the report without extension, the report is written to a .md and a .json file")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "Usage: x-generation upgrade-report --version version [flags]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(argv); err != nil {
+		return err
+	}
+	setDefaultArgs(&args)
+	if *version == "" {
+		fs.Usage()
+		return errors.New("the new version of the provider must be given with --version")
+	}
+
+	list, err := findGenerators(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot find generator files")
+	}
+	generatorConfig, err := loadGeneratorConfig(args.configFile, !args.allowUnknownFields)
+	if err != nil {
+		return errors.Wrap(err, "cannot load generator config file")
+	}
+	if err := checkConfig(generatorConfig); err != nil {
+		return errors.Wrap(err, "generator config not valid")
+	}
+	loader, err := newCRDLoader(&args)
+	if err != nil {
+		return errors.Wrap(err, "cannot load lock file")
+	}
+	upgrade := providerUpgrade{name: *provider, version: *version}
+	if upgrade.name == "" {
+		upgrade.name = generatorConfig.Provider.Name
+	}
+
+	var mu sync.Mutex
+	reports := map[string]*generatorUpgrade{}
+	failures := []failure{}
+	results := generateAll(list, args.concurrency, args.keepGoing, func(path string, out io.Writer) (bool, error) {
+		report, err := upgrade.generatorReport(path, &args, generatorConfig, loader, out)
+		if err != nil {
+			return false, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		reports[path] = report
+		return true, nil
+	})
+	report := upgradeReport{Provider: upgrade.name, Version: upgrade.version, Generators: []generatorUpgrade{}}
+	for i, r := range results {
+		<-r.done
+		if r.skipped {
+			continue
+		}
+		out.Write(r.output.Bytes())
+		if r.err != nil {
+			failures = append(failures, failure{path: list[i], err: r.err})
+			continue
+		}
+		if g := reports[list[i]]; g != nil {
+			report.Generators = append(report.Generators, *g)
+		}
+	}
+	if len(failures) > 0 {
+		printFailures(out, failures, len(list))
+		return errors.New("cannot create the report for all generators")
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	for path, content := range map[string][]byte{
+		*reportPath + ".json": append(content, '\n'),
+		*reportPath + ".md":   []byte(report.markdown()),
+	} {
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return errors.Wrapf(err, "cannot write %s", path)
+		}
+		fmt.Fprintf(out, "Wrote %s\n", path)
+	}
+	return nil
+}
+
+// Return the global configuration and the provider using the new version, the
+// old version is returned as well. False is returned if the provider is not
+// upgraded.
+func (u providerUpgrade) apply(generatorConfig *t.GeneratorConfig, provider t.ProviderConfig) (*t.GeneratorConfig, t.ProviderConfig, string, bool) {
+	if provider.Name != "" {
+		if provider.Name != u.name {
+			return nil, provider, "", false
+		}
+		oldVersion := provider.Version
+		provider.Version = u.version
+		return generatorConfig, provider, oldVersion, true
+	}
+	if generatorConfig.Provider.Name != u.name {
+		return nil, provider, "", false
+	}
+	upgraded := *generatorConfig
+	upgraded.Provider.Version = u.version
+	return &upgraded, provider, generatorConfig.Provider.Version, true
+}
+
+// Create the report of the generate file at path, nil is returned if the
+// generator is ignored
+func (u providerUpgrade) generatorReport(path string, args *arguments, generatorConfig *t.GeneratorConfig, loader *crdLoader, out io.Writer) (*generatorUpgrade, error) {
+	g, err := (&Generator{
+		OverrideFields:        []t.OverrideField{},
+		Compositions:          []t.Composition{},
+		OverrideFieldsInClaim: []t.OverrideFieldInClaim{},
+		log:                   log.New(out, "", log.LstdFlags),
+	}).LoadConfig(path, !args.allowUnknownFields)
+	if err != nil {
+		return nil, err
+	}
+	if g.Ignore {
+		return nil, nil
+	}
+
+	name := ""
+	if g.ResourceName != nil {
+		name = *g.ResourceName
+	}
+	crdVersions := []string{}
+	for _, v := range g.xrdVersions() {
+		if !listHas(&crdVersions, v.CRDVersion) {
+			crdVersions = append(crdVersions, v.CRDVersion)
+		}
+	}
+
+	report := &generatorUpgrade{Path: path, Resources: []resourceUpgrade{}}
+	detectors := newTagDetectors(generatorConfig.TagDetectors)
+	reports, err := u.resourceReports(g, generatorConfig, g.Provider, name, crdVersions, detectors, loader)
+	if err != nil {
+		return nil, err
+	}
+	report.Resources = append(report.Resources, reports...)
+	for _, r := range g.Resources {
+		reports, err := u.resourceReports(g, generatorConfig, g.resourceProvider(r), r.Name, []string{r.Provider.CRD.Version}, detectors, loader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resource %s", r.Name)
+		}
+		report.Resources = append(report.Resources, reports...)
+	}
+	fmt.Fprintf(out, "Compared %d crd versions of %s\n", len(report.Resources), path)
+	return report, nil
+}
+
+// Compare the given versions of the crd of the provider with the crd of the
+// new version of the provider, the kind of the crd is used if name is empty.
+// An error is returned if the crd is read from the same location for both
+// versions, e.g. from crdPath or --crdDir, as there is nothing to compare.
+func (u providerUpgrade) resourceReports(g *Generator, generatorConfig *t.GeneratorConfig, provider t.ProviderConfig, name string, crdVersions []string, detectors []tagDetector, loader *crdLoader) ([]resourceUpgrade, error) {
+	upgradedConfig, upgradedProvider, oldVersion, ok := u.apply(generatorConfig, provider)
+	if !ok {
+		return nil, nil
+	}
+	oldSource, oldErr := g.getProviderCRDSource(generatorConfig, provider, loader)
+	newSource, newErr := g.getProviderCRDSource(upgradedConfig, upgradedProvider, loader)
+	if oldErr == nil && newErr == nil && oldSource.Location(provider.CRD) == newSource.Location(upgradedProvider.CRD) {
+		return nil, errors.Errorf("the crd is read from %s for both %s and %s, the location does not depend on the provider version", oldSource.Location(provider.CRD), oldVersion, u.version)
+	}
+	reports := []resourceUpgrade{}
+	oldCRD, _, _, oldErr := g.loadProviderCRD(generatorConfig, provider, loader)
+	newCRD, _, _, newErr := g.loadProviderCRD(upgradedConfig, upgradedProvider, loader)
+	if name == "" {
+		switch {
+		case oldErr == nil:
+			name = oldCRD.Spec.Names.Kind
+		case newErr == nil:
+			name = newCRD.Spec.Names.Kind
+		default:
+			name = provider.CRD.Kind + provider.CRD.File
+		}
+	}
+	for _, crdVersion := range crdVersions {
+		report := resourceUpgrade{Name: name, CRDVersion: crdVersion, OldVersion: oldVersion, NewVersion: u.version}
+		switch {
+		case oldErr != nil:
+			report.Error = fmt.Sprintf("cannot load the crd of version %s: %s", oldVersion, strings.TrimSpace(oldErr.Error()))
+		case newErr != nil:
+			report.Error = fmt.Sprintf("cannot load the crd of version %s: %s", u.version, strings.TrimSpace(newErr.Error()))
+		default:
+			compareCRDs(&report, oldCRD, newCRD, crdVersion, detectors)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Add the changes between the version of two crds to the report
+func compareCRDs(report *resourceUpgrade, oldCRD, newCRD extv1.CustomResourceDefinition, version string, detectors []tagDetector) {
+	report.AddedFields, report.RemovedFields, report.ChangedValidations = []string{}, []string{}, []fieldChange{}
+	oldSchema, ok := crdVersionSchema(oldCRD, version)
+	if !ok {
+		report.Error = fmt.Sprintf("the crd of version %s has no version %s", report.OldVersion, version)
+		return
+	}
+	newSchema, ok := crdVersionSchema(newCRD, version)
+	if !ok {
+		report.Error = fmt.Sprintf("the crd of version %s has no version %s", report.NewVersion, version)
+		return
+	}
+	for _, property := range []string{"spec", "status"} {
+		oldProperty, newProperty := oldSchema.Properties[property], newSchema.Properties[property]
+		changes := append(diffSchema(property, &oldProperty, &newProperty), diffSchemaValidations(property, &oldProperty, &newProperty)...)
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].path < changes[j].path
+		})
+		for _, c := range changes {
+			switch c.kind {
+			case apiAdded:
+				report.AddedFields = append(report.AddedFields, c.path)
+			case apiRemoved:
+				report.RemovedFields = append(report.RemovedFields, c.path)
+			default:
+				report.ChangedValidations = append(report.ChangedValidations, fieldChange{Path: c.path, Message: c.message, Breaking: c.breaking})
+			}
+		}
+	}
+	oldTags, newTags := detectTags(oldCRD, version, detectors), detectTags(newCRD, version, detectors)
+	if oldTags != newTags {
+		report.OldTags, report.NewTags = newTagShape(oldTags), newTagShape(newTags)
+	}
+}
+
+// Compare the validations of the properties of two schemas, properties whose
+// type changed are already reported by diffSchema
+func diffSchemaValidations(path string, oldSchema, newSchema *extv1.JSONSchemaProps) []apiChange {
+	if oldSchema.Type != newSchema.Type {
+		return nil
+	}
+	changes := diffValidations(path, oldSchema, newSchema)
+	for _, name := range sortedProperties(oldSchema.Properties) {
+		if newProperty, ok := newSchema.Properties[name]; ok {
+			oldProperty := oldSchema.Properties[name]
+			changes = append(changes, diffSchemaValidations(path+"."+name, &oldProperty, &newProperty)...)
+		}
+	}
+	if oldSchema.Items != nil && oldSchema.Items.Schema != nil && newSchema.Items != nil && newSchema.Items.Schema != nil {
+		changes = append(changes, diffSchemaValidations(path+"[*]", oldSchema.Items.Schema, newSchema.Items.Schema)...)
+	}
+	if oldSchema.AdditionalProperties != nil && oldSchema.AdditionalProperties.Schema != nil &&
+		newSchema.AdditionalProperties != nil && newSchema.AdditionalProperties.Schema != nil {
+		changes = append(changes, diffSchemaValidations(path+"[*]", oldSchema.AdditionalProperties.Schema, newSchema.AdditionalProperties.Schema)...)
+	}
+	return changes
+}
+
+// Compare the validations of a property, restricting a value is breaking
+func diffValidations(path string, oldSchema, newSchema *extv1.JSONSchemaProps) []apiChange {
+	changes := []apiChange{}
+	changed := func(message string, breaking bool) {
+		changes = append(changes, apiChange{path: path, kind: apiChanged, message: message, breaking: breaking})
+	}
+	if oldSchema.Pattern != newSchema.Pattern {
+		changed(fmt.Sprintf("pattern changed from %q to %q", oldSchema.Pattern, newSchema.Pattern), newSchema.Pattern != "")
+	}
+	if oldSchema.Format != newSchema.Format {
+		changed(fmt.Sprintf("format changed from %q to %q", oldSchema.Format, newSchema.Format), newSchema.Format != "")
+	}
+	for _, limit := range []struct {
+		name     string
+		old, new *float64
+		// upper limits are restricted by lowering them, lower limits by raising them
+		upper bool
+	}{
+		{"maximum", oldSchema.Maximum, newSchema.Maximum, true},
+		{"minimum", oldSchema.Minimum, newSchema.Minimum, false},
+		{"maxLength", intLimit(oldSchema.MaxLength), intLimit(newSchema.MaxLength), true},
+		{"minLength", intLimit(oldSchema.MinLength), intLimit(newSchema.MinLength), false},
+		{"maxItems", intLimit(oldSchema.MaxItems), intLimit(newSchema.MaxItems), true},
+		{"minItems", intLimit(oldSchema.MinItems), intLimit(newSchema.MinItems), false},
+	} {
+		switch {
+		case limit.old == nil && limit.new == nil:
+		case limit.new == nil:
+			changed(fmt.Sprintf("%s %v removed", limit.name, *limit.old), false)
+		case limit.old == nil:
+			changed(fmt.Sprintf("%s %v added", limit.name, *limit.new), true)
+		case *limit.old != *limit.new:
+			restricted := *limit.new > *limit.old
+			if limit.upper {
+				restricted = *limit.new < *limit.old
+			}
+			changed(fmt.Sprintf("%s changed from %v to %v", limit.name, *limit.old, *limit.new), restricted)
+		}
+	}
+	oldRules, newRules := validationRules(oldSchema.XValidations), validationRules(newSchema.XValidations)
+	for _, rule := range newRules {
+		if !listHas(&oldRules, rule) {
+			changed(fmt.Sprintf("validation rule added: %s", rule), true)
+		}
+	}
+	for _, rule := range oldRules {
+		if !listHas(&newRules, rule) {
+			changed(fmt.Sprintf("validation rule removed: %s", rule), false)
+		}
+	}
+	return changes
+}
+
+func intLimit(limit *int64) *float64 {
+	if limit == nil {
+		return nil
+	}
+	f := float64(*limit)
+	return &f
+}
+
+func validationRules(validations extv1.ValidationRules) []string {
+	rules := []string{}
+	for _, v := range validations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func newTagShape(tags tagDetection) *tagShape {
+	return &tagShape{Type: tags.tagType, Property: tags.property, KeyField: tags.keyField, ValueField: tags.valueField}
+}
+
+func (s *tagShape) String() string {
+	if s.Property == "" {
+		return s.Type
+	}
+	if s.KeyField != "" {
+		return fmt.Sprintf("%s at %s (%s, %s)", s.Type, s.Property, s.KeyField, s.ValueField)
+	}
+	return fmt.Sprintf("%s at %s", s.Type, s.Property)
+}
+
+// Render the report as markdown
+func (r *upgradeReport) markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Upgrade of %s to %s\n", r.Provider, r.Version)
+	if len(r.Generators) == 0 {
+		fmt.Fprintf(&sb, "\nNo generator uses %s.\n", r.Provider)
+	}
+	for _, g := range r.Generators {
+		fmt.Fprintf(&sb, "\n## %s\n", g.Path)
+		if len(g.Resources) == 0 {
+			fmt.Fprintf(&sb, "\nDoes not use %s.\n", r.Provider)
+		}
+		for _, res := range g.Resources {
+			fmt.Fprintf(&sb, "\n### %s %s (%s to %s)\n\n", res.Name, res.CRDVersion, res.OldVersion, res.NewVersion)
+			if res.Error != "" {
+				fmt.Fprintf(&sb, "Error: %s\n", res.Error)
+				continue
+			}
+			if len(res.AddedFields) == 0 && len(res.RemovedFields) == 0 && len(res.ChangedValidations) == 0 && res.NewTags == nil {
+				sb.WriteString("No changes.\n")
+				continue
+			}
+			writeList := func(title string, items []string) {
+				if len(items) == 0 {
+					return
+				}
+				fmt.Fprintf(&sb, "%s:\n\n", title)
+				for _, item := range items {
+					fmt.Fprintf(&sb, "- %s\n", item)
+				}
+				sb.WriteString("\n")
+			}
+			writeList("New fields", quoted(res.AddedFields))
+			writeList("Removed fields", quoted(res.RemovedFields))
+			validations := []string{}
+			for _, c := range res.ChangedValidations {
+				item := fmt.Sprintf("`%s`: %s", c.Path, c.Message)
+				if c.Breaking {
+					item += " (breaking)"
+				}
+				validations = append(validations, item)
+			}
+			writeList("Changed validations", validations)
+			if res.NewTags != nil {
+				fmt.Fprintf(&sb, "Changed tags: %s instead of %s\n\n", res.NewTags, res.OldTags)
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+func quoted(items []string) []string {
+	result := []string{}
+	for _, item := range items {
+		result = append(result, "`"+item+"`")
+	}
+	return result
+}
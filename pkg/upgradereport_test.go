@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/ghodss/yaml"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func Test_compareCRDs(t *testing.T) {
+	pattern := "^[a-z]+$"
+	oldCRD := crdWithForProvider(map[string]extv1.JSONSchemaProps{
+		"region": {Type: "string"},
+		"acl":    {Type: "string"},
+		"tags":   arrayOf("key", "value"),
+	})
+	newCRD := crdWithForProvider(map[string]extv1.JSONSchemaProps{
+		"region": {Type: "string", Pattern: pattern},
+		"policy": {Type: "string"},
+		"labels": objectOfStrings,
+	})
+	detectors := newTagDetectors([]xtype.TagDetector{{Path: "spec.forProvider.labels"}})
+	report := resourceUpgrade{Name: "Bucket", CRDVersion: "v1beta1", OldVersion: "v1.0.0", NewVersion: "v2.0.0"}
+	compareCRDs(&report, oldCRD, newCRD, "v1beta1", detectors)
+
+	want := resourceUpgrade{
+		Name:          "Bucket",
+		CRDVersion:    "v1beta1",
+		OldVersion:    "v1.0.0",
+		NewVersion:    "v2.0.0",
+		AddedFields:   []string{"spec.forProvider.labels", "spec.forProvider.policy"},
+		RemovedFields: []string{"spec.forProvider.acl", "spec.forProvider.tags"},
+		ChangedValidations: []fieldChange{
+			{Path: "spec.forProvider.region", Message: "pattern changed from \"\" to \"^[a-z]+$\"", Breaking: true},
+		},
+		OldTags: &tagShape{Type: "keyValueArray", Property: "spec.forProvider.tags", KeyField: "key", ValueField: "value"},
+		NewTags: &tagShape{Type: "tagObject", Property: "spec.forProvider.labels"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("compareCRDs() = %+v, want %+v", report, want)
+	}
+
+	report = resourceUpgrade{Name: "Bucket", CRDVersion: "v1beta2", OldVersion: "v1.0.0", NewVersion: "v2.0.0"}
+	compareCRDs(&report, oldCRD, newCRD, "v1beta2", detectors)
+	if report.Error != "the crd of version v1.0.0 has no version v1beta2" {
+		t.Errorf("compareCRDs() error = %q", report.Error)
+	}
+}
+
+func providerNamed(name, version string) xtype.ProviderConfig {
+	return xtype.ProviderConfig{GlobalProviderConfig: xtype.GlobalProviderConfig{Name: name, Version: version}}
+}
+
+func Test_providerUpgrade_apply(t *testing.T) {
+	generatorConfig := &xtype.GeneratorConfig{Provider: xtype.GlobalProviderConfig{Name: "provider-aws", Version: "v1.0.0"}}
+	upgrade := providerUpgrade{name: "provider-aws", version: "v2.0.0"}
+
+	upgraded, _, oldVersion, ok := upgrade.apply(generatorConfig, xtype.ProviderConfig{})
+	if !ok || oldVersion != "v1.0.0" || upgraded.Provider.Version != "v2.0.0" || generatorConfig.Provider.Version != "v1.0.0" {
+		t.Errorf("apply() global provider = %v, %s, %v", upgraded.Provider, oldVersion, ok)
+	}
+	_, provider, oldVersion, ok := upgrade.apply(generatorConfig, providerNamed("provider-aws", "v1.1.0"))
+	if !ok || oldVersion != "v1.1.0" || provider.Version != "v2.0.0" {
+		t.Errorf("apply() local provider = %v, %s, %v", provider, oldVersion, ok)
+	}
+	if _, _, _, ok := upgrade.apply(generatorConfig, providerNamed("provider-gcp", "v1.0.0")); ok {
+		t.Errorf("apply() should not upgrade other providers")
+	}
+}
+
+func Test_upgradeReport_markdown(t *testing.T) {
+	report := upgradeReport{
+		Provider: "provider-aws",
+		Version:  "v2.0.0",
+		Generators: []generatorUpgrade{{
+			Path: "bucket/generate.yaml",
+			Resources: []resourceUpgrade{
+				{
+					Name: "Bucket", CRDVersion: "v1beta1", OldVersion: "v1.0.0", NewVersion: "v2.0.0",
+					AddedFields:        []string{"spec.forProvider.policy"},
+					ChangedValidations: []fieldChange{{Path: "spec.forProvider.region", Message: "maxLength 16 added", Breaking: true}},
+					OldTags:            &tagShape{Type: "none"},
+					NewTags:            &tagShape{Type: "tagObject", Property: "spec.forProvider.tags"},
+				},
+				{Name: "Policy", CRDVersion: "v1beta1", OldVersion: "v1.0.0", NewVersion: "v2.0.0", Error: "crd not found"},
+			},
+		}},
+	}
+	got := report.markdown()
+	for _, want := range []string{
+		"# Upgrade of provider-aws to v2.0.0\n",
+		"## bucket/generate.yaml\n",
+		"### Bucket v1beta1 (v1.0.0 to v2.0.0)\n",
+		"New fields:\n\n- `spec.forProvider.policy`\n",
+		"Changed validations:\n\n- `spec.forProvider.region`: maxLength 16 added (breaking)\n",
+		"Changed tags: tagObject at spec.forProvider.tags instead of none\n",
+		"### Policy v1beta1 (v1.0.0 to v2.0.0)\n\nError: crd not found\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("markdown() should contain %q, got %s", want, got)
+		}
+	}
+	if strings.Contains(got, "Removed fields") {
+		t.Errorf("markdown() should not contain empty sections, got %s", got)
+	}
+}
+
+// Write a provider package of the crd to the local registry in dir
+func writeRegistryPackage(t *testing.T, dir, version string, crd extv1.CustomResourceDefinition) {
+	t.Helper()
+	crd.APIVersion, crd.Kind, crd.Name = "apiextensions.k8s.io/v1", "CustomResourceDefinition", "buckets.s3.aws.upbound.io"
+	crd.Spec.Group, crd.Spec.Names = "s3.aws.upbound.io", extv1.CustomResourceDefinitionNames{Kind: "Bucket", Plural: "buckets"}
+	content, err := yaml.Marshal(crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := "apiVersion: meta.pkg.crossplane.io/v1\nkind: Provider\nmetadata:\n  name: provider-aws-s3\n---\n" + string(content)
+	writeFiles(t, dir, map[string][]byte{"provider-aws-s3/" + version + "/" + xpkgStreamFile: []byte(stream)})
+}
+
+func Test_providerUpgrade_resourceReports(t *testing.T) {
+	registry := t.TempDir()
+	maxLength, minItems := int64(63), int64(1)
+	writeRegistryPackage(t, registry, "v1.0.0", crdWithForProvider(map[string]extv1.JSONSchemaProps{
+		"region": {Type: "string"},
+		"tags":   arrayOf("key", "value"),
+	}))
+	writeRegistryPackage(t, registry, "v2.0.0", crdWithForProvider(map[string]extv1.JSONSchemaProps{
+		"region": {Type: "string", MaxLength: &maxLength},
+		"tags": {Type: "array", MinItems: &minItems, Items: &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{
+			Type:       "object",
+			Properties: map[string]extv1.JSONSchemaProps{"key": {Type: "string", Pattern: "^[a-z]+$"}, "value": {Type: "string"}},
+		}}},
+		"policy": {Type: "string"},
+	}))
+	crdPath := t.TempDir()
+	single := filepath.Join(registry, "provider-aws-s3", "v1.0.0")
+	upgrade := providerUpgrade{name: "provider-aws-s3", version: "v2.0.0"}
+	provider := xtype.ProviderConfig{CRD: xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Bucket"}}
+
+	tests := []struct {
+		name     string
+		provider xtype.GlobalProviderConfig
+		want     []resourceUpgrade
+		wantErr  string
+	}{
+		{
+			name:     "Should compare the crds of both versions of a local registry",
+			provider: xtype.GlobalProviderConfig{Name: "provider-aws-s3", Version: "v1.0.0", Package: &registry},
+			want: []resourceUpgrade{{
+				Name:          "Bucket",
+				CRDVersion:    "v1beta1",
+				OldVersion:    "v1.0.0",
+				NewVersion:    "v2.0.0",
+				AddedFields:   []string{"spec.forProvider.policy"},
+				RemovedFields: []string{},
+				ChangedValidations: []fieldChange{
+					{Path: "spec.forProvider.region", Message: "maxLength 63 added", Breaking: true},
+					{Path: "spec.forProvider.tags", Message: "minItems 1 added", Breaking: true},
+					{Path: "spec.forProvider.tags[*].key", Message: "pattern changed from \"\" to \"^[a-z]+$\"", Breaking: true},
+				},
+			}},
+		},
+		{
+			name:     "Should fail if the crd does not depend on the version",
+			provider: xtype.GlobalProviderConfig{Name: "provider-aws-s3", Version: "v1.0.0", CRDPath: &crdPath},
+			wantErr:  "the crd is read from " + crdPath + " for both v1.0.0 and v2.0.0, the location does not depend on the provider version",
+		},
+		{
+			name:     "Should fail for a single package",
+			provider: xtype.GlobalProviderConfig{Name: "provider-aws-s3", Version: "v1.0.0", Package: &single},
+			wantErr:  "the crd is read from " + single + "#Bucket.s3.aws.upbound.io for both v1.0.0 and v2.0.0, the location does not depend on the provider version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Generator{log: log.New(io.Discard, "", 0)}
+			loader := &crdLoader{bundles: newBundleCache(), fetches: newFetchGroup()}
+			got, err := upgrade.resourceReports(g, &xtype.GeneratorConfig{Provider: tt.provider}, provider, "", []string{"v1beta1"}, defaultTagDetectors, loader)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("resourceReports() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resourceReports() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resourceReports() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// Package version contains the version of the generator
+package version
+
+// Version of the generator, set at build time using
+// -ldflags "-X github.com/crossplane-contrib/x-generation/pkg/version.Version=..."
+var Version = "dev"
@@ -0,0 +1,60 @@
+package main
+
+import (
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Return the served versions of the xrd with the crd version each is generated
+// from. The referenceable version is returned first, it is the version of the
+// generator unless one of the versions is marked referenceable.
+func (g *Generator) xrdVersions() []t.XRDVersion {
+	crdVersion := g.Provider.CRD.Version
+	if crdVersion == "" {
+		crdVersion = g.Version
+	}
+	versions := []t.XRDVersion{{Name: g.Version, CRDVersion: crdVersion, Referenceable: true}}
+	for _, v := range g.Versions {
+		if v.CRDVersion == "" {
+			v.CRDVersion = crdVersion
+		}
+		if v.Referenceable {
+			versions[0].Referenceable = false
+			versions = append([]t.XRDVersion{v}, versions...)
+		} else {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// Check the versions of the xrd: the names are unique, at most one version is
+// referenceable and the crd has the versions they are generated from
+func (g *Generator) checkVersions() error {
+	if len(g.Versions) == 0 {
+		return nil
+	}
+	names := []string{g.Version}
+	referenceable := 0
+	for _, v := range g.Versions {
+		if v.Name == "" {
+			return errors.New("versions must have a name")
+		}
+		if listHas(&names, v.Name) {
+			return errors.Errorf("version %s is not unique", v.Name)
+		}
+		names = append(names, v.Name)
+		if v.Referenceable {
+			referenceable++
+		}
+	}
+	if referenceable > 1 {
+		return errors.New("only one version can be referenceable")
+	}
+	for _, v := range g.xrdVersions() {
+		if _, ok := crdVersionSchema(g.crd, v.CRDVersion); !ok {
+			return errors.Errorf("version %s: the crd has no version %s", v.Name, v.CRDVersion)
+		}
+	}
+	return nil
+}
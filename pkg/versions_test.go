@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestGenerator_RenderVersions(t *testing.T) {
+	for _, usePipeline := range []bool{false, true} {
+		g := newTestGenerator(t, t.TempDir(), usePipeline)
+		v2 := *g.crd.Spec.Versions[0].DeepCopy()
+		v2.Name = "testv2"
+		v2.Schema.OpenAPIV3Schema.Properties["spec"].Properties["forProvider"].Properties["zone"] = extv1.JSONSchemaProps{Type: "string"}
+		g.crd.Spec.Versions = append(g.crd.Spec.Versions, v2)
+		crdSource, err := json.Marshal(g.crd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.crdSource = string(crdSource)
+		g.Versions = []xtype.XRDVersion{{Name: "v1beta1", CRDVersion: "testv2", Referenceable: true}}
+
+		gConfig := xtype.GeneratorConfig{CompositionIdentifier: "example.cloud"}
+		if err := g.CheckConfig(&gConfig); err != nil {
+			t.Fatalf("CheckConfig() error = %v", err)
+		}
+		xrd, compositions := renderTestGenerator(t, &g, &gConfig)
+
+		referenceable := map[string]bool{}
+		for _, v := range xrd.Spec.Versions {
+			if !v.Served {
+				t.Errorf("Render() usePipeline %v version %s should be served", usePipeline, v.Name)
+			}
+			referenceable[v.Name] = v.Referenceable
+			// each version has the schema of its crd version
+			schema, err := versionSchema(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, hasZone := schemaAt(schema, "spec.forProvider.zone"); hasZone != (v.Name == "v1beta1") {
+				t.Errorf("Render() usePipeline %v version %s has zone = %v", usePipeline, v.Name, hasZone)
+			}
+		}
+		want := map[string]bool{"v1beta1": true, "testv1": false}
+		if len(referenceable) != len(want) || referenceable["v1beta1"] != true || referenceable["testv1"] != false {
+			t.Errorf("Render() usePipeline %v versions = %v, want %v", usePipeline, referenceable, want)
+		}
+
+		// the composition uses the referenceable version
+		composition := compositions[0]
+		if got := composition.Spec.CompositeTypeRef.APIVersion; got != "example.cloud/v1beta1" {
+			t.Errorf("Render() usePipeline %v compositeTypeRef apiVersion = %s, want example.cloud/v1beta1", usePipeline, got)
+		}
+		input := patchAndTransformInput(t, &composition)
+		if got := resourceBase(t, input, 0).GetAPIVersion(); got != "/testv2" {
+			t.Errorf("Render() usePipeline %v base apiVersion = %s, want /testv2", usePipeline, got)
+		}
+		if findPatch(resourcePatches(t, input, 0), "spec.forProvider.zone", "spec.forProvider.zone") == nil {
+			t.Errorf("Render() usePipeline %v should patch spec.forProvider.zone", usePipeline)
+		}
+	}
+}
+
+func TestGenerator_CheckVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []xtype.XRDVersion
+		wantErr  string
+	}{
+		{
+			name:     "Should accept served versions",
+			versions: []xtype.XRDVersion{{Name: "v1alpha1"}},
+		},
+		{
+			name:     "Should reject duplicate versions",
+			versions: []xtype.XRDVersion{{Name: "testv1"}},
+			wantErr:  "not unique",
+		},
+		{
+			name:     "Should reject more than one referenceable version",
+			versions: []xtype.XRDVersion{{Name: "v1alpha1", Referenceable: true}, {Name: "v1alpha2", Referenceable: true}},
+			wantErr:  "only one version",
+		},
+		{
+			name:     "Should reject unknown crd versions",
+			versions: []xtype.XRDVersion{{Name: "v1alpha1", CRDVersion: "v9"}},
+			wantErr:  "the crd has no version v9",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, t.TempDir(), true)
+			g.Versions = tt.versions
+			err := g.CheckConfig(&xtype.GeneratorConfig{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	t "github.com/crossplane-contrib/x-generation/pkg/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// name of the file containing the package metadata and all crds in an xpkg layer
+	xpkgStreamFile = "package.yaml"
+	ociIndexFile   = "index.json"
+	// manifest of an image tarball as written by docker save or crane
+	tarballManifestFile = "manifest.json"
+)
+
+// packageSource reads crds from a crossplane provider package. The path can
+// point to an OCI image layout directory, an .xpkg image tarball, a directory
+// containing an extracted package.yaml or a directory acting as a local
+// registry with packages stored as <path>/<provider name>/<provider version>.
+type packageSource struct {
+	path            string
+	providerName    string
+	providerVersion string
+
+	bundles *bundleCache
+	logger  *log.Logger
+}
+
+func (s *packageSource) Location(crd t.CrdConfig) string {
+	p := resolvePackage(s.path, s.providerName, s.providerVersion)
+	if crd.Kind != "" {
+		return p + "#" + crd.Kind + "." + crd.Group
+	}
+	return p + "#" + crd.File
+}
+
+func (s *packageSource) Fetch(crd t.CrdConfig) ([]byte, error) {
+	p := resolvePackage(s.path, s.providerName, s.providerVersion)
+	crds, err := s.bundles.Load(p, func() ([]bundleCRD, error) {
+		logf(s.logger, "Reading provider package %s\n", p)
+		stream, err := readPackageStream(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read provider package %s", p)
+		}
+		crds, err := parseCRDDocuments(stream)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse provider package %s", p)
+		}
+		return crds, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return selectCRD(crds, crd, "package "+p)
+}
+
+// Resolve the path of the package of the provider version. A directory that
+// is not a package acts as local registry and is resolved to
+// <path>/<provider name>/<provider version>, with or without .xpkg extension.
+func resolvePackage(p, providerName, providerVersion string) string {
+	info, err := os.Stat(p)
+	if err != nil || !info.IsDir() {
+		return p
+	}
+	for _, file := range []string{xpkgStreamFile, ociIndexFile} {
+		if _, err := os.Stat(filepath.Join(p, file)); err == nil {
+			return p
+		}
+	}
+	registryPath := filepath.Join(p, providerName, providerVersion)
+	if _, err := os.Stat(registryPath); err != nil {
+		if _, err := os.Stat(registryPath + ".xpkg"); err == nil {
+			return registryPath + ".xpkg"
+		}
+	}
+	return registryPath
+}
+
+// Read the package.yaml stream of the package at path
+func readPackageStream(p string) ([]byte, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readPackageTarball(p)
+	}
+	if stream, err := os.ReadFile(filepath.Join(p, xpkgStreamFile)); err == nil {
+		return stream, nil
+	}
+	if _, err := os.Stat(filepath.Join(p, ociIndexFile)); err == nil {
+		return readOCILayout(func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(p, filepath.FromSlash(name)))
+		})
+	}
+	return nil, errors.Errorf("%s is not a package", p)
+}
+
+// Read the package.yaml stream of an image tarball, either in the format of
+// docker save or an archived OCI image layout
+func readPackageTarball(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	files, err := readTar(f)
+	if err != nil {
+		return nil, err
+	}
+	readFile := func(name string) ([]byte, error) {
+		content, ok := files[path.Clean(name)]
+		if !ok {
+			return nil, errors.Errorf("missing %s", name)
+		}
+		return content, nil
+	}
+	if _, ok := files[ociIndexFile]; ok {
+		return readOCILayout(readFile)
+	}
+	manifestFile, ok := files[tarballManifestFile]
+	if !ok {
+		return nil, errors.Errorf("neither %s nor %s found", ociIndexFile, tarballManifestFile)
+	}
+	var manifests []struct {
+		Layers []string `json:"Layers"`
+	}
+	if err := json.Unmarshal(manifestFile, &manifests); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", tarballManifestFile)
+	}
+	if len(manifests) == 0 {
+		return nil, errors.Errorf("%s contains no image", tarballManifestFile)
+	}
+	layers := [][]byte{}
+	for _, l := range manifests[0].Layers {
+		layer, err := readFile(l)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return findPackageStream(layers)
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// Read the package.yaml stream of an OCI image layout, readFile returns the
+// content of a file relative to the root of the layout
+func readOCILayout(readFile func(name string) ([]byte, error)) ([]byte, error) {
+	readBlob := func(digest string) ([]byte, error) {
+		algorithm, hash, ok := strings.Cut(digest, ":")
+		if !ok {
+			return nil, errors.Errorf("invalid digest %s", digest)
+		}
+		return readFile(path.Join("blobs", algorithm, hash))
+	}
+	indexFile, err := readFile(ociIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	var index struct {
+		Manifests []ociDescriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(indexFile, &index); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", ociIndexFile)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, errors.Errorf("%s contains no manifest", ociIndexFile)
+	}
+	manifestFile, err := readBlob(index.Manifests[0].Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Manifests []ociDescriptor `json:"manifests"`
+		Layers    []ociDescriptor `json:"layers"`
+	}
+	if err := json.Unmarshal(manifestFile, &manifest); err != nil {
+		return nil, errors.Wrap(err, "cannot parse image manifest")
+	}
+	// an image index as manifest, use the first image
+	if len(manifest.Layers) == 0 && len(manifest.Manifests) > 0 {
+		if manifestFile, err = readBlob(manifest.Manifests[0].Digest); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(manifestFile, &manifest); err != nil {
+			return nil, errors.Wrap(err, "cannot parse image manifest")
+		}
+	}
+	layers := [][]byte{}
+	for _, l := range manifest.Layers {
+		layer, err := readBlob(l.Digest)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return findPackageStream(layers)
+}
+
+// Find the package.yaml in the given layers, the last layer containing the file wins
+func findPackageStream(layers [][]byte) ([]byte, error) {
+	var stream []byte
+	for _, layer := range layers {
+		files, err := readTar(bytes.NewReader(layer))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read layer")
+		}
+		if content, ok := files[xpkgStreamFile]; ok {
+			stream = content
+		}
+	}
+	if stream == nil {
+		return nil, errors.Errorf("no layer contains %s", xpkgStreamFile)
+	}
+	return stream, nil
+}
+
+// Read all regular files of a (possibly gzip compressed) tar archive
+func readTar(r io.Reader) (map[string][]byte, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(h.Name)] = content
+	}
+}
+
+// Split a multi document yaml stream into its non empty documents
+func splitYAMLDocuments(stream []byte) [][]byte {
+	docs := [][]byte{}
+	current := []byte{}
+	add := func() {
+		if len(bytes.TrimSpace(current)) > 0 {
+			docs = append(docs, current)
+		}
+		current = []byte{}
+	}
+	for _, line := range bytes.SplitAfter(stream, []byte("\n")) {
+		if bytes.Equal(bytes.TrimRight(line, " \r\n"), []byte("---")) {
+			add()
+			continue
+		}
+		current = append(current, line...)
+	}
+	add()
+	return docs
+}
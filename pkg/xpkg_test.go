@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	xtype "github.com/crossplane-contrib/x-generation/pkg/types"
+)
+
+const testPackageStream = `apiVersion: meta.pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider-aws-s3
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.s3.aws.upbound.io
+spec:
+  group: s3.aws.upbound.io
+  names:
+    kind: Bucket
+    plural: buckets
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: bucketpolicies.s3.aws.upbound.io
+spec:
+  group: s3.aws.upbound.io
+  names:
+    kind: BucketPolicy
+    plural: bucketpolicies
+`
+
+func writeTar(t *testing.T, files map[string][]byte, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Create the files of an OCI image layout containing the package stream
+func ociLayoutFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	layer := writeTar(t, map[string][]byte{xpkgStreamFile: []byte(testPackageStream)}, true)
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"layers": []map[string]string{
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": digest(layer)},
+		},
+	})
+	index, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"manifests": []map[string]string{
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": digest(manifest)},
+		},
+	})
+	return map[string][]byte{
+		ociIndexFile: index,
+		"oci-layout": []byte(`{"imageLayoutVersion": "1.0.0"}`),
+		"blobs/sha256/" + strings.TrimPrefix(digest(layer), "sha256:"):    layer,
+		"blobs/sha256/" + strings.TrimPrefix(digest(manifest), "sha256:"): manifest,
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_packageSource_Fetch(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ociDir := filepath.Join(tempDir, "oci")
+	writeFiles(t, ociDir, ociLayoutFiles(t))
+
+	ociTarball := filepath.Join(tempDir, "oci.xpkg")
+	writeFiles(t, tempDir, map[string][]byte{"oci.xpkg": writeTar(t, ociLayoutFiles(t), false)})
+
+	layer := writeTar(t, map[string][]byte{xpkgStreamFile: []byte(testPackageStream)}, false)
+	dockerTarball := writeTar(t, map[string][]byte{
+		tarballManifestFile: []byte(`[{"Config": "config.json", "Layers": ["layer.tar"]}]`),
+		"config.json":       []byte(`{}`),
+		"layer.tar":         layer,
+	}, false)
+	writeFiles(t, tempDir, map[string][]byte{
+		"docker.xpkg":                          dockerTarball,
+		"extracted/" + xpkgStreamFile:          []byte(testPackageStream),
+		"registry/provider-aws-s3/v1.0.0.xpkg": dockerTarball,
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		crd     xtype.CrdConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Should select the crd by kind from an OCI layout",
+			path: ociDir,
+			crd:  xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "BucketPolicy"},
+			want: "bucketpolicies.s3.aws.upbound.io",
+		},
+		{
+			name: "Should select the crd by file name from an OCI layout tarball",
+			path: ociTarball,
+			crd:  xtype.CrdConfig{File: "s3.aws.upbound.io_buckets.yaml"},
+			want: "buckets.s3.aws.upbound.io",
+		},
+		{
+			name: "Should read an image tarball",
+			path: filepath.Join(tempDir, "docker.xpkg"),
+			crd:  xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Bucket"},
+			want: "buckets.s3.aws.upbound.io",
+		},
+		{
+			name: "Should read an extracted package",
+			path: filepath.Join(tempDir, "extracted"),
+			crd:  xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Bucket"},
+			want: "buckets.s3.aws.upbound.io",
+		},
+		{
+			name: "Should read a package from a local registry",
+			path: filepath.Join(tempDir, "registry"),
+			crd:  xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Bucket"},
+			want: "buckets.s3.aws.upbound.io",
+		},
+		{
+			name:    "Should fail for a missing kind",
+			path:    ociDir,
+			crd:     xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Object"},
+			wantErr: true,
+		},
+		{
+			name:    "Should fail for a kind of another group",
+			path:    ociDir,
+			crd:     xtype.CrdConfig{Group: "s3.aws.crossplane.io", Kind: "Bucket"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &packageSource{
+				path:            tt.path,
+				providerName:    "provider-aws-s3",
+				providerVersion: "v1.0.0",
+			}
+			got, err := s.Fetch(tt.crd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !strings.Contains(string(got), "name: "+tt.want) {
+				t.Errorf("Fetch() = %s, want crd %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_packageSource_FetchVersions(t *testing.T) {
+	registry := t.TempDir()
+	writeFiles(t, registry, map[string][]byte{
+		"provider-aws-s3/v1.0.0/" + xpkgStreamFile: []byte(testPackageStream),
+		"provider-aws-s3/v2.0.0/" + xpkgStreamFile: []byte(strings.Replace(testPackageStream, "plural: buckets", "plural: buckets\n    singular: bucket", 1)),
+	})
+	loader := &crdLoader{bundles: newBundleCache(), fetches: newFetchGroup()}
+	crd := xtype.CrdConfig{Group: "s3.aws.upbound.io", Kind: "Bucket"}
+	for _, version := range []string{"v1.0.0", "v2.0.0", "v1.0.0"} {
+		s := &packageSource{path: registry, providerName: "provider-aws-s3", providerVersion: version, bundles: loader.bundles}
+		got, err := loader.fetch(s, crd)
+		if err != nil {
+			t.Fatalf("fetch() %s error = %v", version, err)
+		}
+		if want := version == "v2.0.0"; strings.Contains(string(got), "singular: bucket") != want {
+			t.Errorf("fetch() %s = %s, want the crd of %s", version, got, version)
+		}
+		if want := filepath.Join(registry, "provider-aws-s3", version) + "#Bucket.s3.aws.upbound.io"; s.Location(crd) != want {
+			t.Errorf("Location() = %s, want %s", s.Location(crd), want)
+		}
+	}
+	tarball := filepath.Join(t.TempDir(), "provider.xpkg")
+	s := &packageSource{path: tarball, providerName: "provider-aws-s3", providerVersion: "v2.0.0"}
+	if want := tarball + "#Bucket.s3.aws.upbound.io"; s.Location(crd) != want {
+		t.Errorf("Location() = %s, want %s independent of the version", s.Location(crd), want)
+	}
+}